@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/creativeprojects/catalogue/constants"
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/pterm/pterm"
+)
+
+// openDatabase opens the database file given on the command line for writing, with its shards.
+// The database is migrated to the current version when needed, after saving a backup of the file.
+func openDatabase() (*database.Database, error) {
+	storage, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	db := database.NewShardedDatabase(instrumentStore(rootDSN.Path, storage), newShards(storage, false))
+	plan, err := db.MigrationPlan()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if len(plan) > 0 {
+		backup, err := migrateDatabase(db, storage, plan)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		pterm.Info.Printfln("Database migrated to version %s (backup saved into %q)", database.CurrentVersion, backup)
+	}
+	return db, nil
+}
+
+// openDatabaseReadOnly opens the database file given on the command line in read-only mode, with its shards.
+// A database needing a migration cannot be opened: the migration needs a write access.
+func openDatabaseReadOnly() (*database.Database, error) {
+	storage, err := openStoreReadOnly()
+	if err != nil {
+		return nil, err
+	}
+	db := database.NewShardedDatabase(instrumentStore(rootDSN.Path, storage), newShards(storage, true))
+	plan, err := db.MigrationPlan()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if len(plan) > 0 {
+		db.Close()
+		return nil, fmt.Errorf("the database needs to be migrated from version %s to version %s: please run '%s db migrate' first",
+			plan[0].From, database.CurrentVersion, constants.Name)
+	}
+	return db, nil
+}
+
+// newShards returns the shards saved next to the database file given on the command line.
+// The new shards are created with the same encryption and compression as the database.
+func newShards(storage store.Store, readOnly bool) *database.Shards {
+	return database.NewShards(shardsDir(rootDSN.Path), func(filename string, create bool) (store.Store, error) {
+		shard, err := openShardFile(storage, filename, create, readOnly)
+		if err != nil {
+			return nil, err
+		}
+		return instrumentStore(filename, shard), nil
+	})
+}
+
+// openShardFile opens the shard file, or creates it with the encryption and the compression of the database storage
+func openShardFile(storage store.Store, filename string, create, readOnly bool) (store.Store, error) {
+	if !create {
+		return openStoreFile(rootDSN.withPath(filename), readOnly)
+	}
+	encryption, err := getEncryptionOptions(storage)
+	if err != nil {
+		return nil, err
+	}
+	compression, err := getCompressionOptions(storage)
+	if err != nil {
+		return nil, err
+	}
+	return createStoreFile(rootDSN.withPath(filename), encryption, compression)
+}
+
+// shardsDir returns the directory holding the shards of the database file
+func shardsDir(filename string) string {
+	return filename + ".shards"
+}
+
+// migrateDatabase saves a backup of the database file, and of each shard file, before running the migration plan.
+// It returns the name of the backup of the database: the backup of a shard is saved next to the shard file.
+func migrateDatabase(db *database.Database, storage store.Store, plan []database.Migration) (string, error) {
+	backup := migrationBackupFilename(rootDSN.Path, plan[0].From)
+	// the snapshot is taken from the raw file, so an encrypted database stays encrypted
+	err := writeFileAtomic(backup, func(w io.Writer) error {
+		_, err := storage.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot backup database before migration: %w", err)
+	}
+	err = backupShards(db, plan[0].From)
+	if err != nil {
+		return backup, fmt.Errorf("cannot backup shards before migration: %w", err)
+	}
+	err = db.Migrate(plan)
+	if err != nil {
+		return backup, fmt.Errorf("cannot migrate database (backups saved into %q and next to each shard): %w", backup, err)
+	}
+	return backup, nil
+}
+
+// backupShards saves a backup of the shard file of each sharded volume, next to the shard file
+func backupShards(db *database.Database, version database.Version) error {
+	volumes, err := db.Volumes()
+	if err != nil {
+		return err
+	}
+	for _, record := range volumes {
+		if !record.Sharded {
+			continue
+		}
+		if db.Shards() == nil {
+			return database.ErrNoShards
+		}
+		backup := migrationBackupFilename(db.Shards().Filename(record.ID), version)
+		err = writeFileAtomic(backup, func(w io.Writer) error {
+			_, err := db.CopyShard(record.ID, w)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("volume %s: %w", record.ID, err)
+		}
+	}
+	return nil
+}
+
+// migrationBackupFilename returns a new backup file name next to the database, keeping the version of the database before migration
+func migrationBackupFilename(filename string, version database.Version) string {
+	return fmt.Sprintf("%s.v%s-%s.backup", filename, version, time.Now().Format("20060102-150405"))
+}
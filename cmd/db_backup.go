@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	dbCmd.AddCommand(dbBackupCmd)
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup <destination>",
+	Short: "Backup the database",
+	Long: "Copy the database into a new file. The copy is taken from a consistent snapshot in a read-only transaction, " +
+		"so other read-only commands can keep running during the backup.\n" +
+		"An encrypted database stays encrypted in the backup.\n" +
+		"With the log backend, the backup is a single compacted log file.\n" +
+		"The shards of a sharded database are not included: use 'shard copy' to save them.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		destination := args[0]
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+		if fileExists(destination) {
+			pterm.Error.Printf("Cannot backup database: file %q already exists\n", destination)
+			return
+		}
+
+		// no need to decrypt the database: the file is copied as it is
+		storage, err := openBackend(rootDSN, true)
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer storage.Close()
+
+		var size int64
+		err = writeFileAtomic(destination, func(w io.Writer) error {
+			size, err = storage.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			pterm.Error.Printfln("Cannot backup database: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Database saved into %q (%d bytes)", destination, size)
+		if fileExists(shardsDir(rootDSN.Path)) {
+			pterm.Warning.Printfln("The shards in %q are not included in the backup", shardsDir(rootDSN.Path))
+		}
+	},
+}
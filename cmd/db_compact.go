@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	dbCmd.AddCommand(dbCompactCmd)
+}
+
+var dbCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Compact the log files of the database",
+	Long: "Rewrite the log files of a database using the log backend into a single file holding only the current content. " +
+		"The database must not be used by another process during the compaction.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if rootDSN.Backend != backendLog {
+			pterm.Error.Printfln("Only a database using the %q backend can be compacted", backendLog)
+			return
+		}
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+		before, err := databaseSize(rootDSN.Path)
+		if err != nil {
+			pterm.Error.Printfln("Cannot read database size: %v", err)
+			return
+		}
+
+		options := store.DefaultLogOptions()
+		options.LockTimeout = rootFlags.LockTimeout
+		err = store.CompactLogStore(rootDSN.Path, options)
+		if err != nil {
+			pterm.Error.Printfln("Cannot compact database: %v", err)
+			return
+		}
+		after, err := databaseSize(rootDSN.Path)
+		if err != nil {
+			pterm.Error.Printfln("Cannot read database size: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Database compacted from %d to %d bytes", before, after)
+	},
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+type DBCompressFlags struct {
+	Level     int
+	Threshold int
+}
+
+var dbCompressFlags DBCompressFlags
+
+func init() {
+	defaults := store.DefaultCompressionOptions()
+	dbCompressCmd.Flags().IntVar(&dbCompressFlags.Level, "level", defaults.Level, "compression level: from 1 (fastest) to 9 (smallest), 0 for no compression, -1 for the default level of deflate (6) or -2 for Huffman coding only")
+	dbCompressCmd.Flags().IntVar(&dbCompressFlags.Threshold, "threshold", defaults.Threshold, "minimum size in bytes of a value to compress")
+	dbCmd.AddCommand(dbCompressCmd)
+}
+
+var dbCompressCmd = &cobra.Command{
+	Use:   "compress <destination>",
+	Short: "Rewrite the database in compressed form",
+	Long: "Copy the whole database into a new compressed database file. The original database is left untouched.\n" +
+		"The shards of a sharded database are compressed too.\n" +
+		"An encrypted database is copied into a new database encrypted with the same passphrase.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		destination, err := parseDSN(args[0], rootDSN.Backend)
+		if err != nil {
+			pterm.Error.Printfln("Invalid destination: %v", err)
+			return
+		}
+		if _, err := os.Stat(rootDSN.Path); os.IsNotExist(err) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+		for _, path := range []string{destination.Path, shardsDir(destination.Path)} {
+			if fileExists(path) {
+				pterm.Error.Printf("Cannot write compressed database: file %q already exists\n", path)
+				return
+			}
+		}
+		before, err := databaseSize(rootDSN.Path)
+		if err != nil {
+			pterm.Error.Printfln("Cannot read database size: %v", err)
+			return
+		}
+		compression := store.CompressionOptions{
+			Level:     dbCompressFlags.Level,
+			Threshold: dbCompressFlags.Threshold,
+		}
+
+		pterm.Info.Printfln("Compressing database %q into %q...", rootDSN.Path, destination.Path)
+		err = compressStore(rootDSN, destination, compression)
+		if err != nil {
+			pterm.Error.Printfln("Cannot compress database: %v", err)
+			return
+		}
+		shards, err := copyShards(rootDSN, destination, func(source, target DSN) error {
+			return compressStore(source, target, compression)
+		})
+		if err != nil {
+			_ = os.RemoveAll(destination.Path)
+			_ = os.RemoveAll(shardsDir(destination.Path))
+			pterm.Error.Printfln("Cannot compress shards: %v", err)
+			return
+		}
+
+		after, err := databaseSize(destination.Path)
+		if err != nil {
+			pterm.Warning.Printfln("Database compressed into %q, but cannot read its size: %v", destination.Path, err)
+		} else {
+			pterm.Success.Printfln("Database compressed from %d to %d bytes", before, after)
+		}
+		if shards > 0 {
+			pterm.Success.Printfln("%d shards compressed into %q", shards, shardsDir(destination.Path))
+		}
+	},
+}
+
+// compressStore copies the database into a new compressed database, encrypted with the same passphrase as the source.
+// The new database is removed if the copy fails.
+func compressStore(from, to DSN, compression store.CompressionOptions) error {
+	source, err := openStoreFile(from, true)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	encryption, err := getEncryptionOptions(source)
+	if err != nil {
+		return fmt.Errorf("cannot read database encryption: %w", err)
+	}
+	target, err := createStoreFile(to, encryption, &compression)
+	if err != nil {
+		return err
+	}
+	err = store.Copy(target, source, store.DefaultCopyBatchSize)
+	target.Close()
+	if err != nil {
+		_ = os.RemoveAll(to.Path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+type DBConvertFlags struct {
+	From      string
+	To        string
+	BatchSize int
+}
+
+var dbConvertFlags DBConvertFlags
+
+func init() {
+	dbConvertCmd.Flags().StringVar(&dbConvertFlags.From, "from", "", "location of the database to convert, as \"backend:path\" (default is the --database location)")
+	dbConvertCmd.Flags().StringVar(&dbConvertFlags.To, "to", "", "location of the new database, as \"backend:path\"")
+	dbConvertCmd.Flags().IntVar(&dbConvertFlags.BatchSize, "batch-size", store.DefaultCopyBatchSize, "number of keys saved in each transaction")
+	dbCmd.AddCommand(dbConvertCmd)
+}
+
+var dbConvertCmd = &cobra.Command{
+	Use:   "convert --to <backend:path>",
+	Short: "Copy the database into another storage backend",
+	Long: "Copy all the buckets and keys of the database into a new database using another storage backend, " +
+		"for example: db convert --from bolt:catalogue.db --to log:catalogue.log\n" +
+		"The keys are copied in order in batches of transactions, and the number of buckets and keys of the copy is checked at the end. " +
+		"The shards of a sharded database are converted too.\n" +
+		"The data is copied as it is: an encrypted database stays encrypted with the same passphrase.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		from := rootDSN
+		if dbConvertFlags.From != "" {
+			from, err = parseDSN(dbConvertFlags.From, rootFlags.Backend)
+			if err != nil {
+				pterm.Error.Printfln("Invalid source: %v", err)
+				return
+			}
+		}
+		if dbConvertFlags.To == "" {
+			pterm.Error.Println("Please specify the location of the new database with --to")
+			return
+		}
+		to, err := parseDSN(dbConvertFlags.To, rootFlags.Backend)
+		if err != nil {
+			pterm.Error.Printfln("Invalid destination: %v", err)
+			return
+		}
+		if !fileExists(from.Path) {
+			pterm.Error.Printf("Database %q not found\n", from.Path)
+			return
+		}
+		for _, path := range []string{to.Path, shardsDir(to.Path)} {
+			if fileExists(path) {
+				pterm.Error.Printf("Cannot convert database: file %q already exists\n", path)
+				return
+			}
+		}
+
+		pterm.Info.Printfln("Converting database %s into %s...", from, to)
+		records, err := convertStore(from, to, dbConvertFlags.BatchSize)
+		if err != nil {
+			pterm.Error.Printfln("Cannot convert database: %v", err)
+			return
+		}
+		shards, err := convertShards(from, to, dbConvertFlags.BatchSize)
+		if err != nil {
+			_ = os.RemoveAll(to.Path)
+			_ = os.RemoveAll(shardsDir(to.Path))
+			pterm.Error.Printfln("Cannot convert shards: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Database converted into %s: %d buckets and %d keys copied", to, records.Buckets, records.Keys)
+		if shards > 0 {
+			pterm.Success.Printfln("%d shards converted into %q", shards, shardsDir(to.Path))
+		}
+	},
+}
+
+// convertStore copies the source into a new database, without any decryption or decompression layer,
+// and checks the number of buckets and keys of the copy. The new database is removed if the copy fails.
+func convertStore(from, to DSN, batchSize int) (store.Records, error) {
+	source, err := openBackend(from, true)
+	if err != nil {
+		return store.Records{}, err
+	}
+	defer source.Close()
+
+	target, err := openBackend(to, false)
+	if err != nil {
+		return store.Records{}, err
+	}
+	var records store.Records
+	err = store.Copy(target, source, batchSize)
+	if err == nil {
+		records, err = verifyCopy(target, source)
+	}
+	target.Close()
+	if err != nil {
+		_ = os.RemoveAll(to.Path)
+		return records, err
+	}
+	return records, nil
+}
+
+// verifyCopy compares the number of buckets and keys of both stores
+func verifyCopy(target, source store.Store) (store.Records, error) {
+	expected, err := store.CountRecords(source)
+	if err != nil {
+		return expected, err
+	}
+	copied, err := store.CountRecords(target)
+	if err != nil {
+		return expected, err
+	}
+	if copied != expected {
+		return expected, fmt.Errorf("the copy contains %d buckets and %d keys instead of %d buckets and %d keys",
+			copied.Buckets, copied.Keys, expected.Buckets, expected.Keys)
+	}
+	return expected, nil
+}
+
+// convertShards converts all the shard files of the source, and returns the number of shards converted
+func convertShards(from, to DSN, batchSize int) (int, error) {
+	return copyShards(from, to, func(source, target DSN) error {
+		_, err := convertStore(source, target, batchSize)
+		return err
+	})
+}
+
+// copyShards runs the copy function on each shard file of the source, with the location of the shard in the target,
+// and returns the number of shards copied
+func copyShards(from, to DSN, copyShard func(source, target DSN) error) (int, error) {
+	entries, err := os.ReadDir(shardsDir(from.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), database.ShardExtension) {
+			continue
+		}
+		if count == 0 {
+			if err := os.MkdirAll(shardsDir(to.Path), 0o700); err != nil {
+				return count, err
+			}
+		}
+		source := from.withPath(filepath.Join(shardsDir(from.Path), entry.Name()))
+		target := to.withPath(filepath.Join(shardsDir(to.Path), entry.Name()))
+		err := copyShard(source, target)
+		if err != nil {
+			return count, fmt.Errorf("shard %q: %w", entry.Name(), err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+type DBMigrateFlags struct {
+	DryRun bool
+}
+
+var dbMigrateFlags DBMigrateFlags
+
+func init() {
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateFlags.DryRun, "dry-run", false, "only display the migration steps")
+	dbCmd.AddCommand(dbMigrateCmd)
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database to the current version",
+	Long: "Convert the database file to the version used by this application. " +
+		"A backup of the file is saved next to the database before running the migration, " +
+		"and all the steps run in a single transaction: the database is left untouched if any step fails. " +
+		"The files of the volumes are converted first, in batches of transactions: an interrupted migration resumes where it stopped.\n" +
+		"The commands writing into the database run the migration automatically.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		// a dry run can be done while other commands are reading the database
+		storage, err := openStoreFile(rootDSN, dbMigrateFlags.DryRun)
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		// the shards hold the files of the sharded volumes, and close with the database
+		db := database.NewShardedDatabase(instrumentStore(rootDSN.Path, storage), newShards(storage, dbMigrateFlags.DryRun))
+		defer db.Close()
+
+		plan, err := db.MigrationPlan()
+		if err != nil {
+			pterm.Error.Printfln("Cannot migrate database: %v", err)
+			return
+		}
+		if len(plan) == 0 {
+			pterm.Success.Printfln("Database is already at version %s", database.CurrentVersion)
+			return
+		}
+		for _, step := range plan {
+			pterm.Info.Printfln("Migration from version %s to %s: %s", step.From, step.To, step.Description)
+		}
+		if dbMigrateFlags.DryRun {
+			return
+		}
+
+		backup, err := migrateDatabase(db, storage, plan)
+		if err != nil {
+			pterm.Error.Println(err)
+			return
+		}
+		pterm.Success.Printfln("Database migrated to version %s (backup saved into %q)", database.CurrentVersion, backup)
+	},
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/creativeprojects/catalogue/constants"
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/creativeprojects/catalogue/platform"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+type DBRestoreFlags struct {
+	Force bool
+}
+
+var dbRestoreFlags DBRestoreFlags
+
+func init() {
+	dbRestoreCmd.Flags().BoolVar(&dbRestoreFlags.Force, "force", false, "replace the database even if the backup was taken from a different database")
+	dbCmd.AddCommand(dbRestoreCmd)
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Restore the database from a backup",
+	Long: "Replace the database file with a backup. The database ID and version of the backup are checked first: " +
+		"the backup must come from the same database, unless --force is used.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backup := args[0]
+		if rootDSN.Backend != backendBolt {
+			pterm.Error.Printfln("Restoring a backup is only available with the %q backend", backendBolt)
+			return
+		}
+		if !fileExists(backup) {
+			pterm.Error.Printf("Backup %q not found\n", backup)
+			return
+		}
+
+		backupStats, err := readBackupStats(backup)
+		if err != nil {
+			pterm.Error.Printfln("Invalid backup: %v", err)
+			return
+		}
+
+		if fileExists(rootDSN.Path) {
+			// the write lock is held until the backup has replaced the file, so no other process can open the database in between.
+			// A process already waiting for the lock still gets the replaced file once the lock is released.
+			current, err := openStore()
+			if err != nil {
+				pterm.Error.Printf("Cannot open database: %v\n", err)
+				return
+			}
+			release := sync.OnceFunc(current.Close)
+			defer release()
+			stats, err := database.NewDatabase(current).Stats()
+			if err != nil && !dbRestoreFlags.Force {
+				pterm.Error.Printfln("Cannot read the current database: %v. Use --force to replace it anyway", err)
+				return
+			}
+
+			if stats.DatabaseID != backupStats.DatabaseID && !dbRestoreFlags.Force {
+				pterm.Error.Printfln("The backup was taken from database %s but the current database is %s: use --force to replace it anyway",
+					backupStats.DatabaseID.String(), stats.DatabaseID.String())
+				return
+			}
+			if platform.IsWindows() {
+				// an open file cannot be replaced on Windows: the lock is released just before the rename
+				release()
+			}
+		}
+
+		err = writeFileAtomic(rootDSN.Path, func(w io.Writer) error {
+			file, err := os.Open(backup)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(w, file)
+			return err
+		})
+		if err != nil {
+			pterm.Error.Printfln("Cannot restore database: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Database %s restored from %q", backupStats.DatabaseID.String(), backup)
+	},
+}
+
+// readBackupStats loads the statistics of the backup, and checks it's a catalogue database that can be opened
+func readBackupStats(backup string) (database.Stats, error) {
+	// the backups are only available with the bolt backend
+	storage, err := openStoreFile(DSN{Backend: backendBolt, Path: backup}, true)
+	if err != nil {
+		return database.Stats{}, err
+	}
+	defer storage.Close()
+
+	stats, err := database.NewDatabase(storage).Stats()
+	if err != nil {
+		return stats, fmt.Errorf("this is not a valid catalogue database: %w", err)
+	}
+	if stats.Version.Major > database.CurrentVersion.Major {
+		return stats, fmt.Errorf("the backup was created by a newer version of %s (database version %d.%d)",
+			constants.Name, stats.Version.Major, stats.Version.Minor)
+	}
+	return stats, nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/pterm/pterm"
+
+	"github.com/spf13/cobra"
+)
+
+type DBStatsFlags struct {
+	Storage bool
+}
+
+var dbStatsFlags DBStatsFlags
+
+func init() {
+	dbStatsCmd.Flags().BoolVar(&dbStatsFlags.Storage, "storage", false, "display the storage used by every nested bucket")
+	dbCmd.AddCommand(dbStatsCmd)
+}
+
+var dbStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Storage statistics",
+	Long:  "Display the number of keys and the space used by each bucket of the database file.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+		size, err := databaseSize(rootDSN.Path)
+		if err != nil {
+			pterm.Error.Printfln("Cannot read database size: %v", err)
+			return
+		}
+
+		storage, err := openStoreReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer storage.Close()
+
+		var buckets []bucketStorage
+		err = storage.View(func(transaction store.Transaction) error {
+			buckets, err = readBucketStorage(transaction, dbStatsFlags.Storage)
+			return err
+		})
+		if err != nil {
+			pterm.Error.Printfln("Cannot read storage statistics: %v", err)
+			return
+		}
+
+		fmt.Println("")
+		fmt.Printf(" Database file:  %s (%d bytes)\n", rootDSN.Path, size)
+		fmt.Println("")
+		fmt.Printf(" %-50s %10s %8s %12s %12s %8s\n", "Bucket", "Keys", "Buckets", "In use", "Allocated", "Pages")
+		for _, bucket := range buckets {
+			name := strings.Repeat("  ", len(bucket.path)-1) + bucket.path[len(bucket.path)-1]
+			fmt.Printf(" %-50s %10d %8d %12d %12d %8d\n",
+				name, bucket.stats.Keys, bucket.stats.Buckets, bucket.stats.InUse, bucket.stats.Allocated, bucket.stats.Pages)
+		}
+		fmt.Println("")
+	},
+}
+
+// bucketStorage is the storage used by the bucket at the path
+type bucketStorage struct {
+	path  []string
+	stats store.BucketStats
+}
+
+// readBucketStorage returns the storage used by the top level buckets, and all the nested buckets when nested is true
+func readBucketStorage(transaction store.Transaction, nested bool) ([]bucketStorage, error) {
+	buckets := make([]bucketStorage, 0)
+	var walk func(parent store.Bucketeer, path []string) error
+	walk = func(parent store.Bucketeer, path []string) error {
+		return parent.ForEachBucket(func(name string) error {
+			bucket, err := parent.GetBucket(name)
+			if err != nil {
+				return err
+			}
+			bucketPath := append(slices.Clone(path), name)
+			buckets = append(buckets, bucketStorage{path: bucketPath, stats: bucket.Stats()})
+			if !nested {
+				return nil
+			}
+			return walk(bucket, bucketPath)
+		})
+	}
+	err := walk(transaction, nil)
+	return buckets, err
+}
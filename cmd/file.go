@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a temporary file next to the destination, and moves it to the destination once complete.
+// The destination is never left half written.
+func writeFileAtomic(destination string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(destination), filepath.Base(destination)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temporary := file.Name()
+	defer os.Remove(temporary)
+
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary, destination)
+}
+
+// fileExists returns true if the file exists
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// databaseSize returns the size of the database file, or the total size of the files when the database is a directory
+func databaseSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// sameFile returns true when both paths point to the same existing file
+func sameFile(path1, path2 string) bool {
+	info1, err := os.Stat(path1)
+	if err != nil {
+		return false
+	}
+	info2, err := os.Stat(path2)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info1, info2)
+}
+
+// copyPath copies the file, or all the files of the directory, to the destination.
+// The files are copied atomically, but a directory can be left partially copied on error.
+func copyPath(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(source, destination)
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(destination, 0o700); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = copyPath(filepath.Join(source, entry.Name()), filepath.Join(destination, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies the content of the file to the destination
+func copyFile(source, destination string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeFileAtomic(destination, func(w io.Writer) error {
+		_, err := io.Copy(w, file)
+		return err
+	})
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 
+	"github.com/creativeprojects/catalogue/constants"
 	"github.com/creativeprojects/catalogue/database"
 	"github.com/creativeprojects/catalogue/store"
 	"github.com/pterm/pterm"
@@ -10,7 +11,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
+type InitFlags struct {
+	Encrypt  bool
+	HashKeys bool
+	Compress bool
+	Sharded  bool
+}
+
+var initFlags InitFlags
+
 func init() {
+	initCmd.Flags().BoolVar(&initFlags.Encrypt, "encrypt", false, "encrypt the database with the passphrase given by --passphrase-file or the "+constants.EnvPassphrase+" environment variable")
+	initCmd.Flags().BoolVar(&initFlags.HashKeys, "hash-keys", false, "also hide the file names used as keys (slower listings and searches)")
+	initCmd.Flags().BoolVar(&initFlags.Compress, "compress", false, "compress the values saved in the database")
+	initCmd.Flags().BoolVar(&initFlags.Sharded, "sharded", false, "save the files of each volume in its own shard file, in a directory next to the database")
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -19,19 +33,39 @@ var initCmd = &cobra.Command{
 	Short: "Initializes a new database",
 	Long:  "Initializes a new empty database. The command will fail if a database file already exists.",
 	Run: func(cmd *cobra.Command, args []string) {
-		if _, err := os.Stat(rootFlags.Database); err == nil || os.IsExist(err) {
-			pterm.Error.Printf("Cannot initialize new database: file %q already exists\n", rootFlags.Database)
+		if _, err := os.Stat(rootDSN.Path); err == nil || os.IsExist(err) {
+			pterm.Error.Printf("Cannot initialize new database: file %q already exists\n", rootDSN.Path)
 			return
 		}
 
-		store, err := store.NewBoltStore(rootFlags.Database)
+		var encryption *store.EncryptionOptions
+		if initFlags.Encrypt {
+			options := store.DefaultEncryptionOptions()
+			options.HashKeys = initFlags.HashKeys
+			encryption = &options
+		}
+		var compression *store.CompressionOptions
+		if initFlags.Compress {
+			options := store.DefaultCompressionOptions()
+			compression = &options
+		}
+
+		storage, err := createStoreFile(rootDSN, encryption, compression)
 		if err != nil {
-			pterm.Error.Printf("Cannot open database: %v\n", err)
+			pterm.Error.Printf("Cannot initialize new database: %v\n", err)
 			return
 		}
-		defer store.Close()
+		defer storage.Close()
 
-		db := database.NewDatabase(store)
-		db.Init()
+		db := database.NewDatabase(storage)
+		if initFlags.Sharded {
+			err = db.InitSharded()
+		} else {
+			err = db.Init()
+		}
+		if err != nil {
+			pterm.Error.Printf("Cannot initialize new database: %v\n", err)
+			return
+		}
 	},
 }
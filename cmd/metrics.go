@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// storeMetrics is an instrumented store opened by the command, with the location displayed in front of its metrics
+type storeMetrics struct {
+	location string
+	store    *store.InstrumentedStore
+}
+
+var (
+	metricsMutex sync.Mutex
+	// instrumentedStores is the list of the stores opened by the command when the metrics are enabled
+	instrumentedStores []storeMetrics
+)
+
+// instrumentStore measures the activity of the storage when the metrics are enabled on the command line.
+// The metrics are displayed at the end of the command.
+func instrumentStore(location string, storage store.Store) store.Store {
+	if !rootFlags.Metrics {
+		return storage
+	}
+	instrumented := store.NewInstrumentedStore(storage)
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	instrumentedStores = append(instrumentedStores, storeMetrics{location: location, store: instrumented})
+	return instrumented
+}
+
+// printAllMetrics displays the activity of each store opened by the command
+func printAllMetrics() {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	for _, instrumented := range instrumentedStores {
+		fmt.Println("")
+		fmt.Printf(" Metrics of %s\n", instrumented.location)
+		fmt.Println("")
+		printMetrics(instrumented.store.Metrics())
+	}
+}
+
+// printMetrics displays the activity of the store
+func printMetrics(metrics store.Metrics) {
+	fmt.Printf("  Read transactions:  %d in %s\n", metrics.ReadTransactions, metrics.ReadTime)
+	fmt.Printf(" Write transactions:  %d in %s (longest %s, %d rolled back)\n",
+		metrics.WriteTransactions, metrics.WriteTime, metrics.MaxWriteTime, metrics.Rollbacks)
+	fmt.Printf("          Lock wait:  %s\n", metrics.LockWait)
+	if len(metrics.Buckets) == 0 {
+		fmt.Println("")
+		return
+	}
+	fmt.Println("")
+	fmt.Printf(" %-50s %10s %10s %10s %12s %12s\n", "Bucket", "Reads", "Writes", "Deletes", "Bytes read", "Bytes written")
+	paths := make([]string, 0, len(metrics.Buckets))
+	for path := range metrics.Buckets {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		bucket := metrics.Buckets[path]
+		fmt.Printf(" %-50s %10d %10d %10d %12d %12d\n",
+			path, bucket.Reads, bucket.Writes, bucket.Deletes, bucket.BytesRead, bucket.BytesWritten)
+	}
+	fmt.Println("")
+}
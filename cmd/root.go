@@ -3,15 +3,24 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/creativeprojects/catalogue/constants"
+	"github.com/creativeprojects/catalogue/store"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 type RootFlags struct {
-	Verbose  bool
-	Database string
+	Verbose        bool
+	Database       string
+	PassphraseFile string
+	LockTimeout    time.Duration
+	Backend        string
+	Metrics        bool
 }
 
 var (
@@ -19,6 +28,19 @@ var (
 		Use:   constants.Catalogue,
 		Short: constants.Description,
 		Long:  `An offline file catalogue with fast search`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			rootDSN, err = parseDSN(rootFlags.Database, rootFlags.Backend)
+			if err != nil {
+				// the error is displayed by Execute
+				cmd.SilenceUsage = true
+				cmd.SilenceErrors = true
+			}
+			return err
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			printAllMetrics()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if rootFlags.Verbose {
 				pterm.EnableDebugMessages()
@@ -27,11 +49,55 @@ var (
 	}
 
 	rootFlags RootFlags
+	// rootDSN is the location of the database given on the command line
+	rootDSN DSN
+
+	// dsnScheme matches the backend in front of a path: a single letter is a Windows drive, not a backend
+	dsnScheme = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9]+):(//)?`)
 )
 
+// DSN is the location of a database: the storage backend and the path of the database file or directory.
+// It's written "backend:path" or "backend://path" on the command line.
+type DSN struct {
+	Backend string
+	Path    string
+}
+
+// String returns the location as "backend:path"
+func (d DSN) String() string {
+	return d.Backend + ":" + d.Path
+}
+
+// withPath returns the location of another database using the same backend
+func (d DSN) withPath(path string) DSN {
+	return DSN{Backend: d.Backend, Path: path}
+}
+
+// parseDSN returns the location of a database. A path without a backend uses the default backend.
+func parseDSN(value, defaultBackend string) (DSN, error) {
+	match := dsnScheme.FindStringSubmatch(value)
+	if match == nil {
+		return DSN{Backend: defaultBackend, Path: value}, nil
+	}
+	backend := strings.ToLower(match[1])
+	if !slices.Contains(backends, backend) {
+		return DSN{}, fmt.Errorf("unknown storage backend %q in %q: please use one of %s", backend, value, strings.Join(backends, ", "))
+	}
+	path := value[len(match[0]):]
+	if path == "" {
+		return DSN{}, fmt.Errorf("missing database path in %q", value)
+	}
+	return DSN{Backend: backend, Path: path}, nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&rootFlags.Verbose, "verbose", "v", false, "verbose output")
-	rootCmd.PersistentFlags().StringVarP(&rootFlags.Database, "database", "d", "catalogue.db", "database file")
+	rootCmd.PersistentFlags().StringVarP(&rootFlags.Database, "database", "d", "catalogue.db", "database location: a file path, or \"backend:path\" with the \""+backendBolt+"\" backend for a single database file or the \""+backendLog+"\" backend for a directory of append-only log files")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.PassphraseFile, "passphrase-file", "", "file containing the passphrase of an encrypted database (or use the "+constants.EnvPassphrase+" environment variable)")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.Backend, "backend", backendBolt, "storage backend of a database location without backend")
+	_ = rootCmd.PersistentFlags().MarkDeprecated("backend", "please add the backend in front of the database path instead, like --database "+backendLog+":catalogue.log")
+	rootCmd.PersistentFlags().DurationVar(&rootFlags.LockTimeout, "lock-timeout", store.DefaultLockTimeout, "time to wait for another process to release the database file (0 to wait forever)")
+	rootCmd.PersistentFlags().BoolVar(&rootFlags.Metrics, "metrics", false, "display the transactions and the activity of each bucket of the database and its shards at the end of the command")
 }
 
 func Execute() {
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/creativeprojects/catalogue/query"
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/google/uuid"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+type SearchFlags struct {
+	Limit    int
+	Query    bool
+	Fuzzy    bool
+	Regex    string
+	Glob     string
+	Modified bool
+}
+
+var searchFlags SearchFlags
+
+// errSearchLimit stops the search once enough results are displayed
+var errSearchLimit = errors.New("search limit reached")
+
+func init() {
+	searchCmd.Flags().IntVar(&searchFlags.Limit, "limit", 100, "maximum number of results displayed (0 for no limit)")
+	searchCmd.Flags().BoolVar(&searchFlags.Query, "query", false, "the text is a query like 'name:*.cr2 size:>20M modified:2015 volume:\"Photos*\" location:office type:file'")
+	searchCmd.Flags().BoolVar(&searchFlags.Fuzzy, "fuzzy", false, "tolerate typos in the words of the text, and display the most relevant results first")
+	searchCmd.Flags().StringVar(&searchFlags.Regex, "regex", "", "find the paths matching the regular expression instead of a text")
+	searchCmd.Flags().StringVar(&searchFlags.Glob, "glob", "", "find the paths matching the glob pattern instead of a text, like '**/*.{psd,ai}'")
+	searchCmd.Flags().BoolVar(&searchFlags.Modified, "modified", false, "display the modification date of each result")
+	searchCmd.MarkFlagsMutuallyExclusive("query", "fuzzy", "regex", "glob")
+	rootCmd.AddCommand(searchCmd)
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search <text> | --regex <expression> | --glob <pattern>",
+	Short: "Search files by name",
+	Long: "Find the files and directories containing the text in their name or path, ignoring case, across all the volumes of the catalogue.\n" +
+		"Each result shows the volume, whether the volume is currently mounted, and the path of the file in the volume.\n" +
+		"The volumes excluded from search are skipped: use --query with an included:no term to search them.\n\n" +
+		"With --query, the text is a list of terms which must all match:\n" +
+		"  name:<pattern>      name of the file, with * and ? wildcards\n" +
+		"  path:<pattern>      path of the file in the volume\n" +
+		"  size:[op]<size>     size with a K, M, G or T unit, and an optional operator >, >=, < or <=\n" +
+		"  modified:[op]<date> modification date: YYYY, YYYY-MM or YYYY-MM-DD\n" +
+		"  type:file|dir       files or directories\n" +
+		"  volume:<pattern>    name of the volume\n" +
+		"  location:<pattern>  location of the volume\n" +
+		"  included:yes|no     volumes included in search: only those are searched unless the query says otherwise\n" +
+		"A text without a field, or with an unknown field name before a colon like http:foo, is searched in the path. Terms can be combined with AND, OR and NOT, and grouped with parentheses.\n" +
+		"A pattern without wildcards matches any part of the text. Texts are compared ignoring case.\n\n" +
+		"With --fuzzy, each word of the text must be found in the path with a few typos at most. " +
+		"The results are ranked by score: whole words in the file name rank first, then the beginning of words, words with typos, " +
+		"and words found in the directories. The files at a lower depth rank higher.\n\n" +
+		"With --regex, the regular expression is searched in the whole path: use ^ to match from the beginning. " +
+		"With --glob, the pattern must match the whole path: * and ? don't match a slash, ** matches any number of directories, " +
+		"and {a,b} matches one of the alternatives. Both compare the paths with case. " +
+		"The text at the beginning of an expression starting with ^, or of a glob pattern, only reads the paths starting with this text.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if searchFlags.Regex != "" || searchFlags.Glob != "" {
+			if len(args) > 0 {
+				return errors.New("a text cannot be used with --regex or --glob")
+			}
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabaseReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		text := strings.Join(args, " ")
+		search := func(fn func(result database.SearchResult) error) error {
+			return db.Search(text, fn)
+		}
+		if searchFlags.Regex != "" || searchFlags.Glob != "" {
+			var pattern *query.Pattern
+			if searchFlags.Regex != "" {
+				pattern, err = query.CompileRegex(searchFlags.Regex)
+			} else {
+				pattern, err = query.CompileGlob(searchFlags.Glob)
+			}
+			if err != nil {
+				pterm.Error.Printfln("%v", err)
+				return
+			}
+			text = pattern.String()
+			search = func(fn func(result database.SearchResult) error) error {
+				return db.SearchPattern(pattern, fn)
+			}
+		}
+		if searchFlags.Query {
+			expr, err := query.Parse(text)
+			if err != nil {
+				pterm.Error.Printfln("Invalid query: %v", err)
+				return
+			}
+			search = func(fn func(result database.SearchResult) error) error {
+				return db.Query(expr, fn)
+			}
+		}
+
+		printer := &searchPrinter{
+			mounted:  make(map[uuid.UUID]string),
+			scores:   searchFlags.Fuzzy,
+			modified: searchFlags.Modified,
+		}
+		fmt.Println("")
+		if searchFlags.Fuzzy {
+			err = fuzzySearch(db, text, printer)
+		} else {
+			err = search(func(result database.SearchResult) error {
+				if searchFlags.Limit > 0 && printer.count >= searchFlags.Limit {
+					return errSearchLimit
+				}
+				printer.print(result, 0)
+				return nil
+			})
+		}
+		fmt.Println("")
+		if errors.Is(err, errSearchLimit) {
+			pterm.Info.Printfln("Only the first %d results are displayed", searchFlags.Limit)
+			return
+		}
+		if err != nil {
+			pterm.Error.Printfln("Cannot search %q: %v", text, err)
+			return
+		}
+		if printer.count == 0 {
+			pterm.Info.Printfln("No file found matching %q", text)
+		}
+	},
+}
+
+// fuzzySearch prints the most relevant results of a fuzzy search.
+// It returns errSearchLimit when some results are not displayed.
+func fuzzySearch(db *database.Database, text string, printer *searchPrinter) error {
+	search, err := query.NewFuzzy(text)
+	if err != nil {
+		return err
+	}
+	limit := searchFlags.Limit
+	if limit > 0 {
+		// one more to know if some results are left out
+		limit++
+	}
+	results, err := db.FuzzySearch(search, limit)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		if searchFlags.Limit > 0 && i >= searchFlags.Limit {
+			return errSearchLimit
+		}
+		printer.print(result.SearchResult, result.Score)
+	}
+	return nil
+}
+
+// searchPrinter displays the results of a search, checking once if each volume is mounted
+type searchPrinter struct {
+	mounted  map[uuid.UUID]string
+	scores   bool
+	modified bool
+	count    int
+}
+
+func (p *searchPrinter) print(result database.SearchResult, score int) {
+	if p.count == 0 {
+		if p.scores {
+			fmt.Printf(" %5s", "Score")
+		}
+		fmt.Printf(" %-24s %-7s %10s", "Volume", "Mounted", "Size")
+		if p.modified {
+			fmt.Printf("  %-19s", "Modified")
+		}
+		fmt.Printf("  %s\n", "Path")
+	}
+	p.count++
+	state, found := p.mounted[result.Volume.ID]
+	if !found {
+		state = "no"
+		if volume.IsMounted(&result.Volume.Volume) {
+			state = "yes"
+		}
+		p.mounted[result.Volume.ID] = state
+	}
+	size := ""
+	if !result.Entry.Mode.IsDir() {
+		size = volume.FormatBytes(uint64(result.Entry.Size))
+	}
+	if p.scores {
+		fmt.Printf(" %5d", score)
+	}
+	fmt.Printf(" %-24s %-7s %10s", result.Volume.Volume.Name, state, size)
+	if p.modified {
+		fmt.Printf("  %-19s", result.Entry.ModTime.Local().Format(time.DateTime))
+	}
+	fmt.Printf("  %s\n", result.Path)
+}
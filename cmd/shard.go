@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(shardCmd)
+}
+
+var shardCmd = &cobra.Command{
+	Use:   "shard",
+	Short: "Shards management",
+	Long: "Attach, detach, copy or delete the shard files of a sharded database. " +
+		"Each shard holds the files of one volume, and is saved next to the database in the directory \"<database>.shards\".",
+}
+
+// shardVolumeID returns the volume ID from the name of a shard file: the ID followed by the shard extension
+func shardVolumeID(filename string) (uuid.UUID, error) {
+	name := strings.TrimSuffix(filepath.Base(filename), database.ShardExtension)
+	volumeID, err := uuid.Parse(name)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("the name of a shard file should be the volume ID followed by %q: %w", database.ShardExtension, err)
+	}
+	return volumeID, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shardCmd.AddCommand(shardAttachCmd)
+}
+
+var shardAttachCmd = &cobra.Command{
+	Use:   "attach <shard file>",
+	Short: "Add the volume saved in a shard file",
+	Long: "Add the volume saved in a shard file to the database. The shard file is copied into the shard directory " +
+		"when it's not already there, and its name must be the volume ID followed by \".shard\".",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		source := args[0]
+		volumeID, err := shardVolumeID(source)
+		if err != nil {
+			pterm.Error.Printfln("Cannot attach shard: %v", err)
+			return
+		}
+		if !fileExists(source) {
+			pterm.Error.Printf("Shard %q not found\n", source)
+			return
+		}
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		destination := filepath.Join(shardsDir(rootDSN.Path), filepath.Base(source))
+		copied := false
+		if !sameFile(source, destination) {
+			if fileExists(destination) {
+				pterm.Error.Printf("Cannot attach shard: file %q already exists\n", destination)
+				return
+			}
+			err = os.MkdirAll(shardsDir(rootDSN.Path), 0o700)
+			if err == nil {
+				err = copyPath(source, destination)
+			}
+			if err != nil {
+				pterm.Error.Printfln("Cannot copy shard into %q: %v", destination, err)
+				return
+			}
+			copied = true
+		}
+
+		err = db.AttachShard(volumeID)
+		if err != nil {
+			if copied {
+				_ = os.RemoveAll(destination)
+			}
+			pterm.Error.Printfln("Cannot attach shard: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Volume %s attached", volumeID)
+	},
+}
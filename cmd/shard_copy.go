@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shardCmd.AddCommand(shardCopyCmd)
+}
+
+var shardCopyCmd = &cobra.Command{
+	Use:   "copy <volume ID> <destination>",
+	Short: "Copy the shard file of a volume",
+	Long: "Copy the shard of a volume into a new file, from a consistent snapshot. " +
+		"Name the copy after the volume ID followed by \".shard\" to attach it to another database.\n" +
+		"An encrypted shard stays encrypted in the copy.\n" +
+		"With the log backend, the copy is a single compacted log file.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		volumeID, err := uuid.Parse(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Invalid volume ID %q: %v", args[0], err)
+			return
+		}
+		destination := args[1]
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+		if fileExists(destination) {
+			pterm.Error.Printf("Cannot copy shard: file %q already exists\n", destination)
+			return
+		}
+
+		db, err := openDatabaseReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		var size int64
+		err = writeFileAtomic(destination, func(w io.Writer) error {
+			size, err = db.CopyShard(volumeID, w)
+			return err
+		})
+		if err != nil {
+			pterm.Error.Printfln("Cannot copy shard: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Shard of volume %s saved into %q (%d bytes)", volumeID, destination, size)
+	},
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/google/uuid"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shardCmd.AddCommand(shardDeleteCmd)
+}
+
+var shardDeleteCmd = &cobra.Command{
+	Use:   "delete <volume ID>",
+	Short: "Remove a volume and delete its shard file",
+	Long:  "Remove the volume from the database, and delete its shard file.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		volumeID, err := uuid.Parse(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Invalid volume ID %q: %v", args[0], err)
+			return
+		}
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		err = db.DeleteShard(volumeID)
+		if err != nil {
+			pterm.Error.Printfln("Cannot delete shard: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Volume %s deleted", volumeID)
+	},
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/google/uuid"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shardCmd.AddCommand(shardDetachCmd)
+}
+
+var shardDetachCmd = &cobra.Command{
+	Use:   "detach <volume ID>",
+	Short: "Remove a volume but keep its shard file",
+	Long:  "Remove the volume from the database, but leave its shard file in the shard directory so it can be attached again later.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		volumeID, err := uuid.Parse(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Invalid volume ID %q: %v", args[0], err)
+			return
+		}
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		err = db.DetachShard(volumeID)
+		if err != nil {
+			pterm.Error.Printfln("Cannot detach shard: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Volume %s detached: its shard is still in %q", volumeID, shardsDir(rootDSN.Path))
+	},
+}
@@ -5,8 +5,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/creativeprojects/catalogue/database"
-	"github.com/creativeprojects/catalogue/store"
 	"github.com/pterm/pterm"
 
 	"github.com/spf13/cobra"
@@ -22,22 +20,25 @@ var statsCmd = &cobra.Command{
 	Long:  "Display some simple database statistics.",
 	Run: func(cmd *cobra.Command, args []string) {
 
-		if _, err := os.Stat(rootFlags.Database); os.IsNotExist(err) {
-			pterm.Error.Printf("Database %q not found\n", rootFlags.Database)
+		if _, err := os.Stat(rootDSN.Path); os.IsNotExist(err) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
 			return
 		}
 
-		store, err := store.NewBoltStore(rootFlags.Database)
+		db, err := openDatabaseReadOnly()
 		if err != nil {
 			pterm.Error.Printf("Cannot open database: %v\n", err)
 			return
 		}
-		defer store.Close()
+		defer db.Close()
 
-		db := database.NewDatabase(store)
-		stats := db.Stats()
+		stats, err := db.Stats()
+		if err != nil {
+			pterm.Error.Printf("Cannot read database statistics: %v\n", err)
+			return
+		}
 		fmt.Println("")
-		fmt.Printf("     Database file:  %s\n", rootFlags.Database)
+		fmt.Printf("     Database file:  %s\n", rootDSN.Path)
 		fmt.Printf("                ID:  %s\n", stats.DatabaseID.String())
 		fmt.Printf("           Version:  %d.%d\n", stats.Version.Major, stats.Version.Minor)
 		fmt.Printf("           Created:  %s\n", stats.Created.Format(time.DateTime))
@@ -45,6 +46,9 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("     Total volumes:  %d\n", stats.TotalVolumes)
 		fmt.Printf(" Total directories:  %d\n", stats.TotalDirectories)
 		fmt.Printf("       Total files:  %d\n", stats.TotalFiles)
+		if stats.Sharded {
+			fmt.Printf("            Shards:  %s\n", shardsDir(rootDSN.Path))
+		}
 		fmt.Println("")
 	},
 }
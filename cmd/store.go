@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/constants"
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// Storage backends selected in front of the database path
+const (
+	backendBolt = "bolt"
+	backendLog  = "log"
+)
+
+// backends is the list of the storage backends
+var backends = []string{backendBolt, backendLog}
+
+// openStore opens the database file given on the command line for writing
+func openStore() (store.Store, error) {
+	return openStoreFile(rootDSN, false)
+}
+
+// openStoreReadOnly opens the database file given on the command line in read-only mode:
+// it can be used by many commands at the same time, and it can be saved on a read-only medium
+func openStoreReadOnly() (store.Store, error) {
+	return openStoreFile(rootDSN, true)
+}
+
+// openStoreFile opens the database, with the decryption and the decompression layers when needed
+func openStoreFile(location DSN, readOnly bool) (store.Store, error) {
+	storage, err := openBackend(location, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := store.IsEncrypted(storage)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+	if encrypted {
+		passphrase, err := getPassphrase()
+		if err != nil {
+			storage.Close()
+			return nil, fmt.Errorf("the database is encrypted, %w", err)
+		}
+		encryptedStore, err := store.OpenEncryption(storage, passphrase)
+		if err != nil {
+			storage.Close()
+			return nil, err
+		}
+		storage = encryptedStore
+	}
+
+	// the compression header is saved through the encryption layer
+	compressed, err := store.IsCompressed(storage)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+	if compressed {
+		compressedStore, err := store.OpenCompression(storage)
+		if err != nil {
+			storage.Close()
+			return nil, err
+		}
+		storage = compressedStore
+	}
+	return storage, nil
+}
+
+// openBackend opens the database with its storage backend, without any decryption or decompression layer
+func openBackend(location DSN, readOnly bool) (store.Store, error) {
+	switch location.Backend {
+	case backendBolt:
+		boltStore, err := store.NewBoltStoreWithOptions(location.Path, store.BoltOptions{
+			ReadOnly:    readOnly,
+			LockTimeout: rootFlags.LockTimeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return boltStore, nil
+	case backendLog:
+		options := store.DefaultLogOptions()
+		options.ReadOnly = readOnly
+		options.LockTimeout = rootFlags.LockTimeout
+		logStore, err := store.NewLogStoreWithOptions(location.Path, options)
+		if err != nil {
+			return nil, err
+		}
+		return logStore, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q: please use one of %s", location.Backend, strings.Join(backends, ", "))
+	}
+}
+
+// createStoreFile creates a new database, adding the encryption and the compression layers
+// when their options are not nil. The database is removed if any layer cannot be initialized.
+func createStoreFile(location DSN, encryption *store.EncryptionOptions, compression *store.CompressionOptions) (store.Store, error) {
+	var passphrase []byte
+	if encryption != nil {
+		var err error
+		passphrase, err = getPassphrase()
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt database: %w", err)
+		}
+	}
+
+	backend, err := openBackend(location, false)
+	if err != nil {
+		return nil, err
+	}
+	storage := backend
+	failed := func(err error) (store.Store, error) {
+		backend.Close()
+		// the log backend is a directory
+		_ = os.RemoveAll(location.Path)
+		return nil, err
+	}
+
+	if encryption != nil {
+		encryptedStore, err := store.InitEncryption(storage, passphrase, *encryption)
+		if err != nil {
+			return failed(fmt.Errorf("cannot encrypt database: %w", err))
+		}
+		storage = encryptedStore
+	}
+	if compression != nil {
+		compressedStore, err := store.InitCompression(storage, *compression)
+		if err != nil {
+			return failed(fmt.Errorf("cannot compress database: %w", err))
+		}
+		storage = compressedStore
+	}
+	return storage, nil
+}
+
+// getEncryptionOptions returns the options of the encryption layer of the store, or nil if the store is not encrypted
+func getEncryptionOptions(storage store.Store) (*store.EncryptionOptions, error) {
+	for {
+		transformStore, ok := storage.(*store.TransformStore)
+		if !ok {
+			break
+		}
+		storage = transformStore.Unwrap()
+	}
+	encrypted, err := store.IsEncrypted(storage)
+	if err != nil || !encrypted {
+		return nil, err
+	}
+	options, err := store.GetEncryptionOptions(storage)
+	if err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// getCompressionOptions returns the options of the compression layer of the store, or nil if the store is not compressed
+func getCompressionOptions(storage store.Store) (*store.CompressionOptions, error) {
+	for {
+		// the compression header is only visible from the layer below the compression
+		compressed, err := store.IsCompressed(storage)
+		if err != nil {
+			return nil, err
+		}
+		if compressed {
+			options, err := store.GetCompressionOptions(storage)
+			if err != nil {
+				return nil, err
+			}
+			return &options, nil
+		}
+		transformStore, ok := storage.(*store.TransformStore)
+		if !ok {
+			return nil, nil
+		}
+		storage = transformStore.Unwrap()
+	}
+}
+
+// getPassphrase loads the passphrase from the file in parameter, or from the environment
+func getPassphrase() ([]byte, error) {
+	if rootFlags.PassphraseFile != "" {
+		passphrase, err := os.ReadFile(rootFlags.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read passphrase file: %w", err)
+		}
+		return bytes.TrimRight(passphrase, "\r\n"), nil
+	}
+	if passphrase := os.Getenv(constants.EnvPassphrase); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return nil, fmt.Errorf("missing passphrase: please specify a passphrase file with --passphrase-file or set the %s environment variable", constants.EnvPassphrase)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/creativeprojects/catalogue/database"
+	"github.com/google/uuid"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+type TopFlags struct {
+	By    string
+	Limit int
+}
+
+var topFlags TopFlags
+
+func init() {
+	topCmd.Flags().StringVar(&topFlags.By, "by", "size", "order of the files: size or mtime")
+	topCmd.Flags().IntVar(&topFlags.Limit, "limit", 50, "number of files displayed")
+	rootCmd.AddCommand(topCmd)
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Display the largest or the most recently modified files",
+	Long: "Display the largest files (--by size) or the most recently modified files (--by mtime) across all the volumes of the catalogue.\n" +
+		"The files are read from the secondary indexes of each volume: a volume indexed with an older version is sorted in memory instead.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var order database.FileOrder
+		switch topFlags.By {
+		case "size":
+			order = database.BySize
+		case "mtime":
+			order = database.ByModTime
+		default:
+			pterm.Error.Printfln("Invalid order %q: use size or mtime", topFlags.By)
+			return
+		}
+		if topFlags.Limit < 1 {
+			pterm.Error.Printfln("Invalid limit %d", topFlags.Limit)
+			return
+		}
+
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabaseReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		results, err := db.TopFiles(order, topFlags.Limit)
+		if err != nil {
+			pterm.Error.Printfln("Cannot read the files: %v", err)
+			return
+		}
+		if len(results) == 0 {
+			pterm.Info.Println("No file found")
+			return
+		}
+		printer := &searchPrinter{
+			mounted:  make(map[uuid.UUID]string),
+			modified: order == database.ByModTime,
+		}
+		fmt.Println("")
+		for _, result := range results {
+			printer.print(result, 0)
+		}
+		fmt.Println("")
+	},
+}
@@ -11,7 +11,7 @@ func init() {
 var volumeCmd = &cobra.Command{
 	Use:   "volume",
 	Short: "Volumes management",
-	Long:  "List, add or delete volumes",
+	Long:  "List, add, show, rename or remove the volumes of the catalogue",
 	Run: func(cmd *cobra.Command, args []string) {
 
 	},
@@ -10,11 +10,19 @@ import (
 
 	"github.com/creativeprojects/catalogue/index"
 	"github.com/creativeprojects/catalogue/volume"
+	"github.com/google/uuid"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
+type VolumeAddFlags struct {
+	Location string
+}
+
+var volumeAddFlags VolumeAddFlags
+
 func init() {
+	volumeAddCmd.Flags().StringVar(&volumeAddFlags.Location, "location", "", "physical location of the removable drive, used by search queries")
 	volumeCmd.AddCommand(volumeAddCmd)
 }
 
@@ -37,6 +45,18 @@ var volumeAddCmd = &cobra.Command{
 			return
 		}
 
+		if _, err := os.Stat(rootDSN.Path); os.IsNotExist(err) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
 
@@ -45,11 +65,13 @@ var volumeAddCmd = &cobra.Command{
 			pterm.Error.Println("Cannot get volume information:", err)
 			return
 		}
+		vol.Location = volumeAddFlags.Location
 		volume.PrintVolume(vol)
 		fmt.Println("")
 
 		wg := new(sync.WaitGroup)
 		fileIndexedChannel := make(chan index.FileIndexed, 1000)
+		saveChannel := make(chan index.FileIndexed, 1000)
 		start := time.Now()
 		progresser := index.NewProgress()
 		progresser.Start()
@@ -57,6 +79,7 @@ var volumeAddCmd = &cobra.Command{
 		wg.Add(1)
 		go func(progresser index.Progresser) {
 			defer wg.Done()
+			defer close(saveChannel)
 
 			for fileIndexed := range fileIndexedChannel {
 				if fileIndexed.Error != nil {
@@ -64,19 +87,33 @@ var volumeAddCmd = &cobra.Command{
 					continue
 				}
 				progresser.Increment(fileIndexed.Path, fileIndexed.Info)
+				saveChannel <- fileIndexed
 			}
 		}(progresser)
+
+		var volumeID uuid.UUID
+		var saveErr error
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			volumeID, saveErr = db.IndexVolume(ctx, vol, saveChannel)
+		}()
+
 		indexer := index.NewIndexer(vol, fileIndexedChannel)
 		err = indexer.Run(ctx)
 		close(fileIndexedChannel)
+		wg.Wait()
+		if err == nil {
+			err = saveErr
+		}
 		if err != nil {
 			progresser.Stop("")
 			pterm.Error.Println(err)
-		} else {
-			fileCount, _, _ := progresser.Stats()
-			progresser.Stop(fmt.Sprintf("Indexed %d files in %s", fileCount, time.Since(start).String()))
+			return
 		}
-		wg.Wait()
+		fileCount, _, _ := progresser.Stats()
+		progresser.Stop(fmt.Sprintf("Indexed %d files in %s", fileCount, time.Since(start).String()))
+		pterm.Info.Printfln("Volume saved with ID %s", volumeID.String())
 	},
 }
 
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	volumeCmd.AddCommand(volumeListCmd)
+}
+
+var volumeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the volumes of the catalogue",
+	Long:  "List all the volumes saved in the catalogue, sorted by name.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabaseReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		volumes, err := db.Volumes()
+		if err != nil {
+			pterm.Error.Printfln("Cannot read volumes: %v", err)
+			return
+		}
+		if len(volumes) == 0 {
+			pterm.Info.Println("No volume in the catalogue")
+			return
+		}
+
+		fmt.Println("")
+		fmt.Printf(" %-24s %-16s %-36s %-16s %10s %10s  %s\n", "Name", "Label", "ID", "Type", "Size", "Files", "Last indexed")
+		for _, record := range volumes {
+			vol := record.Volume
+			fmt.Printf(" %-24s %-16s %-36s %-16s %10s %10d  %s\n",
+				vol.Name, vol.Label, record.ID, vol.VolumeType.String(), volume.FormatBytes(vol.BytesTotal),
+				vol.RegularFiles+vol.HiddenFiles, vol.Indexed.Format(time.DateTime))
+		}
+		fmt.Println("")
+	},
+}
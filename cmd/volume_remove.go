@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	volumeCmd.AddCommand(volumeRemoveCmd)
+}
+
+var volumeRemoveCmd = &cobra.Command{
+	Use:   "remove <name|ID>",
+	Short: "Remove a volume from the catalogue",
+	Long:  "Delete a volume and all its files from the catalogue, and update the totals. The shard of a volume saved in a shard is deleted too.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		record, err := db.FindVolume(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Cannot find volume %q: %v", args[0], err)
+			return
+		}
+		err = db.RemoveVolume(record.ID)
+		if err != nil {
+			pterm.Error.Printfln("Cannot remove volume: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Volume %q removed (%s)", record.Volume.Name, record.ID)
+	},
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	volumeCmd.AddCommand(volumeRenameCmd)
+}
+
+var volumeRenameCmd = &cobra.Command{
+	Use:   "rename <name|ID> <new name>",
+	Short: "Rename a volume of the catalogue",
+	Long:  "Change the name of a volume in the catalogue. The label of the file system is kept, and the new name must not be used by another volume.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		record, err := db.FindVolume(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Cannot find volume %q: %v", args[0], err)
+			return
+		}
+		err = db.RenameVolume(record.ID, args[1])
+		if err != nil {
+			pterm.Error.Printfln("Cannot rename volume: %v", err)
+			return
+		}
+		pterm.Success.Printfln("Volume %s renamed from %q to %q", record.ID, record.Volume.Name, args[1])
+	},
+}
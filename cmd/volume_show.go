@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	volumeCmd.AddCommand(volumeShowCmd)
+}
+
+var volumeShowCmd = &cobra.Command{
+	Use:   "show <name|ID>",
+	Short: "View a volume of the catalogue",
+	Long:  "Display the information saved in the catalogue about a volume, from its name or its ID.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !fileExists(rootDSN.Path) {
+			pterm.Error.Printf("Database %q not found\n", rootDSN.Path)
+			return
+		}
+
+		db, err := openDatabaseReadOnly()
+		if err != nil {
+			pterm.Error.Printf("Cannot open database: %v\n", err)
+			return
+		}
+		defer db.Close()
+
+		record, err := db.FindVolume(args[0])
+		if err != nil {
+			pterm.Error.Printfln("Cannot find volume %q: %v", args[0], err)
+			return
+		}
+		fmt.Println("")
+		fmt.Printf("  Catalogue: %s\n", record.ID)
+		volume.PrintVolume(&record.Volume)
+		fmt.Printf("      Files: %d (%d hidden)\n", record.Volume.RegularFiles+record.Volume.HiddenFiles, record.Volume.HiddenFiles)
+		if record.Sharded {
+			fmt.Printf("      Shard: %s\n", shardsDir(rootDSN.Path))
+		}
+		fmt.Println("")
+	},
+}
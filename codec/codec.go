@@ -0,0 +1,23 @@
+// Package codec converts typed keys and values to and from the bytes saved in a store.
+package codec
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidLength = errors.New("Invalid data length")
+)
+
+// Codec encodes and decodes the values of type T
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// KeyCodec encodes and decodes the keys of type K.
+// Keys are saved in byte order, so the encoded keys should keep the order of K when it matters.
+type KeyCodec[K any] interface {
+	EncodeKey(key K) (string, error)
+	DecodeKey(key string) (K, error)
+}
@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testVersion struct {
+	Major uint8
+	Minor uint8
+}
+
+type testRecord struct {
+	Name     string
+	Size     int64
+	Tags     []string
+	Modified time.Time
+}
+
+func TestBinaryCodec(t *testing.T) {
+	t.Parallel()
+
+	data, err := Binary[uint64]().Encode(0x0102)
+	require.NoError(t, err)
+	// compatible with binary.LittleEndian.PutUint64
+	assert.Equal(t, []byte{2, 1, 0, 0, 0, 0, 0, 0}, data)
+
+	value, err := Binary[uint64]().Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x0102), value)
+
+	_, err = Binary[uint64]().Decode(data[:4])
+	assert.ErrorIs(t, err, ErrInvalidLength)
+
+	data, err = Binary[testVersion]().Encode(testVersion{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, data)
+
+	_, err = Binary[string]().Encode("variable size")
+	assert.Error(t, err)
+}
+
+func TestMarshalerCodec(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	data, err := Marshaler[uuid.UUID]().Encode(id)
+	require.NoError(t, err)
+	assert.Len(t, data, 16)
+	decodedID, err := Marshaler[uuid.UUID]().Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, id, decodedID)
+
+	now := time.Now()
+	data, err = Marshaler[time.Time]().Encode(now)
+	require.NoError(t, err)
+	decodedTime, err := Marshaler[time.Time]().Decode(data)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(decodedTime))
+
+	_, err = Marshaler[time.Time]().Decode([]byte{1, 2})
+	assert.Error(t, err)
+}
+
+func TestRecordCodecs(t *testing.T) {
+	t.Parallel()
+
+	record := testRecord{
+		Name:     "IMG_0001.CR2",
+		Size:     25165824,
+		Tags:     []string{"wedding", "raw"},
+		Modified: time.Date(2015, 6, 20, 14, 0, 0, 0, time.UTC),
+	}
+	testData := []struct {
+		name  string
+		codec Codec[testRecord]
+	}{
+		{"JSON", JSON[testRecord]()},
+		{"Gob", Gob[testRecord]()},
+		{"CBOR", CBOR[testRecord]()},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := testItem.codec.Encode(record)
+			require.NoError(t, err)
+			decoded, err := testItem.codec.Decode(data)
+			require.NoError(t, err)
+			assert.Equal(t, record.Name, decoded.Name)
+			assert.Equal(t, record.Size, decoded.Size)
+			assert.Equal(t, record.Tags, decoded.Tags)
+			assert.True(t, record.Modified.Equal(decoded.Modified))
+
+			_, err = testItem.codec.Decode([]byte{0xff, 0x00})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyCodecs(t *testing.T) {
+	t.Parallel()
+
+	key, err := StringKey().EncodeKey("dir/file")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", key)
+
+	small, err := Uint64Key().EncodeKey(255)
+	require.NoError(t, err)
+	large, err := Uint64Key().EncodeKey(256)
+	require.NoError(t, err)
+	assert.Less(t, small, large)
+	decoded, err := Uint64Key().DecodeKey(large)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(256), decoded)
+	_, err = Uint64Key().DecodeKey("short")
+	assert.ErrorIs(t, err, ErrInvalidLength)
+
+	id := uuid.New()
+	key, err = UUIDKey().EncodeKey(id)
+	require.NoError(t, err)
+	decodedID, err := UUIDKey().DecodeKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, id, decodedID)
+	_, err = UUIDKey().DecodeKey("not a uuid")
+	assert.Error(t, err)
+}
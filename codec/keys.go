@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// stringKey saves the keys as they are
+type stringKey struct{}
+
+// StringKey returns a key codec for string keys
+func StringKey() KeyCodec[string] {
+	return stringKey{}
+}
+
+func (stringKey) EncodeKey(key string) (string, error) {
+	return key, nil
+}
+
+func (stringKey) DecodeKey(key string) (string, error) {
+	return key, nil
+}
+
+// uint64Key saves the keys in big-endian order
+type uint64Key struct{}
+
+// Uint64Key returns a key codec saving the numbers as 8 bytes in big-endian order,
+// so the keys are sorted in numerical order
+func Uint64Key() KeyCodec[uint64] {
+	return uint64Key{}
+}
+
+func (uint64Key) EncodeKey(key uint64) (string, error) {
+	buffer := make([]byte, 8)
+	binary.BigEndian.PutUint64(buffer, key)
+	return string(buffer), nil
+}
+
+func (uint64Key) DecodeKey(key string) (uint64, error) {
+	if len(key) != 8 {
+		return 0, fmt.Errorf("%w: %d bytes for uint64 key", ErrInvalidLength, len(key))
+	}
+	return binary.BigEndian.Uint64([]byte(key)), nil
+}
+
+// uuidKey saves the keys in their string form
+type uuidKey struct{}
+
+// UUIDKey returns a key codec saving the UUID in their string form
+func UUIDKey() KeyCodec[uuid.UUID] {
+	return uuidKey{}
+}
+
+func (uuidKey) EncodeKey(key uuid.UUID) (string, error) {
+	return key.String(), nil
+}
+
+func (uuidKey) DecodeKey(key string) (uuid.UUID, error) {
+	return uuid.Parse(key)
+}
@@ -0,0 +1,129 @@
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// binaryCodec saves fixed-size values in little-endian order
+type binaryCodec[T any] struct{}
+
+// Binary returns a codec for fixed-size values (numbers, and arrays or structs of numbers),
+// saved with encoding/binary in little-endian order
+func Binary[T any]() Codec[T] {
+	return binaryCodec[T]{}
+}
+
+func (binaryCodec[T]) Encode(value T) ([]byte, error) {
+	size := binary.Size(value)
+	if size < 0 {
+		return nil, fmt.Errorf("type %T doesn't have a fixed size", value)
+	}
+	buffer := bytes.NewBuffer(make([]byte, 0, size))
+	err := binary.Write(buffer, binary.LittleEndian, value)
+	return buffer.Bytes(), err
+}
+
+func (binaryCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	if len(data) != binary.Size(value) {
+		return value, fmt.Errorf("%w: %d bytes for %T", ErrInvalidLength, len(data), value)
+	}
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &value)
+	return value, err
+}
+
+// marshalerCodec uses the MarshalBinary and UnmarshalBinary methods of the type
+type marshalerCodec[T encoding.BinaryMarshaler, PT interface {
+	*T
+	encoding.BinaryUnmarshaler
+}] struct{}
+
+// Marshaler returns a codec for types implementing encoding.BinaryMarshaler and encoding.BinaryUnmarshaler,
+// like time.Time or uuid.UUID
+func Marshaler[T encoding.BinaryMarshaler, PT interface {
+	*T
+	encoding.BinaryUnmarshaler
+}]() Codec[T] {
+	return marshalerCodec[T, PT]{}
+}
+
+func (marshalerCodec[T, PT]) Encode(value T) ([]byte, error) {
+	return value.MarshalBinary()
+}
+
+func (marshalerCodec[T, PT]) Decode(data []byte) (T, error) {
+	var value T
+	err := PT(&value).UnmarshalBinary(data)
+	return value, err
+}
+
+// jsonCodec saves the values in JSON
+type jsonCodec[T any] struct{}
+
+// JSON returns a codec saving the values in JSON
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+func (jsonCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// gobCodec saves the values with encoding/gob
+type gobCodec[T any] struct{}
+
+// Gob returns a codec saving the values with encoding/gob.
+// Each value carries its own type description, so gob is better suited to large values.
+func Gob[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+func (gobCodec[T]) Encode(value T) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	err := gob.NewEncoder(buffer).Encode(value)
+	return buffer.Bytes(), err
+}
+
+func (gobCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// cborCodec saves the values in CBOR
+type cborCodec[T any] struct{}
+
+// CBOR returns a codec saving the values in CBOR: like JSON, but more compact and faster
+func CBOR[T any]() Codec[T] {
+	return cborCodec[T]{}
+}
+
+func (cborCodec[T]) Encode(value T) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+func (cborCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := cbor.Unmarshal(data, &value)
+	return value, err
+}
+
+var (
+	_ Codec[uint64] = binaryCodec[uint64]{}
+	_ Codec[any]    = jsonCodec[any]{}
+	_ Codec[any]    = gobCodec[any]{}
+	_ Codec[any]    = cborCodec[any]{}
+)
@@ -7,3 +7,8 @@ const (
 	Version     = "0.1.0"
 	FileVersion = "1.0"
 )
+
+// Environment variables
+const (
+	EnvPassphrase = "CATALOGUE_PASSPHRASE"
+)
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// batchFile is an indexed file waiting in a batch, with the ID of its path in the search index
+type batchFile struct {
+	id    uint64
+	path  string
+	entry FileEntry
+}
+
+// saveBatch writes a batch of indexed files, given in the order of their IDs, and their indexes into the buckets under root.
+// Each bucket is written in the order of its keys: inserting keys in random order into a B+tree moves the keys
+// of the same page over and over. It can run more than once for the same batch.
+func saveBatch(root store.Bucketeer, batch []batchFile) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	bucket, err := root.GetBucket(BucketFiles)
+	if err != nil {
+		return err
+	}
+	files := newFilesBucket(bucket)
+	byPath := slices.Clone(batch)
+	slices.SortFunc(byPath, func(a, b batchFile) int {
+		return strings.Compare(a.path, b.path)
+	})
+	for _, file := range byPath {
+		if err = files.Put(file.path, file.entry); err != nil {
+			return err
+		}
+	}
+	return saveIndexes(root, batch, byPath)
+}
+
+// saveIndexes writes the search index and the secondary indexes of a batch of files,
+// given in the order of their IDs and sorted by path
+func saveIndexes(root store.Bucketeer, batch, byPath []batchFile) error {
+	paths, err := root.GetBucket(BucketPaths)
+	if err != nil {
+		return err
+	}
+	index := make(postings)
+	for _, file := range batch {
+		if err = paths.Put(idToKey(file.id), []byte(file.path)); err != nil {
+			return err
+		}
+		index.add(file.id, file.path)
+	}
+	trigramBucket, err := root.GetBucket(BucketTrigrams)
+	if err != nil {
+		return err
+	}
+	if err = index.save(trigramBucket); err != nil {
+		return err
+	}
+	return indexFiles(root, byPath)
+}
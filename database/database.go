@@ -1,9 +1,13 @@
 package database
 
 import (
-	"encoding/binary"
+	"context"
+	"errors"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/creativeprojects/catalogue/index"
 	"github.com/creativeprojects/catalogue/store"
 	"github.com/creativeprojects/catalogue/volume"
 	"github.com/google/uuid"
@@ -19,10 +23,29 @@ const (
 	KeyTotalFiles       = "total-files"
 	KeyCreated          = "created"
 	KeyLastSaved        = "last-saved"
+	KeySharded          = "sharded"
+	KeyVolume           = "volume"
+	KeyVolumeID         = "volume-id"
+	KeyShard            = "shard"
+	BucketFiles         = "files"
+	BucketPaths         = "paths"
+	BucketTrigrams      = "trigrams"
+	BucketBySize        = "by-size"
+	BucketByModTime     = "by-mtime"
+	BucketByExtension   = "by-extension"
+	BucketShard         = "catalogue-shard"
+	BucketRebuild       = "rebuild-indexes"
+	KeyRebuild          = "progress"
+)
+
+var (
+	ErrInvalidIDKey = errors.New("Invalid ID key: expected 8 bytes")
 )
 
 type Database struct {
-	storage store.Store
+	storage    store.Store
+	shards     *Shards
+	migrations Migrations
 }
 
 type Stats struct {
@@ -33,6 +56,15 @@ type Stats struct {
 	TotalFiles       uint64
 	Created          time.Time
 	LastSaved        time.Time
+	// Sharded is true when the files of the new volumes are saved in shards
+	Sharded bool
+}
+
+// FileEntry is the information saved for each file or directory of a volume
+type FileEntry struct {
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
 }
 
 type Version struct {
@@ -42,18 +74,58 @@ type Version struct {
 
 var (
 	// CurrentVersion is the accepted database version
-	CurrentVersion = Version{1, 0}
+	CurrentVersion = Version{1, 2}
+	// IndexBatchSize is the maximum number of files saved in one transaction during indexing
+	IndexBatchSize = 10000
+	// IndexBatchDelay is the maximum time an indexed file waits before being saved
+	IndexBatchDelay = 2 * time.Second
 )
 
+// NewDatabase uses the store to save the catalogue.
+// The derived data like the time of the last change are updated automatically on each commit:
+// the hooks only need to know which buckets have changed, so the values written are not recorded.
 func NewDatabase(s store.Store) *Database {
+	observable := store.NewObservableStoreWithOptions(s, store.ObservableOptions{BucketsOnly: true})
+	observable.OnBeforeCommit(updateLastSaved)
 	return &Database{
-		storage: s,
+		storage:    observable,
+		migrations: registry,
 	}
 }
 
-// Init a blank database
-func (d *Database) Init() {
-	d.storage.Update(func(transaction store.Transaction) error {
+// NewShardedDatabase uses the store for the volumes and the statistics, and the shards for the files of the volumes
+// saved in their own shard file. The shards are only opened when they're needed.
+func NewShardedDatabase(s store.Store, shards *Shards) *Database {
+	d := NewDatabase(s)
+	d.shards = shards
+	return d
+}
+
+// Init a blank database, saving the files of all the volumes in the same store
+func (d *Database) Init() error {
+	return d.init(false)
+}
+
+// InitSharded initializes a blank database saving the files of each new volume in its own shard
+func (d *Database) InitSharded() error {
+	return d.init(true)
+}
+
+// Shards returns the shards of the database, or nil for a database opened without its shards
+func (d *Database) Shards() *Shards {
+	return d.shards
+}
+
+// Close the shards and the store
+func (d *Database) Close() {
+	if d.shards != nil {
+		d.shards.Close()
+	}
+	d.storage.Close()
+}
+
+func (d *Database) init(sharded bool) error {
+	return d.storage.Update(func(transaction store.Transaction) error {
 		_, err := transaction.CreateBucket(BucketVolumes)
 		if err != nil {
 			return err
@@ -62,106 +134,333 @@ func (d *Database) Init() {
 		if err != nil {
 			return err
 		}
-		if ID, err := uuid.NewRandom(); err == nil {
-			if bID, err := ID.MarshalBinary(); err == nil {
-				stats.Put(KeyDatabaseID, bID)
-			}
+		databaseID, err := uuid.NewRandom()
+		if err != nil {
+			return err
 		}
-		stats.Put(KeyVersion, versionToBytes(CurrentVersion))
-		stats.Put(KeyTotalVolumes, uint64ToBytes(0))
-		stats.Put(KeyTotalDirectories, uint64ToBytes(0))
-		stats.Put(KeyTotalFiles, uint64ToBytes(0))
-
 		now := time.Now()
-		if bNow, err := now.MarshalBinary(); err == nil {
-			stats.Put(KeyCreated, bNow)
-			stats.Put(KeyLastSaved, bNow)
-		}
-		return nil
+		return saveStats(stats, Stats{
+			DatabaseID: databaseID,
+			Version:    CurrentVersion,
+			Created:    now,
+			LastSaved:  now,
+			Sharded:    sharded,
+		})
 	})
 }
 
-func (d *Database) Stats() Stats {
-	stats := Stats{}
-	d.storage.View(func(transaction store.Transaction) error {
+// Stats returns the statistics saved in the database
+func (d *Database) Stats() (Stats, error) {
+	var stats Stats
+	err := d.storage.View(func(transaction store.Transaction) error {
 		bucket, err := transaction.GetBucket(BucketStats)
 		if err != nil {
 			return err
 		}
-		stats.DatabaseID = bytesToUUID(bucket.Get(KeyDatabaseID))
-		stats.Version = bytesToVersion(bucket.Get(KeyVersion))
+		stats, err = loadStats(bucket)
+		return err
+	})
+	return stats, err
+}
 
-		stats.TotalVolumes = bytesToUint64(bucket.Get(KeyTotalVolumes))
-		stats.TotalDirectories = bytesToUint64(bucket.Get(KeyTotalDirectories))
-		stats.TotalFiles = bytesToUint64(bucket.Get(KeyTotalFiles))
+// IndexVolume saves the volume and all the files received from the channel.
+// The files are saved in batches of transactions, and the volume record and the statistics
+// are only saved at the end: if anything fails or the context is cancelled, the files already saved are removed.
+// The channel is always drained, even when an error occurs.
+func (d *Database) IndexVolume(ctx context.Context, vol *volume.Volume, files <-chan index.FileIndexed) (uuid.UUID, error) {
+	// Make sure the indexer is never blocked on a full channel
+	defer func() {
+		for range files {
+		}
+	}()
 
-		stats.Created = bytesToTime(bucket.Get(KeyCreated))
-		stats.LastSaved = bytesToTime(bucket.Get(KeyLastSaved))
+	volumeID, err := uuid.NewRandom()
+	if err != nil {
+		return uuid.Nil, err
+	}
 
-		return nil
-	})
-	return stats
+	sharded, err := d.isSharded()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if sharded {
+		err = d.indexShard(ctx, volumeID, vol, files)
+	} else {
+		err = d.indexVolume(ctx, volumeID, vol, files)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return volumeID, nil
 }
 
-func (d *Database) IndexVolume(vol *volume.Volume) {
-	// d.storage.Update(func(transaction store.Transaction) error {
-	// 	bucket, err := transaction.GetBucket(BucketVolumes)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	return nil
-	// })
+// isSharded returns true when the new volumes are saved in shards.
+// A database opened without shards always saves the volumes in its own store.
+func (d *Database) isSharded() (bool, error) {
+	if d.shards == nil {
+		return false, nil
+	}
+	sharded := false
+	err := d.storage.View(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		sharded, err = statSharded.Get(stats)
+		return err
+	})
+	return sharded, err
 }
 
-func uint64ToBytes(value uint64) []byte {
-	buffer := make([]byte, 8)
-	binary.LittleEndian.PutUint64(buffer, value)
-	return buffer
+// indexVolume saves the files in the bucket of the volume
+func (d *Database) indexVolume(ctx context.Context, volumeID uuid.UUID, vol *volume.Volume, files <-chan index.FileIndexed) error {
+	err := d.storage.UpdateContext(ctx, func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		volumeBucket, err := volumes.CreateBucket(volumeID.String())
+		if err != nil {
+			return err
+		}
+		return createFilesBuckets(volumeBucket)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = d.saveVolume(ctx, volumeID, vol, files)
+	if err != nil {
+		// best effort: the original error is more important
+		_ = d.storage.Update(func(transaction store.Transaction) error {
+			volumes, err := transaction.GetBucket(BucketVolumes)
+			if err != nil {
+				return err
+			}
+			return volumes.DeleteBucket(volumeID.String())
+		})
+		return err
+	}
+	return nil
 }
 
-func int64ToBytes(value int64) []byte {
-	return uint64ToBytes(uint64(value))
+// saveVolume saves the files in batches, then the volume record and the statistics in a last transaction
+func (d *Database) saveVolume(ctx context.Context, volumeID uuid.UUID, vol *volume.Volume, files <-chan index.FileIndexed) error {
+	totals, err := saveFiles(ctx, d.storage, func(transaction store.Transaction) (store.Bucketeer, error) {
+		return getVolumeBucket(transaction, volumeID)
+	}, files)
+	if err != nil {
+		return err
+	}
+	totals.update(vol)
+
+	return d.storage.UpdateContext(ctx, func(transaction store.Transaction) error {
+		return registerVolume(transaction, volumeID, *vol, totals, false)
+	})
 }
 
-func versionToBytes(version Version) []byte {
-	output := make([]byte, 2)
-	output[0] = byte(version.Major)
-	output[1] = byte(version.Minor)
-	return output
+// indexShard saves the files in a new shard. The volume is only added to the database once the shard is complete:
+// the shard is removed if anything fails.
+func (d *Database) indexShard(ctx context.Context, volumeID uuid.UUID, vol *volume.Volume, files <-chan index.FileIndexed) error {
+	err := d.saveShard(ctx, volumeID, vol, files)
+	if err != nil {
+		// best effort: the original error is more important
+		_ = d.shards.remove(volumeID)
+		return err
+	}
+	return nil
 }
 
-func bytesToUint64(data []byte, err error) uint64 {
+// saveShard saves the files in batches, then the shard header, and finally adds the volume to the database
+func (d *Database) saveShard(ctx context.Context, volumeID uuid.UUID, vol *volume.Volume, files <-chan index.FileIndexed) error {
+	shard, err := d.shards.get(volumeID, true)
 	if err != nil {
-		return 0
+		return err
 	}
-	return binary.LittleEndian.Uint64(data)
+	err = shard.UpdateContext(ctx, func(transaction store.Transaction) error {
+		return createFilesBuckets(transaction)
+	})
+	if err != nil {
+		return err
+	}
+
+	totals, err := saveFiles(ctx, shard, shardRoot, files)
+	if err != nil {
+		return err
+	}
+	totals.update(vol)
+
+	// the header makes the shard complete: a shard without a header cannot be attached
+	err = shard.UpdateContext(ctx, func(transaction store.Transaction) error {
+		header, err := transaction.CreateBucket(BucketShard)
+		if err != nil {
+			return err
+		}
+		return errors.Join(
+			recordVolumeID.Put(header, volumeID),
+			recordVolume.Put(header, *vol),
+			recordShard.Put(header, totals),
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.storage.UpdateContext(ctx, func(transaction store.Transaction) error {
+		return registerVolume(transaction, volumeID, *vol, totals, true)
+	})
+}
+
+// indexBuckets are the buckets of the search index and of the secondary indexes of a volume
+var indexBuckets = []string{BucketPaths, BucketTrigrams, BucketBySize, BucketByModTime, BucketByExtension}
+
+// createFilesBuckets creates the buckets of the files, of the search index and of the secondary indexes of a volume
+func createFilesBuckets(root store.Bucketeer) error {
+	for _, name := range append([]string{BucketFiles}, indexBuckets...) {
+		if _, err := root.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveFiles saves the files in batches of transactions into the buckets created under the root returned by volumeRoot,
+// and returns the totals. The path of each file is added to the search index, and each regular file to the secondary indexes.
+func saveFiles(
+	ctx context.Context,
+	storage store.Store,
+	volumeRoot func(transaction store.Transaction) (store.Bucketeer, error),
+	files <-chan index.FileIndexed,
+) (volumeTotals, error) {
+	writer := store.NewBatchWriter(storage, store.BatchOptions{
+		MaxSize:  IndexBatchSize,
+		MaxDelay: IndexBatchDelay,
+	}, func(transaction store.Transaction, files []batchFile) error {
+		root, err := volumeRoot(transaction)
+		if err != nil {
+			return err
+		}
+		return saveBatch(root, files)
+	})
+
+	totals := volumeTotals{}
+	// the ID of the path is chosen outside of the batch, so the batch can be saved more than once
+	pathID := uint64(0)
+	for {
+		var file index.FileIndexed
+		var ok bool
+		select {
+		case <-ctx.Done():
+			// the files saved so far are removed by the caller
+			_ = writer.Close()
+			return totals, ctx.Err()
+		case file, ok = <-files:
+		}
+		if !ok {
+			break
+		}
+		if file.Error != nil || file.Info == nil {
+			continue
+		}
+		pathID++
+		err := writer.Add(batchFile{
+			id:   pathID,
+			path: file.Path,
+			entry: FileEntry{
+				Size:    file.Info.Size(),
+				Mode:    file.Info.Mode(),
+				ModTime: file.Info.ModTime(),
+			},
+		})
+		if err != nil {
+			return totals, err
+		}
+		if file.Info.IsDir() {
+			totals.Directories++
+			continue
+		}
+		totals.Files++
+		if strings.HasPrefix(file.Info.Name(), ".") {
+			totals.Hidden++
+		}
+	}
+	return totals, writer.Close()
 }
 
-func bytesToInt64(data []byte, err error) int64 {
-	return int64(bytesToUint64(data, err))
+// registerVolume saves the volume record and adds the volume to the statistics.
+// The bucket of a volume saved in the database is already created with its files,
+// but the bucket of a sharded volume is created here.
+func registerVolume(transaction store.Transaction, volumeID uuid.UUID, vol volume.Volume, totals volumeTotals, sharded bool) error {
+	stats, err := transaction.GetBucket(BucketStats)
+	if err != nil {
+		return err
+	}
+	volumes, err := transaction.GetBucket(BucketVolumes)
+	if err != nil {
+		return err
+	}
+	var volumeBucket store.Bucket
+	if sharded {
+		volumeBucket, err = volumes.CreateBucket(volumeID.String())
+		if errors.Is(err, store.ErrBucketNameExists) {
+			return ErrVolumeExists
+		}
+		if err == nil {
+			err = recordShard.Put(volumeBucket, totals)
+		}
+	} else {
+		volumeBucket, err = volumes.GetBucket(volumeID.String())
+	}
+	if err != nil {
+		return err
+	}
+	err = recordVolume.Put(volumeBucket, vol)
+	if err != nil {
+		return err
+	}
+
+	err = addToCounter(stats, statTotalVolumes, 1)
+	if err != nil {
+		return err
+	}
+	err = addToCounter(stats, statTotalDirectories, totals.Directories)
+	if err != nil {
+		return err
+	}
+	return addToCounter(stats, statTotalFiles, totals.Files)
 }
 
-func bytesToTime(data []byte, err error) time.Time {
-	output := time.Time{}
-	if err == nil {
-		output.UnmarshalBinary(data)
+// unregisterVolume removes a deleted volume from the statistics
+func unregisterVolume(transaction store.Transaction, totals volumeTotals) error {
+	stats, err := transaction.GetBucket(BucketStats)
+	if err != nil {
+		return err
 	}
-	return output
+	return errors.Join(
+		removeFromCounter(stats, statTotalVolumes, 1),
+		removeFromCounter(stats, statTotalDirectories, totals.Directories),
+		removeFromCounter(stats, statTotalFiles, totals.Files),
+	)
 }
 
-func bytesToUUID(data []byte, err error) uuid.UUID {
-	output := uuid.UUID{}
-	if err == nil {
-		output.UnmarshalBinary(data)
+// getVolumeBucket returns the bucket of a volume
+func getVolumeBucket(transaction store.Transaction, volumeID uuid.UUID) (store.Bucket, error) {
+	volumes, err := transaction.GetBucket(BucketVolumes)
+	if err != nil {
+		return nil, err
 	}
-	return output
+	return volumes.GetBucket(volumeID.String())
 }
 
-func bytesToVersion(data []byte, err error) Version {
-	output := Version{1, 0}
-	if err == nil {
-		output.Major = uint8(data[0])
-		output.Minor = uint8(data[1])
+// getFilesBucket returns the bucket containing all the files of a volume
+func getFilesBucket(transaction store.Transaction, volumeID uuid.UUID) (*store.TypedBucket[string, FileEntry], error) {
+	volumeBucket, err := getVolumeBucket(transaction, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := volumeBucket.GetBucket(BucketFiles)
+	if err != nil {
+		return nil, err
 	}
-	return output
+	return newFilesBucket(files), nil
 }
@@ -1,14 +1,24 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
 	"os"
 	"path"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/creativeprojects/catalogue/index"
 	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
 )
 
 type testStoreData struct {
@@ -43,8 +53,12 @@ func TestDatabase(t *testing.T) {
 			t.Run("TestInitAndStats", func(t *testing.T) {
 				t.Parallel()
 
-				database.Init()
-				stats := database.Stats()
+				err := database.Init()
+				require.NoError(t, err)
+				stats, err := database.Stats()
+				require.NoError(t, err)
+				assert.Equal(t, CurrentVersion, stats.Version)
+				assert.NotEqual(t, uuid.Nil, stats.DatabaseID)
 				assert.NotNil(t, stats.Created)
 				assert.NotNil(t, stats.LastSaved)
 				assert.WithinDuration(t, time.Now(), stats.Created, 10*time.Second)
@@ -53,3 +67,371 @@ func TestDatabase(t *testing.T) {
 		testData.store.Close()
 	}
 }
+
+func TestIndexVolume(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	db := NewDatabase(memory)
+	require.NoError(t, db.Init())
+
+	fsys := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Data: []byte("some content"), ModTime: time.Unix(1000, 0)},
+		"dir/.hidden": &fstest.MapFile{Data: []byte("secret")},
+	}
+	files := make(chan index.FileIndexed, 10)
+	for _, name := range []string{".", "dir", "dir/file", "dir/.hidden"} {
+		info, err := fs.Stat(fsys, name)
+		require.NoError(t, err)
+		files <- index.FileIndexed{Path: name, Info: info}
+	}
+	files <- index.FileIndexed{Path: "error", Error: errors.New("cannot read")}
+	close(files)
+
+	vol := &volume.Volume{Name: "test"}
+	volumeID, err := db.IndexVolume(context.Background(), vol, files)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, volumeID)
+	assert.Equal(t, uint64(1), vol.RegularFiles)
+	assert.Equal(t, uint64(1), vol.HiddenFiles)
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.TotalVolumes)
+	assert.Equal(t, uint64(2), stats.TotalDirectories)
+	assert.Equal(t, uint64(2), stats.TotalFiles)
+
+	err = memory.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		require.NoError(t, err)
+		volumeBucket, err := volumes.GetBucket(volumeID.String())
+		require.NoError(t, err)
+		savedVolume, err := recordVolume.Get(volumeBucket)
+		require.NoError(t, err)
+		assert.Equal(t, "test", savedVolume.Name)
+
+		filesBucket, err := getFilesBucket(transaction, volumeID)
+		require.NoError(t, err)
+
+		entry, err := filesBucket.Get("dir/file")
+		require.NoError(t, err)
+		assert.Equal(t, int64(12), entry.Size)
+		assert.True(t, entry.Mode.IsRegular())
+		assert.True(t, time.Unix(1000, 0).Equal(entry.ModTime))
+
+		entry, err = filesBucket.Get("dir")
+		require.NoError(t, err)
+		assert.True(t, entry.Mode.IsDir())
+
+		_, err = filesBucket.Get("error")
+		assert.ErrorIs(t, err, store.ErrKeyNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestIndexVolumeRemovesFilesOnError(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	// the stats bucket is missing so the last transaction will fail
+	err := memory.Update(func(transaction store.Transaction) error {
+		_, err := transaction.CreateBucket(BucketVolumes)
+		return err
+	})
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"file": &fstest.MapFile{Data: []byte("some content")},
+	}
+	files := make(chan index.FileIndexed, 10)
+	for _, name := range []string{".", "file"} {
+		info, err := fs.Stat(fsys, name)
+		require.NoError(t, err)
+		files <- index.FileIndexed{Path: name, Info: info}
+	}
+	close(files)
+
+	db := NewDatabase(memory)
+	_, err = db.IndexVolume(context.Background(), &volume.Volume{}, files)
+	require.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	err = memory.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		require.NoError(t, err)
+		return volumes.ForEach(func(key string, data []byte) error {
+			t.Errorf("unexpected key %q", key)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+}
+
+func TestIndexVolumeCancelled(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	db := NewDatabase(memory)
+	require.NoError(t, db.Init())
+
+	fsys := fstest.MapFS{
+		"file": &fstest.MapFile{Data: []byte("some content")},
+	}
+	info, err := fs.Stat(fsys, "file")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	files := make(chan index.FileIndexed)
+	result := make(chan error)
+	go func() {
+		_, err := db.IndexVolume(ctx, &volume.Volume{}, files)
+		result <- err
+	}()
+	files <- index.FileIndexed{Path: "file", Info: info}
+	cancel()
+	close(files)
+	assert.ErrorIs(t, <-result, context.Canceled)
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalVolumes)
+	assert.Zero(t, stats.TotalFiles)
+
+	err = memory.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		require.NoError(t, err)
+		return volumes.ForEachBucket(func(name string) error {
+			t.Errorf("unexpected volume %q", name)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+}
+
+func TestLastSavedIsUpdatedOnAnyChange(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	db := NewDatabase(memory)
+	require.NoError(t, db.Init())
+	initial, err := db.Stats()
+	require.NoError(t, err)
+
+	// changing only the statistics doesn't count as a change
+	err = db.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return addToCounter(stats, statTotalVolumes, 0)
+	})
+	require.NoError(t, err)
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.True(t, initial.LastSaved.Equal(stats.LastSaved))
+
+	time.Sleep(time.Millisecond)
+	err = db.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		_, err = volumes.CreateBucket("volume")
+		return err
+	})
+	require.NoError(t, err)
+	stats, err = db.Stats()
+	require.NoError(t, err)
+	assert.True(t, stats.LastSaved.After(initial.LastSaved))
+}
+
+func TestStatsReturnsErrors(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	db := NewDatabase(memory)
+	_, err := db.Stats()
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	require.NoError(t, db.Init())
+	err = memory.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return stats.Put(KeyTotalFiles, []byte{1, 2, 3})
+	})
+	require.NoError(t, err)
+
+	_, err = db.Stats()
+	assert.ErrorContains(t, err, KeyTotalFiles)
+}
+
+func TestFileEntryBinary(t *testing.T) {
+	t.Parallel()
+
+	entry := FileEntry{Size: 1 << 40, Mode: fs.ModeDir | 0o755, ModTime: time.Date(2015, 3, 14, 15, 9, 26, 0, time.UTC)}
+	data, err := entry.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded := FileEntry{}
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, entry.Size, decoded.Size)
+	assert.Equal(t, entry.Mode, decoded.Mode)
+	assert.True(t, entry.ModTime.Equal(decoded.ModTime))
+
+	assert.Error(t, decoded.UnmarshalBinary(data[:8]))
+}
+
+func TestIDKeys(t *testing.T) {
+	t.Parallel()
+
+	ids := []uint64{0, 1, 255, 256, 65535, 1 << 32, 1<<64 - 1}
+	previous := ""
+	for _, id := range ids {
+		key := idToKey(id)
+		assert.Len(t, key, 8)
+		assert.Greater(t, key, previous)
+		previous = key
+
+		decoded, err := keyToID(key)
+		require.NoError(t, err)
+		assert.Equal(t, id, decoded)
+	}
+
+	_, err := keyToID("short")
+	assert.ErrorIs(t, err, ErrInvalidIDKey)
+}
+
+func BenchmarkIndexVolume(b *testing.B) {
+	backends := []struct {
+		name  string
+		store func(b *testing.B) store.Store
+	}{
+		{"InMemory", func(b *testing.B) store.Store {
+			return store.NewMemoryStore()
+		}},
+		{"BoltDB", func(b *testing.B) store.Store {
+			boltStore, err := store.NewBoltStore(path.Join(b.TempDir(), "bench.db"))
+			require.NoError(b, err)
+			return boltStore
+		}},
+	}
+	fsys := fstest.MapFS{
+		"file": &fstest.MapFile{Data: []byte("some content")},
+	}
+	info, err := fs.Stat(fsys, "file")
+	require.NoError(b, err)
+
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			storage := backend.store(b)
+			defer storage.Close()
+
+			db := NewDatabase(storage)
+			require.NoError(b, db.Init())
+
+			files := make(chan index.FileIndexed, 1000)
+			go func() {
+				defer close(files)
+				for i := 0; i < b.N; i++ {
+					files <- index.FileIndexed{Path: fmt.Sprintf("dir/file-%012d", i), Info: info}
+				}
+			}()
+
+			b.ResetTimer()
+			_, err := db.IndexVolume(context.Background(), &volume.Volume{}, files)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "files/s")
+		})
+	}
+}
+
+// volumePaths returns the paths of a volume of directories and files with varied names and extensions, in walk order
+func volumePaths(count int) []string {
+	words := []string{"photos", "holiday", "invoice", "report", "music", "backup", "project", "draft", "final", "scan",
+		"family", "work", "archive", "summer", "notes", "video", "2019", "2020", "old", "new"}
+	extensions := []string{".jpg", ".png", ".pdf", ".txt", ".mp3", ".go", ".tar.gz", ".docx", ".cr2", ""}
+	random := rand.New(rand.NewSource(1))
+	paths := make([]string, 0, count)
+	dirs := []string{""}
+	for len(paths) < count {
+		dir := dirs[random.Intn(len(dirs))]
+		name := words[random.Intn(len(words))] + "-" + words[random.Intn(len(words))] + fmt.Sprintf("-%d", random.Intn(1000))
+		if random.Intn(10) == 0 {
+			dirs = append(dirs, dir+name+"/")
+			paths = append(paths, dir+name)
+			continue
+		}
+		paths = append(paths, dir+name+extensions[random.Intn(len(extensions))])
+	}
+	return paths
+}
+
+// BenchmarkIndexLargeVolume indexes a volume larger than a batch into a new database on each iteration:
+// unlike BenchmarkIndexVolume, the paths are varied enough to spread the keys of the indexes across the whole tree
+func BenchmarkIndexLargeVolume(b *testing.B) {
+	count := 2 * IndexBatchSize
+	backends := []struct {
+		name  string
+		store func(b *testing.B) store.Store
+	}{
+		{"InMemory", func(b *testing.B) store.Store {
+			return store.NewMemoryStore()
+		}},
+		{"BoltDB", func(b *testing.B) store.Store {
+			boltStore, err := store.NewBoltStore(path.Join(b.TempDir(), "bench.db"))
+			require.NoError(b, err)
+			return boltStore
+		}},
+	}
+	fsys := fstest.MapFS{
+		"file": &fstest.MapFile{Data: []byte("some content")},
+	}
+	info, err := fs.Stat(fsys, "file")
+	require.NoError(b, err)
+	paths := volumePaths(count)
+
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				storage := backend.store(b)
+				db := NewDatabase(storage)
+				require.NoError(b, db.Init())
+				files := make(chan index.FileIndexed, 1000)
+				go func() {
+					defer close(files)
+					for _, filePath := range paths {
+						files <- index.FileIndexed{Path: filePath, Info: info}
+					}
+				}()
+				b.StartTimer()
+
+				_, err := db.IndexVolume(context.Background(), &volume.Volume{}, files)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				storage.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(b.N*count)/b.Elapsed().Seconds(), "files/s")
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"container/heap"
+	"slices"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/query"
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// FuzzyResult is a file or a directory found by a fuzzy search, with its relevance
+type FuzzyResult struct {
+	SearchResult
+	Score int
+}
+
+// FuzzySearch returns the files and directories matching the search across all the volumes included in search,
+// the most relevant first. Only the best results are kept when limit is positive.
+// A typo cannot be found through the trigram index: all the paths are read.
+func (d *Database) FuzzySearch(search *query.Fuzzy, limit int) ([]FuzzyResult, error) {
+	volumes, err := d.searchedVolumes()
+	if err != nil {
+		return nil, err
+	}
+	results := &fuzzyResults{}
+	for _, record := range volumes {
+		err = d.ViewFiles(record.ID, func(files *store.TypedBucket[string, FileEntry]) error {
+			// the entries are only decoded for the results
+			return files.Bucket().ForEach(func(path string, _ []byte) error {
+				score, found := search.Score(path)
+				if !found {
+					return nil
+				}
+				result := FuzzyResult{
+					SearchResult: SearchResult{Volume: record, Path: path},
+					Score:        score,
+				}
+				if limit > 0 && results.Len() >= limit {
+					if !result.better((*results)[0]) {
+						return nil
+					}
+					heap.Pop(results)
+				}
+				entry, err := files.Get(path)
+				if err != nil {
+					return err
+				}
+				result.Entry = entry
+				heap.Push(results, result)
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(*results, func(a, b FuzzyResult) int {
+		if a.better(b) {
+			return -1
+		}
+		if b.better(a) {
+			return 1
+		}
+		return 0
+	})
+	return *results, nil
+}
+
+// better returns true when the result is ranked before the other one:
+// with a higher score, then by volume name and by path
+func (r FuzzyResult) better(other FuzzyResult) bool {
+	if r.Score != other.Score {
+		return r.Score > other.Score
+	}
+	if compare := strings.Compare(r.Volume.Volume.Name, other.Volume.Volume.Name); compare != 0 {
+		return compare < 0
+	}
+	return r.Path < other.Path
+}
+
+// fuzzyResults is a heap keeping the worst result at the top
+type fuzzyResults []FuzzyResult
+
+func (r fuzzyResults) Len() int           { return len(r) }
+func (r fuzzyResults) Less(i, j int) bool { return r[j].better(r[i]) }
+func (r fuzzyResults) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func (r *fuzzyResults) Push(x any) {
+	*r = append(*r, x.(FuzzyResult))
+}
+
+func (r *fuzzyResults) Pop() any {
+	old := *r
+	last := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return last
+}
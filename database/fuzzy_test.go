@@ -0,0 +1,72 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/query"
+)
+
+// fuzzyPaths returns the volume name and the path of each result
+func fuzzyPaths(t *testing.T, db *Database, text string, limit int) []string {
+	t.Helper()
+
+	search, err := query.NewFuzzy(text)
+	require.NoError(t, err)
+	results, err := db.FuzzySearch(search, limit)
+	require.NoError(t, err)
+	found := make([]string, len(results))
+	for i, result := range results {
+		found[i] = result.Volume.Volume.Name + ":" + result.Path
+	}
+	return found
+}
+
+func TestFuzzySearch(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "first")
+	sharded := newShardedDatabase(t, t.TempDir())
+	indexTestFiles(t, sharded, "first")
+
+	for _, db := range []*Database{db, sharded} {
+		indexTestFiles(t, db, "second")
+
+		// the exact name first
+		assert.Equal(t, []string{"first:dir", "second:dir", "first:dir/.hidden", "first:dir/file", "second:dir/.hidden", "second:dir/file"},
+			fuzzyPaths(t, db, "dir", 0))
+		assert.Equal(t, []string{"first:dir", "second:dir", "first:dir/.hidden"}, fuzzyPaths(t, db, "dir", 3))
+		assert.Equal(t, []string{"first:dir/file", "second:dir/file"}, fuzzyPaths(t, db, "fille", 0))
+		assert.Empty(t, fuzzyPaths(t, db, "nothing", 0))
+	}
+}
+
+func TestFuzzySearchSkipsExcludedVolumes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "included")
+	indexExcludedTestFiles(t, db, "excluded")
+
+	assert.Equal(t, []string{"included:dir/file"}, fuzzyPaths(t, db, "file", 0))
+}
+
+func TestFuzzySearchResult(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexTestFiles(t, db, "test")
+
+	search, err := query.NewFuzzy("file")
+	require.NoError(t, err)
+	results, err := db.FuzzySearch(search, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, volumeID, results[0].Volume.ID)
+	assert.Equal(t, "dir/file", results[0].Path)
+	assert.Equal(t, int64(12), results[0].Entry.Size)
+	assert.Positive(t, results[0].Score)
+}
@@ -0,0 +1,468 @@
+package database
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// FileOrder is the order of a secondary index of the files
+type FileOrder int
+
+const (
+	BySize FileOrder = iota
+	ByModTime
+)
+
+// errIndexLimit stops reading an index once enough files are found
+var errIndexLimit = errors.New("index limit reached")
+
+// fileIndex is a secondary index of the regular files of a volume: the key of each file is an order-preserving
+// fixed-width encoding of the indexed value, followed by the path. The values are empty.
+type fileIndex struct {
+	bucket string
+	width  int
+	key    func(entry FileEntry) string
+}
+
+var (
+	indexBySize = fileIndex{
+		bucket: BucketBySize,
+		width:  8,
+		key: func(entry FileEntry) string {
+			return sizeKey(entry.Size)
+		},
+	}
+	indexByModTime = fileIndex{
+		bucket: BucketByModTime,
+		width:  12,
+		key: func(entry FileEntry) string {
+			return timeKey(entry.ModTime)
+		},
+	}
+)
+
+// index returns the secondary index of the order
+func (o FileOrder) index() fileIndex {
+	if o == ByModTime {
+		return indexByModTime
+	}
+	return indexBySize
+}
+
+// less compares the entries in the order of the index
+func (o FileOrder) less(a, b FileEntry) bool {
+	if o == ByModTime {
+		return a.ModTime.Before(b.ModTime)
+	}
+	return a.Size < b.Size
+}
+
+// FilesBySize runs the function on the regular files with a size from "from" (inclusive) to "to" (exclusive), smallest first.
+// The files are sorted volume by volume. Use math.MaxInt64 for no upper bound.
+func (d *Database) FilesBySize(from, to int64, fn func(result SearchResult) error) error {
+	return d.forEachVolume(func(record VolumeRecord, root store.Bucketeer) error {
+		return scanIndex(root, indexBySize, sizeKey(from), sizeKey(to), false, resultsOf(record, fn))
+	})
+}
+
+// FilesByModTime runs the function on the regular files modified from "from" (inclusive) to "to" (exclusive), oldest first.
+// The files are sorted volume by volume.
+func (d *Database) FilesByModTime(from, to time.Time, fn func(result SearchResult) error) error {
+	return d.forEachVolume(func(record VolumeRecord, root store.Bucketeer) error {
+		return scanIndex(root, indexByModTime, timeKey(from), timeKey(to), false, resultsOf(record, fn))
+	})
+}
+
+// FilesByExtension runs the function on the regular files with the extension, ignoring case.
+// The extension can start with a dot.
+func (d *Database) FilesByExtension(extension string, fn func(result SearchResult) error) error {
+	extension = strings.ToLower(strings.TrimPrefix(extension, "."))
+	if extension == "" {
+		return nil
+	}
+	return d.forEachVolume(func(record VolumeRecord, root store.Bucketeer) error {
+		return scanExtension(root, extension, resultsOf(record, fn))
+	})
+}
+
+// TopFiles returns the largest or the most recently modified regular files across all the volumes
+func (d *Database) TopFiles(order FileOrder, limit int) ([]SearchResult, error) {
+	if limit < 1 {
+		return []SearchResult{}, nil
+	}
+	top := &topResults{order: order, results: make([]topResult, 0, limit)}
+	err := d.forEachVolume(func(record VolumeRecord, root store.Bucketeer) error {
+		err := scanIndex(root, order.index(), "", "", true, func(filePath string, entry FileEntry) error {
+			result := topResult{
+				SearchResult: SearchResult{
+					Volume: record,
+					Path:   filePath,
+					Entry:  entry,
+				},
+				rank: top.count,
+			}
+			top.count++
+			if len(top.results) < limit {
+				heap.Push(top, result)
+				return nil
+			}
+			if !top.before(result, top.results[0]) {
+				// the next files of the volume come after this one
+				return errIndexLimit
+			}
+			top.results[0] = result
+			heap.Fix(top, 0)
+			return nil
+		})
+		if errors.Is(err, errIndexLimit) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(top.results, func(a, b topResult) int {
+		if top.before(a, b) {
+			return -1
+		}
+		return 1
+	})
+	results := make([]SearchResult, len(top.results))
+	for i, result := range top.results {
+		results[i] = result.SearchResult
+	}
+	return results, nil
+}
+
+// topResult is a result of TopFiles, with the order it was found in
+type topResult struct {
+	SearchResult
+	rank int
+}
+
+// topResults is a heap of the best results found so far, with the last one of the list on top
+type topResults struct {
+	order   FileOrder
+	results []topResult
+	count   int
+}
+
+// before returns true when a comes before b in the list: a larger value first, or the first found on a tie
+func (t *topResults) before(a, b topResult) bool {
+	switch {
+	case t.order.less(b.Entry, a.Entry):
+		return true
+	case t.order.less(a.Entry, b.Entry):
+		return false
+	default:
+		return a.rank < b.rank
+	}
+}
+
+func (t *topResults) Len() int           { return len(t.results) }
+func (t *topResults) Less(i, j int) bool { return t.before(t.results[j], t.results[i]) }
+func (t *topResults) Swap(i, j int)      { t.results[i], t.results[j] = t.results[j], t.results[i] }
+func (t *topResults) Push(x any)         { t.results = append(t.results, x.(topResult)) }
+func (t *topResults) Pop() any {
+	last := t.results[len(t.results)-1]
+	t.results = t.results[:len(t.results)-1]
+	return last
+}
+
+// forEachVolume runs the function in a read-only transaction on the root of the buckets of each volume
+func (d *Database) forEachVolume(fn func(record VolumeRecord, root store.Bucketeer) error) error {
+	volumes, err := d.Volumes()
+	if err != nil {
+		return err
+	}
+	for _, record := range volumes {
+		err = d.viewVolume(record.ID, func(root store.Bucketeer) error {
+			return fn(record, root)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultsOf returns a function sending the files of the volume as search results
+func resultsOf(record VolumeRecord, fn func(result SearchResult) error) func(filePath string, entry FileEntry) error {
+	return func(filePath string, entry FileEntry) error {
+		return fn(SearchResult{
+			Volume: record,
+			Path:   filePath,
+			Entry:  entry,
+		})
+	}
+}
+
+// indexFiles adds the regular files of a batch, sorted by path, to the secondary indexes.
+// The keys of each index are written in order.
+func indexFiles(root store.Bucketeer, sorted []batchFile) error {
+	regular := make([]batchFile, 0, len(sorted))
+	for _, file := range sorted {
+		if !file.entry.Mode.IsDir() {
+			regular = append(regular, file)
+		}
+	}
+	for _, index := range []fileIndex{indexBySize, indexByModTime} {
+		bucket, err := root.GetBucket(index.bucket)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, len(regular))
+		for i, file := range regular {
+			keys[i] = index.key(file.entry) + file.path
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if err = bucket.Put(key, []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the paths of each extension keep the order of the batch
+	byExtension := make(map[string][]string)
+	names := make([]string, 0)
+	for _, file := range regular {
+		extension := fileExtension(file.path)
+		if extension == "" {
+			continue
+		}
+		if _, found := byExtension[extension]; !found {
+			names = append(names, extension)
+		}
+		byExtension[extension] = append(byExtension[extension], file.path)
+	}
+	slices.Sort(names)
+	extensions, err := root.GetBucket(BucketByExtension)
+	if err != nil {
+		return err
+	}
+	for _, extension := range names {
+		bucket, err := extensions.GetBucket(extension)
+		if errors.Is(err, store.ErrBucketNotFound) {
+			bucket, err = extensions.CreateBucket(extension)
+		}
+		if err != nil {
+			return err
+		}
+		for _, filePath := range byExtension[extension] {
+			if err = bucket.Put(filePath, []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebuildIndexes replaces the search index and the secondary indexes of the volume with new ones built from its files.
+// The files are indexed in batches of IndexBatchSize files, each saved in its own transaction of the storage
+// on the root returned by volumeRoot. The progress is saved with each batch: an interrupted rebuild resumes
+// after the last batch saved.
+func rebuildIndexes(storage store.Store, volumeRoot func(transaction store.Transaction) (store.Bucketeer, error)) error {
+	for done := false; !done; {
+		err := storage.Update(func(transaction store.Transaction) error {
+			root, err := volumeRoot(transaction)
+			if err != nil {
+				return err
+			}
+			done, err = rebuildNextBatch(root, IndexBatchSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildNextBatch adds the next batch of files of the volume to the indexes, in the order of the paths.
+// It returns true once all the files are indexed.
+func rebuildNextBatch(root store.Bucketeer, size int) (bool, error) {
+	progressBucket, err := root.GetBucket(BucketRebuild)
+	progress := rebuildProgress{}
+	if errors.Is(err, store.ErrBucketNotFound) {
+		// the first batch starts from empty indexes
+		if err = resetIndexes(root); err != nil {
+			return false, err
+		}
+		progressBucket, err = root.CreateBucket(BucketRebuild)
+	} else if err == nil {
+		progress, err = recordRebuild.Get(progressBucket)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	bucket, err := root.GetBucket(BucketFiles)
+	if err != nil {
+		return false, err
+	}
+	batch := make([]batchFile, 0, size)
+	err = newFilesBucket(bucket).ForEachRange(progress.LastPath, "", func(filePath string, entry FileEntry) error {
+		if progress.LastID > 0 && filePath == progress.LastPath {
+			return nil
+		}
+		if len(batch) == size {
+			return errIndexLimit
+		}
+		batch = append(batch, batchFile{id: progress.LastID + uint64(len(batch)) + 1, path: filePath, entry: entry})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errIndexLimit) {
+		return false, err
+	}
+	// the files are read in the order of the paths
+	if err = saveIndexes(root, batch, batch); err != nil {
+		return false, err
+	}
+	if len(batch) < size {
+		return true, root.DeleteBucket(BucketRebuild)
+	}
+	last := batch[len(batch)-1]
+	return false, recordRebuild.Put(progressBucket, rebuildProgress{LastPath: last.path, LastID: last.id})
+}
+
+// resetIndexes replaces the buckets of the indexes of the volume with empty ones
+func resetIndexes(root store.Bucketeer) error {
+	for _, name := range indexBuckets {
+		err := root.DeleteBucket(name)
+		if err != nil && !errors.Is(err, store.ErrBucketNotFound) {
+			return err
+		}
+		if _, err = root.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasIndexes returns true when the volume has all the buckets of the search index and of the secondary indexes,
+// and no rebuild of the indexes is in progress
+func hasIndexes(root store.Bucketeer) (bool, error) {
+	_, err := root.GetBucket(BucketRebuild)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, store.ErrBucketNotFound) {
+		return false, err
+	}
+	for _, name := range indexBuckets {
+		_, err := root.GetBucket(name)
+		if errors.Is(err, store.ErrBucketNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// scanIndex runs the function on the regular files of the volume with a key from "from" (inclusive)
+// to "to" (exclusive), in the order of the index or backwards. A blank "to" means until the end of the index.
+func scanIndex(root store.Bucketeer, index fileIndex, from, to string, backwards bool, fn func(filePath string, entry FileEntry) error) error {
+	bucket, err := root.GetBucket(BucketFiles)
+	if err != nil {
+		return err
+	}
+	files := newFilesBucket(bucket)
+	indexBucket, err := root.GetBucket(index.bucket)
+	if err != nil {
+		return err
+	}
+
+	visit := func(key string) error {
+		filePath := key[index.width:]
+		entry, err := files.Get(filePath)
+		if err != nil {
+			return err
+		}
+		return fn(filePath, entry)
+	}
+	if !backwards {
+		return indexBucket.ForEachRange(from, to, func(key string, _ []byte) error {
+			return visit(key)
+		})
+	}
+	cursor := indexBucket.Cursor()
+	var key string
+	if to == "" {
+		key, _ = cursor.Last()
+	} else if key, _ = cursor.Seek(to); key == "" {
+		key, _ = cursor.Last()
+	} else {
+		key, _ = cursor.Prev()
+	}
+	for ; key != "" && key >= from; key, _ = cursor.Prev() {
+		if err := visit(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanExtension runs the function on the regular files of the volume with the lowercase extension
+func scanExtension(root store.Bucketeer, extension string, fn func(filePath string, entry FileEntry) error) error {
+	bucket, err := root.GetBucket(BucketFiles)
+	if err != nil {
+		return err
+	}
+	files := newFilesBucket(bucket)
+	extensions, err := root.GetBucket(BucketByExtension)
+	if err != nil {
+		return err
+	}
+	extensionBucket, err := extensions.GetBucket(extension)
+	if errors.Is(err, store.ErrBucketNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return extensionBucket.ForEach(func(filePath string, _ []byte) error {
+		entry, err := files.Get(filePath)
+		if err != nil {
+			return err
+		}
+		return fn(filePath, entry)
+	})
+}
+
+// fileExtension returns the lowercase extension of the file without the dot.
+// A hidden file like ".profile" has no extension.
+func fileExtension(filePath string) string {
+	name := path.Base(filePath)
+	extension := path.Ext(name)
+	if extension == name {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(extension, "."))
+}
+
+// sizeKey returns the size as an 8 bytes big-endian key. A negative size is the same as zero.
+func sizeKey(size int64) string {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(max(size, 0)))
+	return string(key)
+}
+
+// timeKey returns the time as a 12 bytes key: the seconds since 1970 with the sign bit flipped
+// so the times before 1970 come first, followed by the nanoseconds
+func timeKey(t time.Time) string {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key[0:8], uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(key[8:12], uint32(t.Nanosecond()))
+	return string(key)
+}
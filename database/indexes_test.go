@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"io/fs"
+	"math"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/index"
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+)
+
+// indexMapFS indexes all the files of fsys as a new volume included in search
+func indexMapFS(t *testing.T, db *Database, name string, fsys fstest.MapFS) uuid.UUID {
+	t.Helper()
+
+	files := make(chan index.FileIndexed, 100)
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files <- index.FileIndexed{Path: path, Info: info}
+		return nil
+	})
+	require.NoError(t, err)
+	close(files)
+
+	volumeID, err := db.IndexVolume(context.Background(), &volume.Volume{Name: name, IncludeInSearch: true}, files)
+	require.NoError(t, err)
+	return volumeID
+}
+
+// day returns midnight of the day in 2019
+func day(month time.Month, day int) time.Time {
+	return time.Date(2019, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// indexPhotos indexes a volume with files of various sizes, dates and extensions
+func indexPhotos(t *testing.T, db *Database, name string, sizeFactor int) uuid.UUID {
+	t.Helper()
+
+	return indexMapFS(t, db, name, fstest.MapFS{
+		"photos":             &fstest.MapFile{Mode: fs.ModeDir, ModTime: day(time.April, 1)},
+		"photos/small.JPG":   &fstest.MapFile{Data: make([]byte, 10*sizeFactor), ModTime: day(time.March, 2)},
+		"photos/large.jpg":   &fstest.MapFile{Data: make([]byte, 100*sizeFactor), ModTime: day(time.March, 31)},
+		"photos/medium.cr2":  &fstest.MapFile{Data: make([]byte, 50*sizeFactor), ModTime: day(time.February, 1)},
+		"photos/.hidden":     &fstest.MapFile{Data: make([]byte, 1), ModTime: time.Time{}},
+		"photos/old.tar.gz":  &fstest.MapFile{Data: make([]byte, 20*sizeFactor), ModTime: time.Date(1969, time.July, 21, 2, 56, 0, 0, time.UTC)},
+		"photos/empty.jpeg":  &fstest.MapFile{ModTime: day(time.March, 1)},
+		"photos/empty/dir":   &fstest.MapFile{Mode: fs.ModeDir, ModTime: day(time.March, 15)},
+		"photos/recent.heic": &fstest.MapFile{Data: make([]byte, 30*sizeFactor), ModTime: day(time.December, 31)},
+	})
+}
+
+// resultPaths returns the volume name and the path of each result
+func resultPaths(results []SearchResult) []string {
+	found := make([]string, len(results))
+	for i, result := range results {
+		found[i] = result.Volume.Volume.Name + ":" + result.Path
+	}
+	return found
+}
+
+// collectResults returns a function saving each result into the list
+func collectResults(results *[]SearchResult) func(result SearchResult) error {
+	return func(result SearchResult) error {
+		*results = append(*results, result)
+		return nil
+	}
+}
+
+func TestKeysKeepTheOrder(t *testing.T) {
+	t.Parallel()
+
+	assert.Less(t, sizeKey(0), sizeKey(1))
+	assert.Less(t, sizeKey(255), sizeKey(256))
+	assert.Equal(t, sizeKey(0), sizeKey(-1))
+
+	times := []time.Time{
+		{},
+		time.Date(1969, time.December, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(1969, time.December, 31, 23, 59, 59, 1, time.UTC),
+		time.Unix(0, 0),
+		time.Unix(0, 999),
+		time.Unix(1, 0),
+		time.Date(2262, time.April, 12, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		assert.Less(t, timeKey(times[i-1]), timeKey(times[i]), times[i].String())
+	}
+}
+
+func TestFileExtension(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "jpg", fileExtension("dir/photo.JPG"))
+	assert.Equal(t, "gz", fileExtension("archive.tar.gz"))
+	assert.Equal(t, "", fileExtension("dir/.profile"))
+	assert.Equal(t, "", fileExtension("dir.d/README"))
+	assert.Equal(t, "txt", fileExtension(".notes.txt"))
+}
+
+func TestSecondaryIndexes(t *testing.T) {
+	t.Parallel()
+
+	for _, sharded := range []bool{false, true} {
+		db := newTestDatabase(t)
+		if sharded {
+			db = newShardedDatabase(t, t.TempDir())
+		}
+		indexPhotos(t, db, "first", 1)
+		indexPhotos(t, db, "second", 10)
+
+		results := make([]SearchResult, 0)
+		require.NoError(t, db.FilesBySize(20, 250, collectResults(&results)))
+		assert.Equal(t, []string{"first:photos/old.tar.gz", "first:photos/recent.heic", "first:photos/medium.cr2",
+			"first:photos/large.jpg", "second:photos/small.JPG", "second:photos/old.tar.gz"}, resultPaths(results))
+
+		results = results[:0]
+		require.NoError(t, db.FilesBySize(500, math.MaxInt64, collectResults(&results)))
+		assert.Equal(t, []string{"second:photos/medium.cr2", "second:photos/large.jpg"}, resultPaths(results))
+		assert.Equal(t, int64(1000), results[1].Entry.Size)
+
+		results = results[:0]
+		require.NoError(t, db.FilesByModTime(day(time.March, 1), day(time.April, 1), collectResults(&results)))
+		assert.Equal(t, []string{"first:photos/empty.jpeg", "first:photos/small.JPG", "first:photos/large.jpg",
+			"second:photos/empty.jpeg", "second:photos/small.JPG", "second:photos/large.jpg"}, resultPaths(results))
+
+		results = results[:0]
+		require.NoError(t, db.FilesByExtension(".JPG", collectResults(&results)))
+		assert.Equal(t, []string{"first:photos/large.jpg", "first:photos/small.JPG",
+			"second:photos/large.jpg", "second:photos/small.JPG"}, resultPaths(results))
+
+		results = results[:0]
+		require.NoError(t, db.FilesByExtension("png", collectResults(&results)))
+		assert.Empty(t, results)
+
+		top, err := db.TopFiles(BySize, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"second:photos/large.jpg", "second:photos/medium.cr2", "second:photos/recent.heic"}, resultPaths(top))
+
+		top, err = db.TopFiles(ByModTime, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first:photos/recent.heic", "second:photos/recent.heic", "first:photos/large.jpg"}, resultPaths(top))
+
+		top, err = db.TopFiles(ByModTime, 100)
+		require.NoError(t, err)
+		assert.Len(t, top, 14)
+		assert.Equal(t, "first:photos/.hidden", resultPaths(top)[12])
+	}
+}
+
+func TestSecondaryIndexesOfOldVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexPhotos(t, db, "old", 1)
+	indexPhotos(t, db, "new", 10)
+
+	// volume indexed before the secondary indexes were added
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		volumeBucket, err := getVolumeBucket(transaction, volumeID)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{BucketBySize, BucketByModTime, BucketByExtension} {
+			if err := volumeBucket.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	migrateFrom(t, db, Version{1, 1})
+
+	results := make([]SearchResult, 0)
+	require.NoError(t, db.FilesBySize(20, 250, collectResults(&results)))
+	assert.Equal(t, []string{"new:photos/small.JPG", "new:photos/old.tar.gz",
+		"old:photos/old.tar.gz", "old:photos/recent.heic", "old:photos/medium.cr2", "old:photos/large.jpg"}, resultPaths(results))
+
+	results = results[:0]
+	require.NoError(t, db.FilesByExtension("jpg", collectResults(&results)))
+	assert.Equal(t, []string{"new:photos/large.jpg", "new:photos/small.JPG", "old:photos/large.jpg", "old:photos/small.JPG"},
+		resultPaths(results))
+
+	top, err := db.TopFiles(ByModTime, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new:photos/recent.heic", "old:photos/recent.heic"}, resultPaths(top))
+}
+
+func TestRebuildIndexesResumes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexPhotos(t, db, "photos", 1)
+	volumeRoot := func(transaction store.Transaction) (store.Bucketeer, error) {
+		return getVolumeBucket(transaction, volumeID)
+	}
+	rebuild := func() bool {
+		done := false
+		err := db.storage.Update(func(transaction store.Transaction) error {
+			root, err := volumeRoot(transaction)
+			if err != nil {
+				return err
+			}
+			done, err = rebuildNextBatch(root, 3)
+			return err
+		})
+		require.NoError(t, err)
+		return done
+	}
+	indexed := func() bool {
+		indexed := false
+		err := db.storage.View(func(transaction store.Transaction) error {
+			root, err := volumeRoot(transaction)
+			if err != nil {
+				return err
+			}
+			indexed, err = hasIndexes(root)
+			return err
+		})
+		require.NoError(t, err)
+		return indexed
+	}
+
+	// the rebuild is interrupted after the first batch
+	assert.False(t, rebuild())
+	assert.False(t, indexed())
+
+	// the 10 entries of the volume need 4 batches
+	assert.False(t, rebuild())
+	assert.False(t, rebuild())
+	assert.True(t, rebuild())
+	assert.True(t, indexed())
+
+	results := make([]SearchResult, 0)
+	require.NoError(t, db.FilesBySize(20, 250, collectResults(&results)))
+	assert.Equal(t, []string{"photos:photos/old.tar.gz", "photos:photos/recent.heic", "photos:photos/medium.cr2",
+		"photos:photos/large.jpg"}, resultPaths(results))
+	assert.Equal(t, []string{"photos:photos/empty", "photos:photos/empty.jpeg", "photos:photos/empty/dir"},
+		searchPaths(t, db, "empty"))
+
+	// a rebuild in one go gives the same indexes
+	require.NoError(t, rebuildIndexes(db.storage, volumeRoot))
+	assert.Equal(t, []string{"photos:photos/empty", "photos:photos/empty.jpeg", "photos:photos/empty/dir"},
+		searchPaths(t, db, "empty"))
+}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNewerVersion    = errors.New("The database was created by a newer version of the application")
+	ErrNoMigrationPath = errors.New("No migration available for this database version")
+)
+
+// Migration converts a database from one version to the next
+type Migration struct {
+	From        Version
+	To          Version
+	Description string
+	Migrate     func(transaction store.Transaction) error
+	// MigrateVolume converts the buckets of a volume, in as many transactions of the storage as it needs:
+	// volumeRoot returns the root of the buckets of the volume in a transaction. The volumes are converted
+	// before the transaction of the migration, and are not restored if the migration fails:
+	// the step must give the same result when it runs again.
+	MigrateVolume func(storage store.Store, volumeRoot func(transaction store.Transaction) (store.Bucketeer, error)) error
+}
+
+// Migrations is a list of migration steps
+type Migrations []Migration
+
+// registry holds all the migrations steps of the database
+var registry Migrations
+
+func init() {
+	RegisterMigration(Migration{
+		From:        Version{1, 0},
+		To:          Version{1, 1},
+		Description: "Add the layout of the new volumes: the existing volumes stay in the main database",
+		Migrate:     addShardedSetting,
+	})
+	RegisterMigration(Migration{
+		From:          Version{1, 1},
+		To:            Version{1, 2},
+		Description:   "Rebuild the search index and the secondary indexes of the files of each volume",
+		MigrateVolume: rebuildIndexes,
+	})
+}
+
+// RegisterMigration adds a migration step to the registry
+func RegisterMigration(migration Migration) {
+	registry = append(registry, migration)
+}
+
+// Plan returns the migration steps to go from version "from" to version "to".
+// It returns an empty plan when the versions are the same, and an error if there's no way to reach the target version.
+func (m Migrations) Plan(from, to Version) ([]Migration, error) {
+	if from.Major > to.Major {
+		return nil, fmt.Errorf("%w: database version %s, supported version %s", ErrNewerVersion, from, to)
+	}
+	plan := make([]Migration, 0)
+	current := from
+	for current != to {
+		step, found := m.from(current)
+		if !found {
+			return nil, fmt.Errorf("%w: from version %s to version %s", ErrNoMigrationPath, current, to)
+		}
+		plan = append(plan, step)
+		current = step.To
+		if len(plan) > len(m) {
+			return nil, fmt.Errorf("%w: loop detected from version %s", ErrNoMigrationPath, current)
+		}
+	}
+	return plan, nil
+}
+
+// from returns the step starting at the version
+func (m Migrations) from(version Version) (Migration, bool) {
+	for _, migration := range m {
+		if migration.From == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// String returns the version as "major.minor"
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// MigrationPlan returns the steps needed to bring the database to the current version.
+// A database from a newer minor version is accepted as it is, but a newer major version returns ErrNewerVersion.
+func (d *Database) MigrationPlan() ([]Migration, error) {
+	version, err := d.version()
+	if err != nil {
+		return nil, err
+	}
+	if version.Major == CurrentVersion.Major && version.Minor >= CurrentVersion.Minor {
+		return nil, nil
+	}
+	return d.migrations.Plan(version, CurrentVersion)
+}
+
+// Migrate runs all the steps of the plan in a single transaction: the database is left untouched if any step fails.
+// The volumes are converted first, in their own transactions. The shards are not restored when the migration fails:
+// the error lists the volumes with a shard already converted.
+func (d *Database) Migrate(plan []Migration) error {
+	if len(plan) == 0 {
+		return nil
+	}
+	converted, err := d.migrateVolumes(plan)
+	if err == nil {
+		err = d.migrateDatabase(plan)
+	}
+	if err != nil && len(converted) > 0 {
+		names := make([]string, len(converted))
+		for i, volumeID := range converted {
+			names[i] = volumeID.String()
+		}
+		return fmt.Errorf("%w (shards already converted: %s)", err, strings.Join(names, ", "))
+	}
+	return err
+}
+
+// migrateDatabase runs the steps of the plan in a transaction of the database, and saves the new version
+func (d *Database) migrateDatabase(plan []Migration) error {
+	return d.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		version, err := statVersion.Get(stats)
+		if err != nil {
+			return err
+		}
+		if version != plan[0].From {
+			return fmt.Errorf("the database version %s has changed since the migration was planned", version)
+		}
+		for _, step := range plan {
+			if step.Migrate == nil {
+				continue
+			}
+			err = step.Migrate(transaction)
+			if err != nil {
+				return fmt.Errorf("migration from version %s to %s failed: %w", step.From, step.To, err)
+			}
+		}
+		return statVersion.Put(stats, plan[len(plan)-1].To)
+	})
+}
+
+// migrateVolumes runs the volume steps of the plan on each volume: in the database, or in its shard.
+// It returns the IDs of the volumes with a shard converted.
+func (d *Database) migrateVolumes(plan []Migration) ([]uuid.UUID, error) {
+	steps := make([]Migration, 0, len(plan))
+	for _, step := range plan {
+		if step.MigrateVolume != nil {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	var inline, sharded []uuid.UUID
+	err := d.storage.View(func(transaction store.Transaction) error {
+		var err error
+		if inline, err = listVolumes(transaction, false); err != nil {
+			return err
+		}
+		sharded, err = listVolumes(transaction, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sharded) > 0 && d.shards == nil {
+		return nil, ErrNoShards
+	}
+
+	migrate := func(volumeID uuid.UUID, storage store.Store, volumeRoot func(transaction store.Transaction) (store.Bucketeer, error)) error {
+		for _, step := range steps {
+			if err := step.MigrateVolume(storage, volumeRoot); err != nil {
+				return fmt.Errorf("migration from version %s to %s failed: volume %s: %w", step.From, step.To, volumeID, err)
+			}
+		}
+		return nil
+	}
+	for _, volumeID := range inline {
+		err = migrate(volumeID, d.storage, func(transaction store.Transaction) (store.Bucketeer, error) {
+			return getVolumeBucket(transaction, volumeID)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	converted := make([]uuid.UUID, 0, len(sharded))
+	for _, volumeID := range sharded {
+		storage, err := d.shards.get(volumeID, false)
+		if err != nil {
+			return converted, fmt.Errorf("volume %s: %w", volumeID, err)
+		}
+		err = migrate(volumeID, storage, shardRoot)
+		if err != nil {
+			return converted, err
+		}
+		converted = append(converted, volumeID)
+	}
+	return converted, nil
+}
+
+// listVolumes returns the IDs of the sharded volumes, or of the volumes saved in the database
+func listVolumes(transaction store.Transaction, sharded bool) ([]uuid.UUID, error) {
+	volumes, err := transaction.GetBucket(BucketVolumes)
+	if err != nil {
+		return nil, err
+	}
+	volumeIDs := make([]uuid.UUID, 0)
+	err = volumes.ForEachBucket(func(name string) error {
+		volumeID, err := uuid.Parse(name)
+		if err != nil {
+			return err
+		}
+		volumeBucket, err := volumes.GetBucket(name)
+		if err != nil {
+			return err
+		}
+		_, err = volumeBucket.Get(KeyShard)
+		if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+			return err
+		}
+		if (err == nil) == sharded {
+			volumeIDs = append(volumeIDs, volumeID)
+		}
+		return nil
+	})
+	return volumeIDs, err
+}
+
+// version returns the version saved in the database
+func (d *Database) version() (Version, error) {
+	var version Version
+	err := d.storage.View(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		version, err = statVersion.Get(stats)
+		return err
+	})
+	return version, err
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDatabaseWithVersion returns an initialized database saved with the version
+func newDatabaseWithVersion(t *testing.T, version Version, migrations Migrations) *Database {
+	t.Helper()
+
+	memory := store.NewMemoryStore()
+	t.Cleanup(memory.Close)
+
+	db := NewDatabase(memory)
+	db.migrations = migrations
+	require.NoError(t, db.Init())
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return statVersion.Put(stats, version)
+	})
+	require.NoError(t, err)
+	return db
+}
+
+// migrateFrom saves the version in the database, then migrates the database to the current version
+func migrateFrom(t *testing.T, db *Database, version Version) {
+	t.Helper()
+
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return statVersion.Put(stats, version)
+	})
+	require.NoError(t, err)
+	plan, err := db.MigrationPlan()
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(plan))
+}
+
+// markStep returns a migration step leaving a key in the stats bucket
+func markStep(from, to Version, key string) Migration {
+	return Migration{
+		From:        from,
+		To:          to,
+		Description: key,
+		Migrate: func(transaction store.Transaction) error {
+			stats, err := transaction.GetBucket(BucketStats)
+			if err != nil {
+				return err
+			}
+			return stats.Put(key, []byte(to.String()))
+		},
+	}
+}
+
+func TestMigrationPlan(t *testing.T) {
+	t.Parallel()
+
+	migrations := Migrations{
+		markStep(Version{0, 2}, CurrentVersion, "step2"),
+		markStep(Version{0, 1}, Version{0, 2}, "step1"),
+	}
+	testData := []struct {
+		version Version
+		steps   []string
+		err     error
+	}{
+		{CurrentVersion, []string{}, nil},
+		{Version{CurrentVersion.Major, CurrentVersion.Minor + 1}, []string{}, nil},
+		{Version{0, 1}, []string{"step1", "step2"}, nil},
+		{Version{0, 2}, []string{"step2"}, nil},
+		{Version{0, 0}, nil, ErrNoMigrationPath},
+		{Version{CurrentVersion.Major + 1, 0}, nil, ErrNewerVersion},
+	}
+	for _, testItem := range testData {
+		t.Run(testItem.version.String(), func(t *testing.T) {
+			t.Parallel()
+
+			db := newDatabaseWithVersion(t, testItem.version, migrations)
+			plan, err := db.MigrationPlan()
+			if testItem.err != nil {
+				assert.ErrorIs(t, err, testItem.err)
+				return
+			}
+			require.NoError(t, err)
+			steps := make([]string, 0, len(plan))
+			for _, step := range plan {
+				steps = append(steps, step.Description)
+			}
+			assert.Equal(t, testItem.steps, steps)
+		})
+	}
+}
+
+func TestMigrationLoop(t *testing.T) {
+	t.Parallel()
+
+	migrations := Migrations{
+		markStep(Version{0, 1}, Version{0, 2}, "step1"),
+		markStep(Version{0, 2}, Version{0, 1}, "step2"),
+	}
+	_, err := migrations.Plan(Version{0, 1}, Version{1, 0})
+	assert.ErrorIs(t, err, ErrNoMigrationPath)
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	migrations := Migrations{
+		markStep(Version{0, 1}, Version{0, 2}, "step1"),
+		markStep(Version{0, 2}, CurrentVersion, "step2"),
+	}
+	db := newDatabaseWithVersion(t, Version{0, 1}, migrations)
+	plan, err := db.MigrationPlan()
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(plan))
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, stats.Version)
+
+	err = db.storage.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket(BucketStats)
+		require.NoError(t, err)
+		for _, key := range []string{"step1", "step2"} {
+			_, err := bucket.Get(key)
+			assert.NoError(t, err)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// nothing left to do
+	plan, err = db.MigrationPlan()
+	require.NoError(t, err)
+	assert.Empty(t, plan)
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.New("step failed")
+	migrations := Migrations{
+		markStep(Version{0, 1}, Version{0, 2}, "step1"),
+		{
+			From: Version{0, 2},
+			To:   CurrentVersion,
+			Migrate: func(transaction store.Transaction) error {
+				return failure
+			},
+		},
+	}
+	db := newDatabaseWithVersion(t, Version{0, 1}, migrations)
+	plan, err := db.MigrationPlan()
+	require.NoError(t, err)
+	err = db.Migrate(plan)
+	assert.ErrorIs(t, err, failure)
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, Version{0, 1}, stats.Version)
+
+	err = db.storage.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket(BucketStats)
+		require.NoError(t, err)
+		_, err = bucket.Get("step1")
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
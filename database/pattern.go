@@ -0,0 +1,38 @@
+package database
+
+import (
+	"github.com/creativeprojects/catalogue/query"
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// SearchPattern runs the function on each file and directory with a path matching the pattern, as soon as it's found,
+// across all the volumes included in search.
+// When the pattern starts with a literal text, only the paths starting with this text are read.
+func (d *Database) SearchPattern(pattern *query.Pattern, fn func(result SearchResult) error) error {
+	volumes, err := d.searchedVolumes()
+	if err != nil {
+		return err
+	}
+	for _, record := range volumes {
+		err = d.ViewFiles(record.ID, func(files *store.TypedBucket[string, FileEntry]) error {
+			match := func(path string, entry FileEntry) error {
+				if !pattern.Match(path) {
+					return nil
+				}
+				return fn(SearchResult{
+					Volume: record,
+					Path:   path,
+					Entry:  entry,
+				})
+			}
+			if prefix := pattern.Prefix(); prefix != "" {
+				return files.ForEachPrefix(prefix, match)
+			}
+			return files.ForEach(match)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
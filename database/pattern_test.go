@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/query"
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// patternPaths returns the volume name and the path of each file matching the pattern
+func patternPaths(t *testing.T, db *Database, pattern *query.Pattern) []string {
+	t.Helper()
+
+	found := make([]string, 0)
+	err := db.SearchPattern(pattern, func(result SearchResult) error {
+		found = append(found, result.Volume.Volume.Name+":"+result.Path)
+		return nil
+	})
+	require.NoError(t, err)
+	return found
+}
+
+func TestSearchPattern(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "first")
+	sharded := newShardedDatabase(t, t.TempDir())
+	indexTestFiles(t, sharded, "first")
+
+	for _, db := range []*Database{db, sharded} {
+		indexTestFiles(t, db, "second")
+
+		glob, err := query.CompileGlob("dir/*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first:dir/.hidden", "first:dir/file", "second:dir/.hidden", "second:dir/file"},
+			patternPaths(t, db, glob))
+
+		glob, err = query.CompileGlob("**/f*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first:dir/file", "second:dir/file"}, patternPaths(t, db, glob))
+
+		regex, err := query.CompileRegex(`^dir$|\.hid`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first:dir", "first:dir/.hidden", "second:dir", "second:dir/.hidden"},
+			patternPaths(t, db, regex))
+	}
+}
+
+func TestSearchPatternSkipsExcludedVolumes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "included")
+	indexExcludedTestFiles(t, db, "excluded")
+
+	glob, err := query.CompileGlob("dir/f*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"included:dir/file"}, patternPaths(t, db, glob))
+}
+
+func TestSearchPatternStopsEarly(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "first")
+	indexTestFiles(t, db, "second")
+
+	regex, err := query.CompileRegex("^dir/")
+	require.NoError(t, err)
+	stop := errors.New("stop")
+	count := 0
+	err = db.SearchPattern(regex, func(result SearchResult) error {
+		count++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, count)
+}
+
+func TestSearchPatternReadsThePrefixOnly(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexTestFiles(t, db, "test")
+
+	// a record which cannot be decoded outside of the prefix
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		files, err := getFilesBucket(transaction, volumeID)
+		if err != nil {
+			return err
+		}
+		return files.Bucket().Put("other", []byte{1})
+	})
+	require.NoError(t, err)
+
+	regex, err := query.CompileRegex("^dir/f")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test:dir/file"}, patternPaths(t, db, regex))
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"github.com/creativeprojects/catalogue/query"
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// Query runs the function on each file and directory matching the expression, volume by volume.
+// The files of a volume are not read when the expression cannot match any of them.
+func (d *Database) Query(expr query.Expr, fn func(result SearchResult) error) error {
+	volumes, err := d.Volumes()
+	if err != nil {
+		return err
+	}
+	for _, record := range volumes {
+		match := expr.MatchVolume(&record.Volume)
+		if match == query.MatchNone {
+			continue
+		}
+		err = d.ViewFiles(record.ID, func(files *store.TypedBucket[string, FileEntry]) error {
+			return files.ForEach(func(path string, entry FileEntry) error {
+				if match != query.MatchAll && !expr.Match(&query.File{
+					Volume:  &record.Volume,
+					Path:    path,
+					Size:    entry.Size,
+					Mode:    entry.Mode,
+					ModTime: entry.ModTime,
+				}) {
+					return nil
+				}
+				return fn(SearchResult{
+					Volume: record,
+					Path:   path,
+					Entry:  entry,
+				})
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/query"
+)
+
+// queryPaths returns the volume name and the path of each file matching the query
+func queryPaths(t *testing.T, db *Database, input string) []string {
+	t.Helper()
+
+	expr, err := query.Parse(input)
+	require.NoError(t, err)
+	found := make([]string, 0)
+	err = db.Query(expr, func(result SearchResult) error {
+		found = append(found, result.Volume.Volume.Name+":"+result.Path)
+		return nil
+	})
+	require.NoError(t, err)
+	return found
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexExcludedTestFiles(t, db, "first")
+	sharded := newShardedDatabase(t, t.TempDir())
+	indexExcludedTestFiles(t, sharded, "first")
+
+	for _, db := range []*Database{db, sharded} {
+		indexExcludedTestFiles(t, db, "second")
+
+		// the test volumes are not included in search
+		assert.Empty(t, queryPaths(t, db, "file"))
+
+		assert.Equal(t, []string{"first:dir/file", "second:dir/file"}, queryPaths(t, db, "included:no name:f*"))
+		assert.Equal(t, []string{"second:dir/.hidden"}, queryPaths(t, db, "included:no volume:second size:<10 type:file"))
+		assert.Equal(t, []string{"first:.", "first:dir"}, queryPaths(t, db, "included:no type:dir NOT volume:sec*"))
+		assert.Equal(t, []string{"second:.", "second:dir", "second:dir/.hidden", "second:dir/file"},
+			queryPaths(t, db, "included:no volume:second"))
+	}
+}
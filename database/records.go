@@ -0,0 +1,180 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/creativeprojects/catalogue/codec"
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/google/uuid"
+)
+
+// Keys of the BucketStats bucket
+var (
+	statDatabaseID       = store.NewTypedKey(KeyDatabaseID, codec.Marshaler[uuid.UUID]())
+	statVersion          = store.NewTypedKey(KeyVersion, codec.Binary[Version]())
+	statTotalVolumes     = store.NewTypedKey(KeyTotalVolumes, codec.Binary[uint64]())
+	statTotalDirectories = store.NewTypedKey(KeyTotalDirectories, codec.Binary[uint64]())
+	statTotalFiles       = store.NewTypedKey(KeyTotalFiles, codec.Binary[uint64]())
+	statCreated          = store.NewTypedKey(KeyCreated, codec.Marshaler[time.Time]())
+	statLastSaved        = store.NewTypedKey(KeyLastSaved, codec.Marshaler[time.Time]())
+	statSharded          = store.NewTypedKey(KeySharded, codec.Binary[bool]())
+)
+
+// recordVolume is the volume information saved in the bucket of each volume, and in the header of its shard
+var recordVolume = store.NewTypedKey(KeyVolume, codec.JSON[volume.Volume]())
+
+// recordShard marks a volume saved in a shard, with the totals needed to detach it. It's also saved in the header of the shard.
+var recordShard = store.NewTypedKey(KeyShard, codec.JSON[volumeTotals]())
+
+// recordVolumeID is the ID of the volume saved in the header of a shard
+var recordVolumeID = store.NewTypedKey(KeyVolumeID, codec.Marshaler[uuid.UUID]())
+
+// recordRebuild is the progress of the rebuild of the indexes of a volume, saved in the BucketRebuild bucket
+var recordRebuild = store.NewTypedKey(KeyRebuild, codec.JSON[rebuildProgress]())
+
+// rebuildProgress is the last file added to the indexes of a volume being rebuilt
+type rebuildProgress struct {
+	LastPath string
+	LastID   uint64
+}
+
+// volumeTotals is the number of entries of a volume
+type volumeTotals struct {
+	Directories uint64
+	Files       uint64
+	Hidden      uint64
+}
+
+// update saves the number of files into the volume
+func (t volumeTotals) update(vol *volume.Volume) {
+	vol.RegularFiles = t.Files - t.Hidden
+	vol.HiddenFiles = t.Hidden
+}
+
+// newFilesBucket returns the files of a volume indexed by path
+func newFilesBucket(bucket store.Bucket) *store.TypedBucket[string, FileEntry] {
+	return store.NewTypedBucket(bucket, codec.StringKey(), codec.Marshaler[FileEntry]())
+}
+
+// loadStats reads all the statistics from the BucketStats bucket
+func loadStats(bucket store.Bucket) (Stats, error) {
+	var stats Stats
+	var err error
+	if stats.DatabaseID, err = statDatabaseID.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.Version, err = statVersion.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.TotalVolumes, err = statTotalVolumes.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.TotalDirectories, err = statTotalDirectories.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.TotalFiles, err = statTotalFiles.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.Created, err = statCreated.Get(bucket); err != nil {
+		return stats, err
+	}
+	if stats.LastSaved, err = statLastSaved.Get(bucket); err != nil {
+		return stats, err
+	}
+	// the layout was added in version 1.1
+	if stats.Sharded, err = statSharded.Get(bucket); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// saveStats writes all the statistics into the BucketStats bucket
+func saveStats(bucket store.Bucket, stats Stats) error {
+	return errors.Join(
+		statDatabaseID.Put(bucket, stats.DatabaseID),
+		statVersion.Put(bucket, stats.Version),
+		statTotalVolumes.Put(bucket, stats.TotalVolumes),
+		statTotalDirectories.Put(bucket, stats.TotalDirectories),
+		statTotalFiles.Put(bucket, stats.TotalFiles),
+		statCreated.Put(bucket, stats.Created),
+		statLastSaved.Put(bucket, stats.LastSaved),
+		statSharded.Put(bucket, stats.Sharded),
+	)
+}
+
+// updateLastSaved is a commit hook saving the time of the last change, unless only the statistics have changed
+func updateLastSaved(transaction store.Transaction, changes []store.Change) error {
+	for _, change := range changes {
+		if change.Path[0] == BucketStats {
+			continue
+		}
+		stats, err := transaction.GetBucket(BucketStats)
+		if errors.Is(err, store.ErrBucketNotFound) {
+			// not initialized yet
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return statLastSaved.Put(stats, time.Now())
+	}
+	return nil
+}
+
+// addToCounter adds value to the counter
+func addToCounter(bucket store.Bucket, counter store.TypedKey[uint64], value uint64) error {
+	total, err := counter.Get(bucket)
+	if err != nil {
+		return err
+	}
+	return counter.Put(bucket, total+value)
+}
+
+// removeFromCounter subtracts value from the counter, without going below zero
+func removeFromCounter(bucket store.Bucket, counter store.TypedKey[uint64], value uint64) error {
+	total, err := counter.Get(bucket)
+	if err != nil {
+		return err
+	}
+	return counter.Put(bucket, total-min(total, value))
+}
+
+// MarshalBinary saves the size and the mode in little-endian order, followed by the modification time
+func (e FileEntry) MarshalBinary() ([]byte, error) {
+	modTime, err := e.ModTime.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 12, 12+len(modTime))
+	binary.LittleEndian.PutUint64(output[0:8], uint64(e.Size))
+	binary.LittleEndian.PutUint32(output[8:12], uint32(e.Mode))
+	return append(output, modTime...), nil
+}
+
+// UnmarshalBinary loads an entry saved by MarshalBinary
+func (e *FileEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return codec.ErrInvalidLength
+	}
+	e.Size = int64(binary.LittleEndian.Uint64(data[0:8]))
+	e.Mode = os.FileMode(binary.LittleEndian.Uint32(data[8:12]))
+	return e.ModTime.UnmarshalBinary(data[12:])
+}
+
+// idToKey returns the ID as an 8 bytes big-endian key, so the keys are sorted in the same order as the IDs
+func idToKey(id uint64) string {
+	key, _ := codec.Uint64Key().EncodeKey(id)
+	return key
+}
+
+// keyToID returns the ID from a key created by idToKey
+func keyToID(key string) (uint64, error) {
+	if len(key) != 8 {
+		return 0, ErrInvalidIDKey
+	}
+	return codec.Uint64Key().DecodeKey(key)
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// trigramSize is the number of bytes of each key of the search index
+const trigramSize = 3
+
+var (
+	ErrEmptySearch     = errors.New("The search text cannot be empty")
+	ErrInvalidPostings = errors.New("Invalid posting list in the search index")
+)
+
+// SearchResult is a file or a directory found by a search
+type SearchResult struct {
+	Volume VolumeRecord
+	Path   string
+	Entry  FileEntry
+}
+
+// Search finds the files and directories containing the text in their path, ignoring case,
+// across all the volumes included in search.
+// The function is called on each result, volume by volume, in the order the files were indexed.
+//
+// The paths are found through the trigram index saved with the files of each volume.
+// A text shorter than a trigram needs a scan of all the files.
+func (d *Database) Search(text string, fn func(result SearchResult) error) error {
+	if text == "" {
+		return ErrEmptySearch
+	}
+	query := strings.ToLower(text)
+	volumes, err := d.searchedVolumes()
+	if err != nil {
+		return err
+	}
+	for _, record := range volumes {
+		err = d.viewVolume(record.ID, func(root store.Bucketeer) error {
+			return searchVolume(root, query, func(path string, entry FileEntry) error {
+				return fn(SearchResult{
+					Volume: record,
+					Path:   path,
+					Entry:  entry,
+				})
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchVolume runs the function on the files of the volume containing the lowercase query in their path
+func searchVolume(root store.Bucketeer, query string, fn func(path string, entry FileEntry) error) error {
+	bucket, err := root.GetBucket(BucketFiles)
+	if err != nil {
+		return err
+	}
+	files := newFilesBucket(bucket)
+	if len(query) < trigramSize {
+		return files.ForEach(func(path string, entry FileEntry) error {
+			if !strings.Contains(strings.ToLower(path), query) {
+				return nil
+			}
+			return fn(path, entry)
+		})
+	}
+
+	paths, err := root.GetBucket(BucketPaths)
+	if err != nil {
+		return err
+	}
+	trigramBucket, err := root.GetBucket(BucketTrigrams)
+	if err != nil {
+		return err
+	}
+	candidates, err := findCandidates(trigramBucket, query)
+	if err != nil {
+		return err
+	}
+	for _, id := range candidates {
+		data, err := paths.Get(idToKey(id))
+		if err != nil {
+			return err
+		}
+		path := string(data)
+		// the trigrams can be found in a different order
+		if !strings.Contains(strings.ToLower(path), query) {
+			continue
+		}
+		entry, err := files.Get(path)
+		if err != nil {
+			return err
+		}
+		if err = fn(path, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findCandidates returns the sorted IDs of the paths containing all the trigrams of the query
+func findCandidates(trigramBucket store.Bucket, query string) ([]uint64, error) {
+	var candidates []uint64
+	for i, trigram := range trigrams(query) {
+		// the posting lists of a trigram are saved in the order of the IDs
+		found := make([]uint64, 0, len(candidates))
+		err := trigramBucket.ForEachPrefix(trigram, func(key string, value []byte) error {
+			ids, err := decodePostings(key[trigramSize:], value)
+			if err != nil {
+				return err
+			}
+			found = append(found, ids...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			candidates = found
+		} else {
+			candidates = intersect(candidates, found)
+		}
+		if len(candidates) == 0 {
+			break
+		}
+	}
+	return candidates, nil
+}
+
+// intersect returns the IDs found in both sorted lists
+func intersect(a, b []uint64) []uint64 {
+	both := make([]uint64, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return both
+}
+
+// postings is the search index of a batch of paths: the sorted IDs of the paths containing each trigram
+type postings map[string][]uint64
+
+// add adds the ID to the list of each trigram of the path. The IDs must be added in increasing order.
+func (p postings) add(id uint64, path string) {
+	for _, trigram := range trigrams(strings.ToLower(path)) {
+		p[trigram] = append(p[trigram], id)
+	}
+}
+
+// save writes one posting list per trigram, in the order of the trigrams. The key is the trigram followed by the first ID
+// of the list, so the lists of the next batches come after: the value is the gap between each of the next IDs.
+func (p postings) save(trigramBucket store.Bucket) error {
+	list := make([]string, 0, len(p))
+	for trigram := range p {
+		list = append(list, trigram)
+	}
+	slices.Sort(list)
+	for _, trigram := range list {
+		ids := p[trigram]
+		err := trigramBucket.Put(trigram+idToKey(ids[0]), encodePostings(ids))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodePostings returns the gaps between the sorted IDs, after the first one, as varints
+func encodePostings(ids []uint64) []byte {
+	data := make([]byte, 0, len(ids))
+	for i := 1; i < len(ids); i++ {
+		data = binary.AppendUvarint(data, ids[i]-ids[i-1])
+	}
+	return data
+}
+
+// decodePostings returns the IDs of a posting list, from the first ID saved in the key and the gaps saved in the value
+func decodePostings(key string, value []byte) ([]uint64, error) {
+	id, err := keyToID(key)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint64{id}
+	for len(value) > 0 {
+		gap, read := binary.Uvarint(value)
+		if read <= 0 || gap == 0 {
+			return nil, ErrInvalidPostings
+		}
+		id += gap
+		ids = append(ids, id)
+		value = value[read:]
+	}
+	return ids, nil
+}
+
+// trigrams returns the unique sequences of three bytes of the text
+func trigrams(text string) []string {
+	if len(text) < trigramSize {
+		return nil
+	}
+	unique := make(map[string]bool, len(text)-trigramSize+1)
+	list := make([]string, 0, len(text)-trigramSize+1)
+	for i := 0; i+trigramSize <= len(text); i++ {
+		trigram := text[i : i+trigramSize]
+		if unique[trigram] {
+			continue
+		}
+		unique[trigram] = true
+		list = append(list, trigram)
+	}
+	return list
+}
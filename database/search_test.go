@@ -0,0 +1,159 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+)
+
+// searchPaths returns the volume name and the path of each result
+func searchPaths(t *testing.T, db *Database, text string) []string {
+	t.Helper()
+
+	found := make([]string, 0)
+	err := db.Search(text, func(result SearchResult) error {
+		found = append(found, result.Volume.Volume.Name+":"+result.Path)
+		return nil
+	})
+	require.NoError(t, err)
+	return found
+}
+
+func TestTrigrams(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, trigrams("ab"))
+	assert.Equal(t, []string{"abc"}, trigrams("abc"))
+	assert.Equal(t, []string{"aaa"}, trigrams("aaaaa"))
+	assert.Equal(t, []string{"dir", "ir/", "r/f", "/fi", "fil", "ile"}, trigrams("dir/file"))
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		db   func(t *testing.T) *Database
+	}{
+		{"inline", newTestDatabase},
+		{"sharded", func(t *testing.T) *Database { return newShardedDatabase(t, t.TempDir()) }},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := testCase.db(t)
+			indexTestFiles(t, db, "first")
+			indexTestFiles(t, db, "second")
+
+			assert.Equal(t, []string{"first:dir/file", "second:dir/file"}, searchPaths(t, db, "FILE"))
+			assert.Equal(t, []string{"first:dir/.hidden", "second:dir/.hidden"}, searchPaths(t, db, ".hid"))
+			// in the order of indexing
+			assert.Equal(t, []string{"first:dir", "first:dir/file", "first:dir/.hidden",
+				"second:dir", "second:dir/file", "second:dir/.hidden"}, searchPaths(t, db, "dir"))
+			assert.Empty(t, searchPaths(t, db, "files"))
+
+			// shorter than a trigram
+			assert.Equal(t, []string{"first:dir/file", "second:dir/file"}, searchPaths(t, db, "/f"))
+
+			err := db.Search("", func(result SearchResult) error { return nil })
+			assert.ErrorIs(t, err, ErrEmptySearch)
+		})
+	}
+}
+
+func TestSearchSkipsExcludedVolumes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	indexTestFiles(t, db, "included")
+	indexExcludedTestFiles(t, db, "excluded")
+
+	assert.Equal(t, []string{"included:dir/file"}, searchPaths(t, db, "file"))
+	assert.Equal(t, []string{"included:dir/file"}, searchPaths(t, db, "/f"))
+}
+
+func TestSearchResult(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexTestFiles(t, db, "test")
+
+	results := make([]SearchResult, 0, 1)
+	err := db.Search("dir/file", func(result SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, volumeID, results[0].Volume.ID)
+	assert.Equal(t, "test", results[0].Volume.Volume.Name)
+	assert.Equal(t, int64(12), results[0].Entry.Size)
+}
+
+func TestSearchTrigramsInAnotherOrder(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := uuid.New()
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		volumeBucket, err := volumes.CreateBucket(volumeID.String())
+		if err != nil {
+			return err
+		}
+		err = createFilesBuckets(volumeBucket)
+		if err != nil {
+			return err
+		}
+		batch := make([]batchFile, 0)
+		for id, path := range []string{"bcd/abc", "abcd"} {
+			batch = append(batch, batchFile{id: uint64(id + 1), path: path})
+		}
+		err = saveBatch(volumeBucket, batch)
+		if err != nil {
+			return err
+		}
+		return registerVolume(transaction, volumeID, volume.Volume{Name: "test", IncludeInSearch: true}, volumeTotals{Files: 2}, false)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"test:abcd"}, searchPaths(t, db, "abcd"))
+}
+
+func TestSearchVolumeIndexedByAnOlderVersion(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	volumeID := indexTestFiles(t, db, "test")
+
+	// volume indexed before the search index was added
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		volumeBucket, err := getVolumeBucket(transaction, volumeID)
+		if err != nil {
+			return err
+		}
+		return errors.Join(
+			volumeBucket.DeleteBucket(BucketPaths),
+			volumeBucket.DeleteBucket(BucketTrigrams),
+		)
+	})
+	require.NoError(t, err)
+	err = db.Search("File", func(result SearchResult) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	migrateFrom(t, db, Version{1, 1})
+	assert.Equal(t, []string{"test:dir/file"}, searchPaths(t, db, "File"))
+	assert.Equal(t, []string{"test:dir/.hidden"}, searchPaths(t, db, "hid"))
+}
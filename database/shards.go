@@ -0,0 +1,321 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/google/uuid"
+)
+
+// ShardExtension is the extension of the shard files
+const ShardExtension = ".shard"
+
+var (
+	ErrNoShards       = errors.New("No shard directory configured for this database")
+	ErrShardNotFound  = errors.New("Shard not found")
+	ErrInvalidShard   = errors.New("Invalid shard")
+	ErrNotSharded     = errors.New("The files of the volume are not saved in a shard")
+	ErrVolumeNotFound = errors.New("Volume not found")
+	ErrVolumeExists   = errors.New("The volume is already in the database")
+)
+
+// ShardOpener opens the store saved in the shard file. The file is created when create is true.
+type ShardOpener func(filename string, create bool) (store.Store, error)
+
+// Shards is the directory holding the shard files of a database: each shard contains the files of one volume.
+// A shard is opened on first use, and stays open until Close is called.
+type Shards struct {
+	dir    string
+	open   ShardOpener
+	mutex  *sync.Mutex
+	stores map[uuid.UUID]store.Store
+}
+
+// NewShards uses the opener to open the shard files saved in the directory
+func NewShards(dir string, open ShardOpener) *Shards {
+	return &Shards{
+		dir:    dir,
+		open:   open,
+		mutex:  &sync.Mutex{},
+		stores: make(map[uuid.UUID]store.Store),
+	}
+}
+
+// Dir returns the directory of the shard files
+func (s *Shards) Dir() string {
+	return s.dir
+}
+
+// Filename returns the name of the shard file of the volume
+func (s *Shards) Filename(volumeID uuid.UUID) string {
+	return filepath.Join(s.dir, volumeID.String()+ShardExtension)
+}
+
+// Close all the shards opened so far
+func (s *Shards) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for volumeID, storage := range s.stores {
+		storage.Close()
+		delete(s.stores, volumeID)
+	}
+}
+
+// get returns the store of the shard, opening the shard file if needed
+func (s *Shards) get(volumeID uuid.UUID, create bool) (store.Store, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if storage, found := s.stores[volumeID]; found {
+		return storage, nil
+	}
+	filename := s.Filename(volumeID)
+	if create {
+		if err := os.MkdirAll(s.dir, 0o700); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(filename); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrShardNotFound, filename)
+		}
+		return nil, err
+	}
+	storage, err := s.open(filename, create)
+	if err != nil {
+		return nil, err
+	}
+	s.stores[volumeID] = storage
+	return storage, nil
+}
+
+// release closes the shard if it's open
+func (s *Shards) release(volumeID uuid.UUID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if storage, found := s.stores[volumeID]; found {
+		storage.Close()
+		delete(s.stores, volumeID)
+	}
+}
+
+// remove closes and deletes the shard file. A shard saved by the log backend is a directory.
+func (s *Shards) remove(volumeID uuid.UUID) error {
+	s.release(volumeID)
+	return os.RemoveAll(s.Filename(volumeID))
+}
+
+// AttachShard adds the volume saved in the shard file to the database.
+// The shard file must already be in the shard directory, under the name returned by Shards.Filename.
+// The indexes of a shard saved before they were added are built first.
+func (d *Database) AttachShard(volumeID uuid.UUID) error {
+	if d.shards == nil {
+		return ErrNoShards
+	}
+	storage, err := d.shards.get(volumeID, false)
+	if err != nil {
+		return err
+	}
+	var shardID uuid.UUID
+	var vol volume.Volume
+	var totals volumeTotals
+	err = storage.View(func(transaction store.Transaction) error {
+		header, err := transaction.GetBucket(BucketShard)
+		if errors.Is(err, store.ErrBucketNotFound) {
+			return fmt.Errorf("%w: missing shard header", ErrInvalidShard)
+		}
+		if err != nil {
+			return err
+		}
+		if shardID, err = recordVolumeID.Get(header); err != nil {
+			return err
+		}
+		if vol, err = recordVolume.Get(header); err != nil {
+			return err
+		}
+		totals, err = recordShard.Get(header)
+		return err
+	})
+	if err == nil && shardID != volumeID {
+		err = fmt.Errorf("%w: the shard contains the volume %s", ErrInvalidShard, shardID)
+	}
+	if err == nil {
+		// a shard saved by an older version can miss some of the indexes
+		indexed := false
+		err = storage.View(func(transaction store.Transaction) error {
+			indexed, err = hasIndexes(transaction)
+			return err
+		})
+		if err == nil && !indexed {
+			err = rebuildIndexes(storage, shardRoot)
+		}
+	}
+	if err == nil {
+		err = d.storage.Update(func(transaction store.Transaction) error {
+			return registerVolume(transaction, volumeID, vol, totals, true)
+		})
+	}
+	if err != nil {
+		d.shards.release(volumeID)
+		return err
+	}
+	return nil
+}
+
+// DetachShard removes the volume from the database, but leaves its shard file in the shard directory
+func (d *Database) DetachShard(volumeID uuid.UUID) error {
+	err := d.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		volumeBucket, err := volumes.GetBucket(volumeID.String())
+		if errors.Is(err, store.ErrBucketNotFound) {
+			return ErrVolumeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		totals, err := recordShard.Get(volumeBucket)
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return ErrNotSharded
+		}
+		if err != nil {
+			return err
+		}
+		err = volumes.DeleteBucket(volumeID.String())
+		if err != nil {
+			return err
+		}
+		return unregisterVolume(transaction, totals)
+	})
+	if err != nil {
+		return err
+	}
+	if d.shards != nil {
+		d.shards.release(volumeID)
+	}
+	return nil
+}
+
+// DeleteShard removes the volume from the database, and deletes its shard file
+func (d *Database) DeleteShard(volumeID uuid.UUID) error {
+	if d.shards == nil {
+		return ErrNoShards
+	}
+	err := d.DetachShard(volumeID)
+	if err != nil {
+		return err
+	}
+	return d.shards.remove(volumeID)
+}
+
+// CopyShard writes a snapshot of the shard of the volume. The copy can be attached to another database.
+func (d *Database) CopyShard(volumeID uuid.UUID, w io.Writer) (int64, error) {
+	storage, err := d.shardStore(volumeID)
+	if err != nil {
+		return 0, err
+	}
+	return storage.WriteTo(w)
+}
+
+// ViewFiles runs the function in a read-only transaction on the files of the volume, wherever they are saved
+func (d *Database) ViewFiles(volumeID uuid.UUID, fn func(files *store.TypedBucket[string, FileEntry]) error) error {
+	return d.viewVolume(volumeID, func(root store.Bucketeer) error {
+		files, err := root.GetBucket(BucketFiles)
+		if err != nil {
+			return err
+		}
+		return fn(newFilesBucket(files))
+	})
+}
+
+// shardRoot returns the root of the buckets of the volume saved in a shard: the top level of the shard
+func shardRoot(transaction store.Transaction) (store.Bucketeer, error) {
+	return transaction, nil
+}
+
+// viewVolume runs the function in a read-only transaction on the root of the buckets of the volume:
+// the bucket of the volume in the database, or the top level of its shard
+func (d *Database) viewVolume(volumeID uuid.UUID, fn func(root store.Bucketeer) error) error {
+	sharded, err := d.isShardedVolume(volumeID)
+	if err != nil {
+		return err
+	}
+	if !sharded {
+		return d.storage.View(func(transaction store.Transaction) error {
+			volumeBucket, err := getVolumeBucket(transaction, volumeID)
+			if err != nil {
+				return err
+			}
+			return fn(volumeBucket)
+		})
+	}
+	storage, err := d.shardStore(volumeID)
+	if err != nil {
+		return err
+	}
+	return storage.View(func(transaction store.Transaction) error {
+		return fn(transaction)
+	})
+}
+
+// shardStore returns the store of the shard of a volume registered in the database
+func (d *Database) shardStore(volumeID uuid.UUID) (store.Store, error) {
+	sharded, err := d.isShardedVolume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if !sharded {
+		return nil, ErrNotSharded
+	}
+	if d.shards == nil {
+		return nil, ErrNoShards
+	}
+	return d.shards.get(volumeID, false)
+}
+
+// isShardedVolume returns true when the files of the volume are saved in a shard
+func (d *Database) isShardedVolume(volumeID uuid.UUID) (bool, error) {
+	sharded := false
+	err := d.storage.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		volumeBucket, err := volumes.GetBucket(volumeID.String())
+		if errors.Is(err, store.ErrBucketNotFound) {
+			return ErrVolumeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = volumeBucket.Get(KeyShard)
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sharded = true
+		return nil
+	})
+	return sharded, err
+}
+
+// addShardedSetting is the migration step adding the layout of the new volumes to the statistics:
+// the volumes of an existing database stay in the main file
+func addShardedSetting(transaction store.Transaction) error {
+	stats, err := transaction.GetBucket(BucketStats)
+	if err != nil {
+		return err
+	}
+	return statSharded.Put(stats, false)
+}
@@ -0,0 +1,405 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/index"
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+)
+
+// newBoltShards returns shards saved as bolt files in the directory, counting the shards opened
+func newBoltShards(dir string, opened *int) *Shards {
+	return NewShards(dir, func(filename string, create bool) (store.Store, error) {
+		if opened != nil {
+			*opened++
+		}
+		return store.NewBoltStore(filename)
+	})
+}
+
+// newShardedDatabase returns a new sharded database in memory, with the shards in the directory
+func newShardedDatabase(t *testing.T, dir string) *Database {
+	t.Helper()
+
+	db := NewShardedDatabase(store.NewMemoryStore(), newBoltShards(dir, nil))
+	t.Cleanup(db.Close)
+	require.NoError(t, db.InitSharded())
+	return db
+}
+
+// indexTestFiles indexes a volume included in search, with a directory, a file and a hidden file
+func indexTestFiles(t *testing.T, db *Database, name string) uuid.UUID {
+	t.Helper()
+
+	return indexTestVolume(t, db, &volume.Volume{Name: name, IncludeInSearch: true})
+}
+
+// indexExcludedTestFiles indexes the files of indexTestFiles into a volume excluded from search
+func indexExcludedTestFiles(t *testing.T, db *Database, name string) uuid.UUID {
+	t.Helper()
+
+	return indexTestVolume(t, db, &volume.Volume{Name: name})
+}
+
+// indexTestVolume indexes the volume with a directory, a file and a hidden file
+func indexTestVolume(t *testing.T, db *Database, vol *volume.Volume) uuid.UUID {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		"dir":         &fstest.MapFile{Mode: fs.ModeDir},
+		"dir/file":    &fstest.MapFile{Data: []byte("some content")},
+		"dir/.hidden": &fstest.MapFile{Data: []byte("secret")},
+	}
+	files := make(chan index.FileIndexed, 10)
+	for _, name := range []string{".", "dir", "dir/file", "dir/.hidden"} {
+		info, err := fs.Stat(fsys, name)
+		require.NoError(t, err)
+		files <- index.FileIndexed{Path: name, Info: info}
+	}
+	close(files)
+
+	volumeID, err := db.IndexVolume(context.Background(), vol, files)
+	require.NoError(t, err)
+	return volumeID
+}
+
+// assertVolumeFiles checks the files of the volume indexed by indexTestFiles
+func assertVolumeFiles(t *testing.T, db *Database, volumeID uuid.UUID) {
+	t.Helper()
+
+	err := db.ViewFiles(volumeID, func(files *store.TypedBucket[string, FileEntry]) error {
+		entry, err := files.Get("dir/file")
+		require.NoError(t, err)
+		assert.Equal(t, int64(12), entry.Size)
+
+		entry, err = files.Get("dir")
+		require.NoError(t, err)
+		assert.True(t, entry.Mode.IsDir())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// assertTotals checks the totals saved in the statistics
+func assertTotals(t *testing.T, db *Database, volumes, directories, files uint64) {
+	t.Helper()
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, volumes, stats.TotalVolumes)
+	assert.Equal(t, directories, stats.TotalDirectories)
+	assert.Equal(t, files, stats.TotalFiles)
+}
+
+func TestShardedIndexVolume(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	db := newShardedDatabase(t, dir)
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.True(t, stats.Sharded)
+
+	volumeID := indexTestFiles(t, db, "test")
+	assert.FileExists(t, filepath.Join(dir, volumeID.String()+ShardExtension))
+	assertTotals(t, db, 1, 2, 2)
+	assertVolumeFiles(t, db, volumeID)
+
+	// the main store only holds the volume record
+	err = db.storage.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		require.NoError(t, err)
+		volumeBucket, err := volumes.GetBucket(volumeID.String())
+		require.NoError(t, err)
+		savedVolume, err := recordVolume.Get(volumeBucket)
+		require.NoError(t, err)
+		assert.Equal(t, "test", savedVolume.Name)
+		assert.Equal(t, uint64(1), savedVolume.RegularFiles)
+		assert.Equal(t, uint64(1), savedVolume.HiddenFiles)
+
+		_, err = volumeBucket.GetBucket(BucketFiles)
+		assert.ErrorIs(t, err, store.ErrBucketNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestShardsAreOpenedOnFirstUse(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	db := NewShardedDatabase(memory, newBoltShards(dir, nil))
+	require.NoError(t, db.InitSharded())
+	first := indexTestFiles(t, db, "first")
+	second := indexTestFiles(t, db, "second")
+	db.shards.Close()
+
+	opened := 0
+	db = NewShardedDatabase(memory, newBoltShards(dir, &opened))
+	defer db.shards.Close()
+	assertTotals(t, db, 2, 4, 4)
+	assert.Zero(t, opened)
+
+	assertVolumeFiles(t, db, second)
+	assertVolumeFiles(t, db, second)
+	assert.Equal(t, 1, opened)
+	assertVolumeFiles(t, db, first)
+	assert.Equal(t, 2, opened)
+}
+
+func TestMixedLayouts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	memory := store.NewMemoryStore()
+	defer memory.Close()
+
+	// volume saved before the database was sharded
+	db := NewShardedDatabase(memory, newBoltShards(dir, nil))
+	defer db.shards.Close()
+	require.NoError(t, db.Init())
+	inline := indexTestFiles(t, db, "inline")
+
+	err := memory.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return statSharded.Put(stats, true)
+	})
+	require.NoError(t, err)
+	sharded := indexTestFiles(t, db, "sharded")
+
+	assertTotals(t, db, 2, 4, 4)
+	assertVolumeFiles(t, db, inline)
+	assertVolumeFiles(t, db, sharded)
+	assert.NoFileExists(t, db.shards.Filename(inline))
+	assert.FileExists(t, db.shards.Filename(sharded))
+
+	err = db.DetachShard(inline)
+	assert.ErrorIs(t, err, ErrNotSharded)
+	_, err = db.CopyShard(inline, &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrNotSharded)
+}
+
+func TestDetachAndAttachShard(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	db := newShardedDatabase(t, dir)
+	first := indexTestFiles(t, db, "first")
+	second := indexTestFiles(t, db, "second")
+
+	require.NoError(t, db.DetachShard(first))
+	assertTotals(t, db, 1, 2, 2)
+	assert.FileExists(t, db.shards.Filename(first))
+	err := db.ViewFiles(first, func(files *store.TypedBucket[string, FileEntry]) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrVolumeNotFound)
+	assert.ErrorIs(t, db.DetachShard(first), ErrVolumeNotFound)
+
+	require.NoError(t, db.AttachShard(first))
+	assertTotals(t, db, 2, 4, 4)
+	assertVolumeFiles(t, db, first)
+	assertVolumeFiles(t, db, second)
+
+	assert.ErrorIs(t, db.AttachShard(second), ErrVolumeExists)
+	assertTotals(t, db, 2, 4, 4)
+}
+
+func TestAttachInvalidShard(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	db := newShardedDatabase(t, dir)
+	volumeID := indexTestFiles(t, db, "test")
+	require.NoError(t, db.DetachShard(volumeID))
+
+	assert.ErrorIs(t, db.AttachShard(uuid.New()), ErrShardNotFound)
+
+	// shard file under the name of another volume
+	other := uuid.New()
+	require.NoError(t, os.Rename(db.shards.Filename(volumeID), db.shards.Filename(other)))
+	assert.ErrorIs(t, db.AttachShard(other), ErrInvalidShard)
+
+	// shard file without header
+	empty := uuid.New()
+	emptyStore, err := store.NewBoltStore(db.shards.Filename(empty))
+	require.NoError(t, err)
+	emptyStore.Close()
+	assert.ErrorIs(t, db.AttachShard(empty), ErrInvalidShard)
+
+	assertTotals(t, db, 0, 0, 0)
+}
+
+func TestDeleteShard(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	db := newShardedDatabase(t, dir)
+	volumeID := indexTestFiles(t, db, "test")
+	assertVolumeFiles(t, db, volumeID)
+
+	require.NoError(t, db.DeleteShard(volumeID))
+	assertTotals(t, db, 0, 0, 0)
+	assert.NoFileExists(t, db.shards.Filename(volumeID))
+	assert.ErrorIs(t, db.DeleteShard(volumeID), ErrVolumeNotFound)
+}
+
+func TestCopyShardToAnotherDatabase(t *testing.T) {
+	t.Parallel()
+
+	source := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, source, "test")
+
+	target := newShardedDatabase(t, t.TempDir())
+	require.NoError(t, os.MkdirAll(target.shards.Dir(), 0o700))
+	buffer := &bytes.Buffer{}
+	_, err := source.CopyShard(volumeID, buffer)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(target.shards.Filename(volumeID), buffer.Bytes(), 0o600))
+
+	require.NoError(t, target.AttachShard(volumeID))
+	assertTotals(t, target, 1, 2, 2)
+	assertVolumeFiles(t, target, volumeID)
+}
+
+func TestShardRemovedOnCancel(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	db := newShardedDatabase(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	files := make(chan index.FileIndexed)
+	close(files)
+	_, err := db.IndexVolume(ctx, &volume.Volume{}, files)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assertTotals(t, db, 0, 0, 0)
+}
+
+// deleteShardIndexes removes the indexes from the shard file, like a shard saved by an older version
+func deleteShardIndexes(t *testing.T, storage store.Store) {
+	t.Helper()
+
+	err := storage.Update(func(transaction store.Transaction) error {
+		for _, name := range indexBuckets {
+			if err := transaction.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestIndexesMigrationOfShards(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+	storage, err := db.shardStore(volumeID)
+	require.NoError(t, err)
+	deleteShardIndexes(t, storage)
+
+	migrateFrom(t, db, Version{1, 1})
+	assert.Equal(t, []string{"test:dir/file"}, searchPaths(t, db, "file"))
+
+	// a database opened without its shards cannot migrate the sharded volumes
+	withoutShards := NewDatabase(db.storage)
+	err = withoutShards.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return statVersion.Put(stats, Version{1, 1})
+	})
+	require.NoError(t, err)
+	plan, err := withoutShards.MigrationPlan()
+	require.NoError(t, err)
+	assert.ErrorIs(t, withoutShards.Migrate(plan), ErrNoShards)
+}
+
+func TestFailedMigrationListsTheShardsConverted(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+
+	converted := 0
+	plan := []Migration{{
+		// the version of the database is different: the transaction of the migration fails
+		From: Version{0, 9},
+		To:   CurrentVersion,
+		MigrateVolume: func(storage store.Store, volumeRoot func(transaction store.Transaction) (store.Bucketeer, error)) error {
+			converted++
+			return nil
+		},
+	}}
+	err := db.Migrate(plan)
+	require.Error(t, err)
+	assert.Equal(t, 1, converted)
+	assert.Contains(t, err.Error(), "shards already converted: "+volumeID.String())
+}
+
+func TestAttachShardSavedByAnOlderVersion(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+	require.NoError(t, db.DetachShard(volumeID))
+
+	storage, err := store.NewBoltStore(db.shards.Filename(volumeID))
+	require.NoError(t, err)
+	deleteShardIndexes(t, storage)
+	storage.Close()
+
+	require.NoError(t, db.AttachShard(volumeID))
+	assert.Equal(t, []string{"test:dir/file"}, searchPaths(t, db, "file"))
+}
+
+func TestShardedSettingMigration(t *testing.T) {
+	t.Parallel()
+
+	db := newDatabaseWithVersion(t, Version{1, 0}, registry)
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		stats, err := transaction.GetBucket(BucketStats)
+		if err != nil {
+			return err
+		}
+		return stats.Delete(KeySharded)
+	})
+	require.NoError(t, err)
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	assert.False(t, stats.Sharded)
+
+	plan, err := db.MigrationPlan()
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(plan))
+
+	stats, err = db.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, stats.Version)
+	assert.False(t, stats.Sharded)
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/volume"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAmbiguousVolume  = errors.New("More than one volume with this name: please use the volume ID")
+	ErrVolumeNameExists = errors.New("Another volume already has this name")
+	ErrEmptyVolumeName  = errors.New("The name of a volume cannot be empty")
+)
+
+// VolumeRecord is a volume saved in the database
+type VolumeRecord struct {
+	ID      uuid.UUID
+	Volume  volume.Volume
+	Sharded bool
+}
+
+// Volumes returns all the volumes of the database, sorted by name.
+// A volume still being indexed is not returned.
+func (d *Database) Volumes() ([]VolumeRecord, error) {
+	records := make([]VolumeRecord, 0)
+	err := d.storage.View(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		return volumes.ForEachBucket(func(name string) error {
+			record, err := loadVolumeRecord(volumes, name)
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	slices.SortStableFunc(records, func(a, b VolumeRecord) int {
+		return strings.Compare(a.Volume.Name, b.Volume.Name)
+	})
+	return records, err
+}
+
+// searchedVolumes returns the volumes included in search, sorted by name
+func (d *Database) searchedVolumes() ([]VolumeRecord, error) {
+	volumes, err := d.Volumes()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(volumes, func(record VolumeRecord) bool {
+		return !record.Volume.IncludeInSearch
+	}), nil
+}
+
+// FindVolume returns the volume from its ID, or from its name
+func (d *Database) FindVolume(nameOrID string) (VolumeRecord, error) {
+	volumes, err := d.Volumes()
+	if err != nil {
+		return VolumeRecord{}, err
+	}
+	if volumeID, err := uuid.Parse(nameOrID); err == nil {
+		for _, record := range volumes {
+			if record.ID == volumeID {
+				return record, nil
+			}
+		}
+	}
+	found := make([]VolumeRecord, 0, 1)
+	for _, record := range volumes {
+		if record.Volume.Name == nameOrID {
+			found = append(found, record)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return VolumeRecord{}, ErrVolumeNotFound
+	case 1:
+		return found[0], nil
+	default:
+		return VolumeRecord{}, ErrAmbiguousVolume
+	}
+}
+
+// RenameVolume changes the name of the volume in the catalogue. The label of the file system is kept.
+// The name must not be used by another volume.
+// The header of the shard of a sharded volume is renamed too, and restored if the catalogue cannot be saved.
+func (d *Database) RenameVolume(volumeID uuid.UUID, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrEmptyVolumeName
+	}
+	shardRenamed := false
+	var previous volume.Volume
+	err := d.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		var record VolumeRecord
+		err = volumes.ForEachBucket(func(bucketName string) error {
+			other, err := loadVolumeRecord(volumes, bucketName)
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if other.ID == volumeID {
+				record = other
+				return nil
+			}
+			if other.Volume.Name == name {
+				return ErrVolumeNameExists
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if record.ID != volumeID {
+			return ErrVolumeNotFound
+		}
+
+		vol := record.Volume
+		if vol.Label == "" {
+			// saved before the label was recorded: the name was the label
+			vol.Label = vol.Name
+		}
+		vol.Name = name
+		if record.Sharded {
+			// the shard keeps its own copy of the record, used when it's attached again
+			previous, err = d.updateShardHeader(volumeID, vol)
+			if err != nil {
+				return err
+			}
+			shardRenamed = true
+		}
+		volumeBucket, err := volumes.GetBucket(volumeID.String())
+		if err != nil {
+			return err
+		}
+		return recordVolume.Put(volumeBucket, vol)
+	})
+	if err != nil && shardRenamed {
+		// best effort: the original error is more important
+		_, _ = d.updateShardHeader(volumeID, previous)
+	}
+	return err
+}
+
+// RemoveVolume deletes the volume and all its files, and removes them from the statistics.
+// The shard of a sharded volume is deleted.
+func (d *Database) RemoveVolume(volumeID uuid.UUID) error {
+	sharded, err := d.isShardedVolume(volumeID)
+	if err != nil {
+		return err
+	}
+	if sharded {
+		return d.DeleteShard(volumeID)
+	}
+	return d.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		files, err := getFilesBucket(transaction, volumeID)
+		if err != nil {
+			return err
+		}
+		totals := volumeTotals{}
+		err = files.ForEach(func(key string, entry FileEntry) error {
+			if entry.Mode.IsDir() {
+				totals.Directories++
+			} else {
+				totals.Files++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = volumes.DeleteBucket(volumeID.String())
+		if err != nil {
+			return err
+		}
+		return unregisterVolume(transaction, totals)
+	})
+}
+
+// updateShardHeader saves the volume record into the header of its shard, and returns the previous record
+func (d *Database) updateShardHeader(volumeID uuid.UUID, vol volume.Volume) (volume.Volume, error) {
+	if d.shards == nil {
+		return volume.Volume{}, ErrNoShards
+	}
+	shard, err := d.shards.get(volumeID, false)
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	var previous volume.Volume
+	err = shard.Update(func(transaction store.Transaction) error {
+		header, err := transaction.GetBucket(BucketShard)
+		if err != nil {
+			return err
+		}
+		previous, err = recordVolume.Get(header)
+		if err != nil {
+			return err
+		}
+		return recordVolume.Put(header, vol)
+	})
+	return previous, err
+}
+
+// loadVolumeRecord reads the volume saved in the bucket of the volume. It returns store.ErrKeyNotFound
+// when the volume is still being indexed.
+func loadVolumeRecord(volumes store.Bucket, name string) (VolumeRecord, error) {
+	record := VolumeRecord{}
+	volumeID, err := uuid.Parse(name)
+	if err != nil {
+		return record, err
+	}
+	volumeBucket, err := volumes.GetBucket(name)
+	if err != nil {
+		return record, err
+	}
+	vol, err := recordVolume.Get(volumeBucket)
+	if err != nil {
+		return record, err
+	}
+	_, err = volumeBucket.Get(KeyShard)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return record, err
+	}
+	record.ID = volumeID
+	record.Volume = vol
+	record.Sharded = err == nil
+	return record, nil
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+// newTestDatabase returns a new database in memory
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db := NewDatabase(store.NewMemoryStore())
+	t.Cleanup(db.Close)
+	require.NoError(t, db.Init())
+	return db
+}
+
+func TestVolumes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	second := indexTestFiles(t, db, "second")
+	first := indexTestFiles(t, db, "first")
+
+	// volume still being indexed
+	err := db.storage.Update(func(transaction store.Transaction) error {
+		volumes, err := transaction.GetBucket(BucketVolumes)
+		if err != nil {
+			return err
+		}
+		_, err = volumes.CreateBucket(uuid.NewString())
+		return err
+	})
+	require.NoError(t, err)
+
+	volumes, err := db.Volumes()
+	require.NoError(t, err)
+	require.Len(t, volumes, 2)
+	assert.Equal(t, first, volumes[0].ID)
+	assert.Equal(t, "first", volumes[0].Volume.Name)
+	assert.Equal(t, uint64(1), volumes[0].Volume.RegularFiles)
+	assert.False(t, volumes[0].Sharded)
+	assert.Equal(t, second, volumes[1].ID)
+	assert.Equal(t, "second", volumes[1].Volume.Name)
+}
+
+func TestFindVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	first := indexTestFiles(t, db, "first")
+	indexTestFiles(t, db, "twice")
+	indexTestFiles(t, db, "twice")
+
+	record, err := db.FindVolume(first.String())
+	require.NoError(t, err)
+	assert.Equal(t, "first", record.Volume.Name)
+
+	record, err = db.FindVolume("first")
+	require.NoError(t, err)
+	assert.Equal(t, first, record.ID)
+
+	_, err = db.FindVolume("twice")
+	assert.ErrorIs(t, err, ErrAmbiguousVolume)
+	_, err = db.FindVolume("unknown")
+	assert.ErrorIs(t, err, ErrVolumeNotFound)
+	_, err = db.FindVolume(uuid.NewString())
+	assert.ErrorIs(t, err, ErrVolumeNotFound)
+}
+
+func TestRenameVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	first := indexTestFiles(t, db, "first")
+	indexTestFiles(t, db, "second")
+
+	require.NoError(t, db.RenameVolume(first, "renamed"))
+	record, err := db.FindVolume(first.String())
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", record.Volume.Name)
+	assert.Equal(t, "first", record.Volume.Label)
+
+	// the label is only set once
+	require.NoError(t, db.RenameVolume(first, "again"))
+	record, err = db.FindVolume("again")
+	require.NoError(t, err)
+	assert.Equal(t, "first", record.Volume.Label)
+
+	assert.ErrorIs(t, db.RenameVolume(first, "second"), ErrVolumeNameExists)
+	assert.ErrorIs(t, db.RenameVolume(first, " "), ErrEmptyVolumeName)
+	assert.ErrorIs(t, db.RenameVolume(uuid.New(), "other"), ErrVolumeNotFound)
+
+	// renaming a volume with its own name is accepted
+	require.NoError(t, db.RenameVolume(first, "again"))
+}
+
+func TestRenameShardedVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+	require.NoError(t, db.RenameVolume(volumeID, "renamed"))
+
+	// the new name is saved in the shard too
+	require.NoError(t, db.DetachShard(volumeID))
+	require.NoError(t, db.AttachShard(volumeID))
+	record, err := db.FindVolume(volumeID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", record.Volume.Name)
+	assert.True(t, record.Sharded)
+}
+
+func TestRenameShardedVolumeRestoresTheShardOnError(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+
+	// the catalogue cannot be saved after the shard has been renamed
+	errVeto := errors.New("veto")
+	veto := true
+	db.storage.(*store.ObservableStore).OnBeforeCommit(func(transaction store.Transaction, changes []store.Change) error {
+		if veto {
+			return errVeto
+		}
+		return nil
+	})
+	assert.ErrorIs(t, db.RenameVolume(volumeID, "renamed"), errVeto)
+	veto = false
+
+	require.NoError(t, db.DetachShard(volumeID))
+	require.NoError(t, db.AttachShard(volumeID))
+	record, err := db.FindVolume(volumeID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "test", record.Volume.Name)
+}
+
+func TestRemoveVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDatabase(t)
+	first := indexTestFiles(t, db, "first")
+	second := indexTestFiles(t, db, "second")
+
+	require.NoError(t, db.RemoveVolume(first))
+	assertTotals(t, db, 1, 2, 2)
+	assertVolumeFiles(t, db, second)
+	_, err := db.FindVolume("first")
+	assert.ErrorIs(t, err, ErrVolumeNotFound)
+	assert.ErrorIs(t, db.RemoveVolume(first), ErrVolumeNotFound)
+
+	require.NoError(t, db.RemoveVolume(second))
+	assertTotals(t, db, 0, 0, 0)
+}
+
+func TestRemoveShardedVolume(t *testing.T) {
+	t.Parallel()
+
+	db := newShardedDatabase(t, t.TempDir())
+	volumeID := indexTestFiles(t, db, "test")
+
+	require.NoError(t, db.RemoveVolume(volumeID))
+	assertTotals(t, db, 0, 0, 0)
+	assert.NoFileExists(t, db.shards.Filename(volumeID))
+}
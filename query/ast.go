@@ -0,0 +1,221 @@
+package query
+
+import (
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/creativeprojects/catalogue/volume"
+)
+
+// File is a file or a directory of a volume, as saved in the catalogue
+type File struct {
+	Volume  *volume.Volume
+	Path    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// VolumeMatch tells which files of a volume can match an expression
+type VolumeMatch int
+
+const (
+	// MatchSome means each file of the volume must be checked
+	MatchSome VolumeMatch = iota
+	// MatchAll means all the files of the volume match
+	MatchAll
+	// MatchNone means no file of the volume can match
+	MatchNone
+)
+
+// Expr is a node of the syntax tree of a query
+type Expr interface {
+	// Match returns true when the file matches the expression
+	Match(file *File) bool
+	// MatchVolume returns MatchAll or MatchNone when the expression only depends on the volume,
+	// so the files of a volume don't need to be checked one by one
+	MatchVolume(vol *volume.Volume) VolumeMatch
+	// String returns the expression in the query syntax, with parentheses around each group
+	String() string
+}
+
+// And matches the files matching both expressions
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e *And) Match(file *File) bool {
+	return e.Left.Match(file) && e.Right.Match(file)
+}
+
+func (e *And) MatchVolume(vol *volume.Volume) VolumeMatch {
+	left, right := e.Left.MatchVolume(vol), e.Right.MatchVolume(vol)
+	switch {
+	case left == MatchNone || right == MatchNone:
+		return MatchNone
+	case left == MatchAll && right == MatchAll:
+		return MatchAll
+	default:
+		return MatchSome
+	}
+}
+
+func (e *And) String() string {
+	return "(" + e.Left.String() + " " + keywordAnd + " " + e.Right.String() + ")"
+}
+
+// Or matches the files matching any of the expressions
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e *Or) Match(file *File) bool {
+	return e.Left.Match(file) || e.Right.Match(file)
+}
+
+func (e *Or) MatchVolume(vol *volume.Volume) VolumeMatch {
+	left, right := e.Left.MatchVolume(vol), e.Right.MatchVolume(vol)
+	switch {
+	case left == MatchAll || right == MatchAll:
+		return MatchAll
+	case left == MatchNone && right == MatchNone:
+		return MatchNone
+	default:
+		return MatchSome
+	}
+}
+
+func (e *Or) String() string {
+	return "(" + e.Left.String() + " " + keywordOr + " " + e.Right.String() + ")"
+}
+
+// Not matches the files not matching the expression
+type Not struct {
+	Expr Expr
+}
+
+func (e *Not) Match(file *File) bool {
+	return !e.Expr.Match(file)
+}
+
+func (e *Not) MatchVolume(vol *volume.Volume) VolumeMatch {
+	switch e.Expr.MatchVolume(vol) {
+	case MatchAll:
+		return MatchNone
+	case MatchNone:
+		return MatchAll
+	default:
+		return MatchSome
+	}
+}
+
+func (e *Not) String() string {
+	return keywordNot + " " + e.Expr.String()
+}
+
+// Term compares a field of the file or of its volume with a value
+type Term struct {
+	Field    Field
+	Operator Operator
+	Value    string
+
+	// value parsed according to the field
+	pattern  string
+	glob     bool
+	size     int64
+	from, to time.Time
+	flag     bool
+}
+
+func (t *Term) Match(file *File) bool {
+	switch t.Field {
+	case FieldText, FieldPath:
+		return t.matchText(file.Path)
+	case FieldName:
+		return t.matchText(path.Base(file.Path))
+	case FieldSize:
+		return t.Operator.compare(file.Size, t.size)
+	case FieldModified:
+		return t.matchTime(file.ModTime)
+	case FieldType:
+		return file.Mode.IsDir() == t.flag
+	default:
+		return t.MatchVolume(file.Volume) == MatchAll
+	}
+}
+
+func (t *Term) MatchVolume(vol *volume.Volume) VolumeMatch {
+	var match bool
+	switch t.Field {
+	case FieldVolume:
+		match = t.matchText(vol.Name)
+	case FieldLocation:
+		match = t.matchText(vol.Location)
+	case FieldIncluded:
+		match = vol.IncludeInSearch == t.flag
+	default:
+		return MatchSome
+	}
+	if match {
+		return MatchAll
+	}
+	return MatchNone
+}
+
+func (t *Term) String() string {
+	value := t.Value
+	if value == "" || strings.ContainsAny(value, ` ()"\`) || isKeyword(value) || strings.Contains(value, ":") {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	}
+	if t.Field == FieldText {
+		return value
+	}
+	operator := string(t.Operator)
+	if t.Operator == OperatorEqual {
+		operator = ""
+	}
+	return string(t.Field) + ":" + operator + value
+}
+
+// matchText matches the glob pattern against the whole text, or finds the text, ignoring case
+func (t *Term) matchText(text string) bool {
+	text = strings.ToLower(text)
+	if t.glob {
+		// the pattern has already been checked
+		matched, _ := path.Match(t.pattern, text)
+		return matched
+	}
+	return strings.Contains(text, t.pattern)
+}
+
+// matchTime compares the time with the period [from, to) of the term
+func (t *Term) matchTime(modTime time.Time) bool {
+	switch t.Operator {
+	case OperatorGreater:
+		return !modTime.Before(t.to)
+	case OperatorGreaterOrEqual:
+		return !modTime.Before(t.from)
+	case OperatorLess:
+		return modTime.Before(t.from)
+	case OperatorLessOrEqual:
+		return modTime.Before(t.to)
+	default:
+		return !modTime.Before(t.from) && modTime.Before(t.to)
+	}
+}
+
+// isKeyword returns true when the text would be read as a boolean operator
+func isKeyword(text string) bool {
+	return text == keywordAnd || text == keywordOr || text == keywordNot
+}
+
+var (
+	_ Expr = &And{}
+	_ Expr = &Or{}
+	_ Expr = &Not{}
+	_ Expr = &Term{}
+)
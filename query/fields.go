@@ -0,0 +1,202 @@
+package query
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is the name of the information compared by a term
+type Field string
+
+const (
+	// FieldText is a term without a field name: the text is searched in the path
+	FieldText     Field = ""
+	FieldName     Field = "name"
+	FieldPath     Field = "path"
+	FieldSize     Field = "size"
+	FieldModified Field = "modified"
+	FieldType     Field = "type"
+	FieldVolume   Field = "volume"
+	FieldLocation Field = "location"
+	FieldIncluded Field = "included"
+)
+
+// fields are the names of the fields which can be written in front of a value
+var fields = []Field{FieldName, FieldPath, FieldSize, FieldModified, FieldType, FieldVolume, FieldLocation, FieldIncluded}
+
+// Operator compares a size or a date
+type Operator string
+
+const (
+	OperatorEqual          Operator = "="
+	OperatorGreater        Operator = ">"
+	OperatorGreaterOrEqual Operator = ">="
+	OperatorLess           Operator = "<"
+	OperatorLessOrEqual    Operator = "<="
+)
+
+// compare returns the result of "value operator reference"
+func (o Operator) compare(value, reference int64) bool {
+	switch o {
+	case OperatorGreater:
+		return value > reference
+	case OperatorGreaterOrEqual:
+		return value >= reference
+	case OperatorLess:
+		return value < reference
+	case OperatorLessOrEqual:
+		return value <= reference
+	default:
+		return value == reference
+	}
+}
+
+// dateLayouts are the accepted precisions of a date, with the length of the period they cover
+var dateLayouts = []struct {
+	layout string
+	years  int
+	months int
+	days   int
+}{
+	{"2006", 1, 0, 0},
+	{"2006-01", 0, 1, 0},
+	{"2006-01-02", 0, 0, 1},
+}
+
+// sizeUnits are the multipliers of a size, in binary units like the sizes displayed
+var sizeUnits = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// newTerm parses the value of a term according to its field
+func newTerm(field Field, value string) (*Term, error) {
+	term := &Term{
+		Field:    field,
+		Operator: OperatorEqual,
+		Value:    value,
+	}
+	if field == FieldSize || field == FieldModified {
+		term.Operator, term.Value = cutOperator(value)
+	}
+	if term.Value == "" {
+		return nil, fmt.Errorf("missing value of field %q", field)
+	}
+
+	switch field {
+	case FieldText, FieldName, FieldPath, FieldVolume, FieldLocation:
+		term.pattern = strings.ToLower(term.Value)
+		term.glob = strings.ContainsAny(term.pattern, `*?[`)
+		if term.glob {
+			if _, err := path.Match(term.pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", term.Value, err)
+			}
+		}
+
+	case FieldSize:
+		size, err := parseSize(term.Value)
+		if err != nil {
+			return nil, err
+		}
+		term.size = size
+
+	case FieldModified:
+		from, to, err := parsePeriod(term.Value)
+		if err != nil {
+			return nil, err
+		}
+		term.from, term.to = from, to
+
+	case FieldType:
+		switch strings.ToLower(term.Value) {
+		case "dir", "directory":
+			term.flag = true
+		case "file":
+			term.flag = false
+		default:
+			return nil, fmt.Errorf("invalid type %q: expected file or dir", term.Value)
+		}
+
+	case FieldIncluded:
+		included, err := parseYesNo(term.Value)
+		if err != nil {
+			return nil, err
+		}
+		term.flag = included
+
+	default:
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	return term, nil
+}
+
+// newIncludedTerm matches the volumes included in search, or excluded from search
+func newIncludedTerm(included bool) *Term {
+	value := "no"
+	if included {
+		value = "yes"
+	}
+	return &Term{
+		Field:    FieldIncluded,
+		Operator: OperatorEqual,
+		Value:    value,
+		flag:     included,
+	}
+}
+
+// cutOperator separates the comparison operator in front of the value
+func cutOperator(value string) (Operator, string) {
+	for _, operator := range []Operator{OperatorGreaterOrEqual, OperatorLessOrEqual, OperatorGreater, OperatorLess, OperatorEqual} {
+		if rest, found := strings.CutPrefix(value, string(operator)); found {
+			return operator, rest
+		}
+	}
+	return OperatorEqual, value
+}
+
+// parseSize reads a size like 1500, 20M, 1.5GB or 4KiB. The units are powers of 1024.
+func parseSize(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	lower = strings.TrimSuffix(lower, "b")
+	lower = strings.TrimSuffix(lower, "i")
+	number := strings.TrimRight(lower, "kmgtp")
+	unit, found := sizeUnits[lower[len(number):]]
+	if !found || number == "" {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(size * unit), nil
+}
+
+// parsePeriod reads a year, a month or a day, and returns the period [from, to) in local time
+func parsePeriod(value string) (time.Time, time.Time, error) {
+	for _, date := range dateLayouts {
+		from, err := time.ParseInLocation(date.layout, value, time.Local)
+		if err == nil {
+			return from, from.AddDate(date.years, date.months, date.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: expected YYYY, YYYY-MM or YYYY-MM-DD", value)
+}
+
+// parseYesNo reads a boolean value
+func parseYesNo(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return true, nil
+	case "no", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid value %q: expected yes or no", value)
+	}
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// scores of a word of the search found in a word of the file name.
+// A word found in the directories counts half.
+const (
+	scoreExactWord  = 10
+	scorePrefixWord = 7
+	scoreTypo       = 6 // minus 2 for each edit
+	scoreSubsequent = 2 // letters of the word found in order, like an abbreviation
+	scoreInsideWord = 3 // found in the middle of a word of the file name
+	scoreInsideDir  = 1 // found in the middle of a word of the directories
+	scoreBasename   = 20
+)
+
+// Fuzzy is a search tolerant to typos, ranking the files by relevance
+type Fuzzy struct {
+	words []string
+}
+
+// NewFuzzy returns a fuzzy search of the words of the text
+func NewFuzzy(text string) (*Fuzzy, error) {
+	words := splitWords(text)
+	if len(words) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return &Fuzzy{words: words}, nil
+}
+
+// Score returns the relevance of the file, and false when the file doesn't match.
+// Each word of the search must be found in the path, with a few typos at most:
+// the words matching the file name score more than the ones matching the directories,
+// and the whole word scores more than a part of it. A file matching all the words of its name
+// gets a bonus, and each level of directory costs a point.
+func (f *Fuzzy) Score(filePath string) (int, bool) {
+	name := strings.ToLower(path.Base(filePath))
+	dir := strings.ToLower(path.Dir(filePath))
+	if dir == "." {
+		dir = ""
+	}
+	nameWords := splitWords(name)
+	dirWords := splitWords(dir)
+
+	score := 0
+	for _, word := range f.words {
+		wordScore := matchWord(word, nameWords)
+		if wordScore == 0 {
+			wordScore = matchWord(word, dirWords) / 2
+		}
+		if wordScore == 0 && strings.Contains(name, word) {
+			wordScore = scoreInsideWord
+		}
+		if wordScore == 0 && strings.Contains(dir, word) {
+			wordScore = scoreInsideDir
+		}
+		if wordScore == 0 {
+			return 0, false
+		}
+		score += wordScore
+	}
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	if strings.Join(splitWords(stem), " ") == strings.Join(f.words, " ") {
+		score += scoreBasename
+	}
+	score -= strings.Count(filePath, "/")
+	// still a match
+	return max(score, 1), true
+}
+
+// matchWord returns the best score of the word found in the list
+func matchWord(word string, list []string) int {
+	best := 0
+	for _, candidate := range list {
+		best = max(best, scoreWord(word, candidate))
+		if best == scoreExactWord {
+			break
+		}
+	}
+	return best
+}
+
+// scoreWord compares a word of the search with a word of the path
+func scoreWord(word, candidate string) int {
+	if word == candidate {
+		return scoreExactWord
+	}
+	if strings.HasPrefix(candidate, word) {
+		return scorePrefixWord
+	}
+	allowed := allowedTypos(word)
+	if edits := fuzzy.LevenshteinDistance(word, candidate); edits <= allowed {
+		return scoreTypo - 2*edits
+	}
+	length, runes := len([]rune(word)), []rune(candidate)
+	if allowed > 0 && len(runes) > length {
+		// typo in the beginning of the word
+		if edits := fuzzy.LevenshteinDistance(word, string(runes[:length])); edits <= allowed {
+			return scoreTypo - 2*edits - 1
+		}
+	}
+	if len(word) > 1 && fuzzy.Match(word, candidate) {
+		return scoreSubsequent
+	}
+	return 0
+}
+
+// allowedTypos is the number of edits accepted for a word: none for a short word
+func allowedTypos(word string) int {
+	length := len([]rune(word))
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// splitWords returns the lowercase words of the text, separated by anything but letters and digits
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,79 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWords(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"wedding", "speech", "final", "v2", "docx"}, splitWords("Wedding_Speech-FINAL v2.docx"))
+	assert.Empty(t, splitWords(" -_. "))
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewFuzzy(" . ")
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+
+	search, err := NewFuzzy("wedding speach final v2")
+	require.NoError(t, err)
+
+	for _, path := range []string{
+		"Documents/Wedding speech final v2.docx",
+		"Documents/wedding/speech_FINAL_v2.odt",
+		"Wedding/Speeches/final-v2.txt",
+	} {
+		_, found := search.Score(path)
+		assert.True(t, found, path)
+	}
+	for _, path := range []string{
+		"Documents/Wedding speech final.docx",
+		"Documents/birthday speech final v2.docx",
+	} {
+		_, found := search.Score(path)
+		assert.False(t, found, path)
+	}
+}
+
+func TestFuzzyRanking(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		search string
+		better string
+		worse  string
+	}{
+		// exact basename
+		{"budget 2019", "Finance/Budget 2019.xlsx", "Finance/Budget 2019 draft.xlsx"},
+		// whole word against part of a word
+		{"report", "Work/report.pdf", "Work/reporting.pdf"},
+		// prefix of a word against inside a word
+		{"port", "Work/portfolio.pdf", "Work/report.pdf"},
+		// no typo against a typo
+		{"holiday", "Photos/holiday.jpg", "Photos/holyday.jpg"},
+		// file name against directory
+		{"rome", "Photos/rome.jpg", "Rome/photo.jpg"},
+		// depth
+		{"notes", "notes.txt", "Archive/2019/notes.txt"},
+		// typo against a typo in a prefix
+		{"speach", "speech.txt", "speeches.txt"},
+		// abbreviation
+		{"fnl", "Documents/fnl.txt", "Documents/final.txt"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.search, func(t *testing.T) {
+			search, err := NewFuzzy(testCase.search)
+			require.NoError(t, err)
+			better, found := search.Score(testCase.better)
+			require.True(t, found, testCase.better)
+			worse, found := search.Score(testCase.worse)
+			require.True(t, found, testCase.worse)
+			assert.Greater(t, better, worse)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenWord
+	tokenString
+	tokenLeftParen
+	tokenRightParen
+)
+
+// token is a word, a quoted string or a parenthesis, with its position in the query
+type token struct {
+	Type  tokenType
+	Value string
+	Start int
+	End   int
+}
+
+// isKeyword returns true when the token is one of the boolean operators
+func (t token) isKeyword(keyword string) bool {
+	return t.Type == tokenWord && t.Value == keyword
+}
+
+// tokenize splits the query into tokens. The list always ends with a tokenEOF token.
+func tokenize(input string) ([]token, error) {
+	tokens := make([]token, 0, 8)
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{Type: tokenLeftParen, Value: "(", Start: i, End: i + 1})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{Type: tokenRightParen, Value: ")", Start: i, End: i + 1})
+			i++
+
+		case r == '"':
+			start := i
+			value := &strings.Builder{}
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%w at position %d: missing closing quote", ErrSyntax, start+1)
+			}
+			i++
+			tokens = append(tokens, token{Type: tokenString, Value: value.String(), Start: start, End: i})
+
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{Type: tokenWord, Value: string(runes[start:i]), Start: start, End: i})
+		}
+	}
+	tokens = append(tokens, token{Type: tokenEOF, Start: len(runes), End: len(runes)})
+	return tokens, nil
+}
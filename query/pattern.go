@@ -0,0 +1,171 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+var (
+	ErrInvalidPattern = errors.New("Invalid pattern")
+)
+
+// Pattern is a regular expression or a glob pattern matched against the path of the files.
+// The literal text at the beginning of the pattern, if any, narrows down the paths to check.
+type Pattern struct {
+	source string
+	regexp *regexp.Regexp
+	prefix string
+}
+
+// CompileRegex returns a pattern finding the regular expression anywhere in the path, unless it's anchored with ^ or $.
+// The paths are compared with case, unless the expression starts with (?i).
+func CompileRegex(expr string) (*Pattern, error) {
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	// the expression has already been checked
+	tree, _ := syntax.Parse(expr, syntax.Perl)
+	return &Pattern{
+		source: expr,
+		regexp: compiled,
+		prefix: anchoredPrefix(tree.Simplify()),
+	}, nil
+}
+
+// CompileGlob returns a pattern matching the whole path, with case. The glob can contain:
+//
+//	*       any characters but a slash
+//	**      any characters including slashes: "**/" also matches no directory at all
+//	?       any character but a slash
+//	[a-z]   a character of the class, [!a-z] a character not in the class
+//	{a,b}   one of the alternatives
+//	\*      the character after the backslash
+func CompileGlob(glob string) (*Pattern, error) {
+	expr, prefix, err := globToRegexp(glob)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return &Pattern{
+		source: glob,
+		regexp: compiled,
+		prefix: prefix,
+	}, nil
+}
+
+// Match returns true when the path matches the pattern
+func (p *Pattern) Match(path string) bool {
+	return p.regexp.MatchString(path)
+}
+
+// Prefix returns the text all the matching paths start with, or a blank string when the paths can start with anything
+func (p *Pattern) Prefix() string {
+	return p.prefix
+}
+
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// anchoredPrefix returns the literal text following the ^ at the beginning of the expression
+func anchoredPrefix(tree *syntax.Regexp) string {
+	if tree.Op != syntax.OpConcat || len(tree.Sub) < 2 || tree.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	literal := tree.Sub[1]
+	if literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return ""
+	}
+	return string(literal.Rune)
+}
+
+// globToRegexp returns the regular expression of the glob, with the literal text at the beginning of the glob
+func globToRegexp(glob string) (string, string, error) {
+	expr := &strings.Builder{}
+	prefix := &strings.Builder{}
+	literal := true // still in the literal prefix
+	depth := 0      // of the alternatives
+	expr.WriteString("^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '*':
+			literal = false
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+					continue
+				}
+				expr.WriteString(".*")
+				continue
+			}
+			expr.WriteString("[^/]*")
+
+		case r == '?':
+			literal = false
+			expr.WriteString("[^/]")
+
+		case r == '[':
+			literal = false
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return "", "", fmt.Errorf("%w: missing ] in %q", ErrInvalidPattern, glob)
+			}
+			class := runes[i+1 : end]
+			expr.WriteString("[")
+			if class[0] == '!' {
+				expr.WriteString("^")
+				class = class[1:]
+			}
+			expr.WriteString(strings.ReplaceAll(string(class), `[`, `\[`))
+			expr.WriteString("]")
+			i = end
+
+		case r == '{':
+			literal = false
+			depth++
+			expr.WriteString("(?:")
+
+		case r == ',' && depth > 0:
+			expr.WriteString("|")
+
+		case r == '}' && depth > 0:
+			depth--
+			expr.WriteString(")")
+
+		default:
+			if r == '\\' && i+1 < len(runes) {
+				i++
+				r = runes[i]
+			}
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			if literal {
+				prefix.WriteRune(r)
+			}
+		}
+	}
+	if depth > 0 {
+		return "", "", fmt.Errorf("%w: missing } in %q", ErrInvalidPattern, glob)
+	}
+	expr.WriteString("$")
+	return expr.String(), prefix.String(), nil
+}
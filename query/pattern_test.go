@@ -0,0 +1,81 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		glob    string
+		prefix  string
+		matches []string
+		others  []string
+	}{
+		{"*.psd", "", []string{"a.psd", ".psd"}, []string{"dir/a.psd", "a.psd.bak", "a.PSD"}},
+		{"**/*.{psd,ai}", "", []string{"a.psd", "dir/a.ai", "a/b/c.psd"}, []string{"a.pdf", "dir/a.aix"}},
+		{"Projects/**/build/", "Projects/", []string{"Projects/build/", "Projects/a/b/build/"}, []string{"Projects/a/build", "projects/build/"}},
+		{"Projects/**", "Projects/", []string{"Projects/", "Projects/a/b"}, []string{"Projects"}},
+		{"IMG_????.CR2", "IMG_", []string{"IMG_0001.CR2"}, []string{"IMG_01.CR2", "IMG_0/01.CR2"}},
+		{"file[0-9].txt", "file", []string{"file1.txt"}, []string{"filea.txt"}},
+		{"file[!0-9].txt", "file", []string{"filea.txt"}, []string{"file1.txt"}},
+		{"{a,b{c,d}}/x", "", []string{"a/x", "bc/x", "bd/x"}, []string{"b/x"}},
+		{`a\*b,c}`, "a*b,c}", []string{"a*b,c}"}, []string{"axb,c}"}},
+		{"a.b(c)", "a.b(c)", []string{"a.b(c)"}, []string{"axb(c)"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.glob, func(t *testing.T) {
+			pattern, err := CompileGlob(testCase.glob)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.prefix, pattern.Prefix())
+			for _, path := range testCase.matches {
+				assert.True(t, pattern.Match(path), path)
+			}
+			for _, path := range testCase.others {
+				assert.False(t, pattern.Match(path), path)
+			}
+		})
+	}
+
+	for _, glob := range []string{"file[0-9", "{a,b", "[]"} {
+		_, err := CompileGlob(glob)
+		assert.ErrorIs(t, err, ErrInvalidPattern, glob)
+	}
+}
+
+func TestRegex(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		expr    string
+		prefix  string
+		matches []string
+		others  []string
+	}{
+		{"^Projects/.*/build/", "Projects/", []string{"Projects/a/build/x"}, []string{"Old/Projects/a/build/"}},
+		{"/build/", "", []string{"Projects/a/build/x"}, []string{"build/"}},
+		{`^IMG_\d+\.CR2$`, "IMG_", []string{"IMG_01.CR2"}, []string{"IMG_01.CR2.xmp"}},
+		{"^(?i)img", "", []string{"IMG_01.CR2"}, []string{"a/img"}},
+		{"^(a|b)/", "", []string{"a/x"}, []string{"c/x"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.expr, func(t *testing.T) {
+			pattern, err := CompileRegex(testCase.expr)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.prefix, pattern.Prefix())
+			for _, path := range testCase.matches {
+				assert.True(t, pattern.Match(path), path)
+			}
+			for _, path := range testCase.others {
+				assert.False(t, pattern.Match(path), path)
+			}
+		})
+	}
+
+	_, err := CompileRegex("(unclosed")
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+}
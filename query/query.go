@@ -0,0 +1,201 @@
+// Package query parses and evaluates the search queries of the catalogue.
+//
+// A query is a list of terms, all of them must match:
+//
+//	name:*.cr2 size:>20M modified:2015 volume:"Photos*" location:office type:file
+//
+// A term without a field matches a text anywhere in the path of the file.
+// Terms can be combined with AND, OR and NOT (in capital letters), and grouped with parentheses:
+//
+//	(name:*.cr2 OR name:*.nef) NOT location:office
+package query
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	keywordAnd = "AND"
+	keywordOr  = "OR"
+	keywordNot = "NOT"
+)
+
+var (
+	ErrSyntax     = errors.New("Syntax error")
+	ErrEmptyQuery = errors.New("The query cannot be empty")
+)
+
+// Parse returns the expression of the query.
+// The volumes excluded from search are only matched when the query has an "included:" term:
+// otherwise the query is combined with "included:yes".
+func Parse(input string) (Expr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].Type == tokenEOF {
+		return nil, ErrEmptyQuery
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.Type != tokenEOF {
+		return nil, p.errorf(next, "unexpected %q", next.Value)
+	}
+	if !hasField(expr, FieldIncluded) {
+		expr = &And{Left: expr, Right: newIncludedTerm(true)}
+	}
+	return expr, nil
+}
+
+// parser is a recursive descent parser of the grammar:
+//
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = "NOT" unary | primary
+//	primary = "(" or ")" | term
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	current := p.tokens[p.pos]
+	if current.Type != tokenEOF {
+		p.pos++
+	}
+	return current
+}
+
+func (p *parser) errorf(at token, format string, args ...any) error {
+	return fmt.Errorf("%w at position %d: %s", ErrSyntax, at.Start+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword(keywordOr) {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := p.peek()
+		if next.Type == tokenEOF || next.Type == tokenRightParen || next.isKeyword(keywordOr) {
+			return left, nil
+		}
+		if next.isKeyword(keywordAnd) {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().isKeyword(keywordNot) {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	current := p.next()
+	switch current.Type {
+	case tokenLeftParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.Type != tokenRightParen {
+			return nil, p.errorf(closing, "missing closing parenthesis")
+		}
+		return expr, nil
+
+	case tokenString:
+		return p.newTerm(current, FieldText, current.Value)
+
+	case tokenWord:
+		if current.isKeyword(keywordAnd) || current.isKeyword(keywordOr) || current.isKeyword(keywordNot) {
+			return nil, p.errorf(current, "unexpected %s", current.Value)
+		}
+		field, value, found := strings.Cut(current.Value, ":")
+		if !found || !isFieldName(field) {
+			return p.newTerm(current, FieldText, current.Value)
+		}
+		if value == "" {
+			// quoted value right after the colon
+			if next := p.peek(); next.Type == tokenString && next.Start == current.End {
+				value = p.next().Value
+			}
+		}
+		return p.newTerm(current, Field(strings.ToLower(field)), value)
+
+	case tokenEOF:
+		return nil, p.errorf(current, "unexpected end of query")
+
+	default:
+		return nil, p.errorf(current, "unexpected %q", current.Value)
+	}
+}
+
+// newTerm returns the term read from the token, or a syntax error at the position of the token
+func (p *parser) newTerm(at token, field Field, value string) (Expr, error) {
+	term, err := newTerm(field, value)
+	if err != nil {
+		return nil, p.errorf(at, "%v", err)
+	}
+	return term, nil
+}
+
+// isFieldName returns true when the text before a colon is the name of a field.
+// Any other text, like a Windows drive or a URL scheme, is part of a text term.
+func isFieldName(name string) bool {
+	return slices.Contains(fields, Field(strings.ToLower(name)))
+}
+
+// hasField returns true when a term of the expression is on the field
+func hasField(expr Expr, field Field) bool {
+	switch e := expr.(type) {
+	case *And:
+		return hasField(e.Left, field) || hasField(e.Right, field)
+	case *Or:
+		return hasField(e.Left, field) || hasField(e.Right, field)
+	case *Not:
+		return hasField(e.Expr, field)
+	case *Term:
+		return e.Field == field
+	default:
+		return false
+	}
+}
@@ -0,0 +1,193 @@
+package query
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/creativeprojects/catalogue/volume"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"holiday", "(holiday AND included:yes)"},
+		{"name:*.cr2 size:>20M", "((name:*.cr2 AND size:>20M) AND included:yes)"},
+		{`volume:"Photos*" location:office`, "((volume:Photos* AND location:office) AND included:yes)"},
+		{`"two words" OR NOT type:dir`, `(("two words" OR NOT type:dir) AND included:yes)`},
+		{"a OR b c", "((a OR (b AND c)) AND included:yes)"},
+		{"(a OR b) AND c", "(((a OR b) AND c) AND included:yes)"},
+		{"NOT NOT a", "(NOT NOT a AND included:yes)"},
+		{"modified:<=2015-03 included:no", "(modified:<=2015-03 AND included:no)"},
+		{`"AND"`, `("AND" AND included:yes)`},
+		{"C:/path", `("C:/path" AND included:yes)`},
+		{"http:foo", `("http:foo" AND included:yes)`},
+		{"nmae:file", `("nmae:file" AND included:yes)`},
+		{"NAME:file", "(name:file AND included:yes)"},
+		{`path:"my \"docs\""`, `(path:"my \"docs\"" AND included:yes)`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.query, func(t *testing.T) {
+			expr, err := Parse(testCase.query)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, expr.String())
+
+			// the string can be parsed again
+			again, err := Parse(expr.String())
+			require.NoError(t, err)
+			assert.Equal(t, expr.String(), again.String())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("  ")
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+
+	for _, input := range []string{
+		"(a OR b",
+		"a OR",
+		"AND a",
+		"a)",
+		`"unfinished`,
+		"size:big",
+		"size:>",
+		"modified:2015-13",
+		"type:link",
+		"included:maybe",
+		"name:[a",
+		"[a",
+		"name:",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, ErrSyntax)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value    string
+		expected int64
+	}{
+		{"1500", 1500},
+		{"12B", 12},
+		{"4k", 4096},
+		{"4KiB", 4096},
+		{"20M", 20 << 20},
+		{"20MB", 20 << 20},
+		{"1.5G", 3 << 29},
+	}
+	for _, testCase := range testCases {
+		size, err := parseSize(testCase.value)
+		require.NoError(t, err, testCase.value)
+		assert.Equal(t, testCase.expected, size, testCase.value)
+	}
+	for _, value := range []string{"", "M", "20X", "20mm", "-1"} {
+		_, err := parseSize(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	photos := &volume.Volume{Name: "Photos 2015", Location: "Office shelf", IncludeInSearch: true}
+	archive := &volume.Volume{Name: "Archive", Location: "Home", IncludeInSearch: false}
+	raw := &File{
+		Volume:  photos,
+		Path:    "Trips/Rome/IMG_0001.CR2",
+		Size:    25 << 20,
+		ModTime: time.Date(2015, time.June, 12, 10, 0, 0, 0, time.Local),
+	}
+	small := &File{
+		Volume:  photos,
+		Path:    "Trips/Rome/IMG_0002.cr2",
+		Size:    5 << 20,
+		ModTime: time.Date(2016, time.January, 1, 0, 0, 0, 0, time.Local),
+	}
+	dir := &File{
+		Volume: photos,
+		Path:   "Trips/Rome",
+		Mode:   os.ModeDir,
+	}
+	archived := &File{
+		Volume: archive,
+		Path:   "Trips/Rome/IMG_0001.CR2",
+		Size:   25 << 20,
+	}
+
+	testCases := []struct {
+		query    string
+		expected []*File
+	}{
+		{"rome", []*File{raw, small, dir}},
+		{"name:*.cr2", []*File{raw, small}},
+		{"name:rome", []*File{dir}},
+		{"path:trips/*", []*File{dir}},
+		{"size:>20M", []*File{raw}},
+		{"size:<=5M type:file", []*File{small}},
+		{"modified:2015", []*File{raw}},
+		{"modified:2015-06-12", []*File{raw}},
+		{"modified:>2015", []*File{small}},
+		{"modified:>=2015 modified:<2016", []*File{raw}},
+		{"modified:<=2015", []*File{raw, dir}},
+		{`volume:"photos*" location:office type:dir`, []*File{dir}},
+		{"type:dir OR size:<10M", []*File{small, dir}},
+		{"name:*.cr2 NOT (size:>20M OR modified:2016)", []*File{}},
+		{"included:no", []*File{archived}},
+		{"location:home", []*File{}},
+		{"location:home OR included:no", []*File{archived}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.query, func(t *testing.T) {
+			expr, err := Parse(testCase.query)
+			require.NoError(t, err)
+			found := make([]*File, 0, len(testCase.expected))
+			for _, file := range []*File{raw, small, dir, archived} {
+				if expr.Match(file) {
+					found = append(found, file)
+				}
+			}
+			assert.Equal(t, testCase.expected, found)
+		})
+	}
+}
+
+func TestMatchVolume(t *testing.T) {
+	t.Parallel()
+
+	photos := &volume.Volume{Name: "Photos", Location: "Office", IncludeInSearch: true}
+	testCases := []struct {
+		query    string
+		expected VolumeMatch
+	}{
+		{"volume:photos", MatchAll},
+		{"volume:archive", MatchNone},
+		{"NOT volume:archive", MatchAll},
+		{"name:*.cr2", MatchSome},
+		{"name:*.cr2 volume:archive", MatchNone},
+		{"name:*.cr2 OR volume:photos", MatchAll},
+		{"name:*.cr2 OR volume:archive", MatchSome},
+		{"location:office included:no", MatchNone},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.query, func(t *testing.T) {
+			expr, err := Parse(testCase.query)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, expr.MatchVolume(photos))
+		})
+	}
+}
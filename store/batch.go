@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxBatchSize  = 1000
+	DefaultMaxBatchDelay = 10 * time.Millisecond
+)
+
+// errTrySolo is returned to a caller when its function failed inside a batch: it should run again on its own
+var errTrySolo = errors.New("Batch function returned an error and should run solo")
+
+// batcher combines concurrent calls to Batch into one write transaction, the same way bolt.DB.Batch does
+type batcher struct {
+	update   func(func(transaction Transaction) error) error
+	maxSize  int
+	maxDelay time.Duration
+	mutex    *sync.Mutex
+	current  *batch
+}
+
+type batchCall struct {
+	job func(transaction Transaction) error
+	err chan<- error
+}
+
+type batch struct {
+	batcher *batcher
+	timer   *time.Timer
+	start   *sync.Once
+	calls   []batchCall
+}
+
+func newBatcher(update func(func(transaction Transaction) error) error, maxSize int, maxDelay time.Duration) *batcher {
+	return &batcher{
+		update:   update,
+		maxSize:  maxSize,
+		maxDelay: maxDelay,
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// Batch runs the job with other concurrent jobs in the same transaction.
+// The job can be called more than once, so it must be idempotent.
+func (b *batcher) Batch(job func(transaction Transaction) error) error {
+	errChannel := make(chan error, 1)
+
+	b.mutex.Lock()
+	if b.current == nil || len(b.current.calls) >= b.maxSize {
+		// start a new batch
+		b.current = &batch{
+			batcher: b,
+			start:   &sync.Once{},
+		}
+		b.current.timer = time.AfterFunc(b.maxDelay, b.current.trigger)
+	}
+	b.current.calls = append(b.current.calls, batchCall{job: job, err: errChannel})
+	if len(b.current.calls) >= b.maxSize {
+		// the batch is full, no need to wait any longer
+		go b.current.trigger()
+	}
+	b.mutex.Unlock()
+
+	err := <-errChannel
+	if err == errTrySolo {
+		err = b.update(job)
+	}
+	return err
+}
+
+// trigger runs the batch, only once
+func (b *batch) trigger() {
+	b.start.Do(b.run)
+}
+
+// run executes all the jobs of the batch in one transaction.
+// If a job fails, it is taken out of the batch and the others run again in a new transaction.
+func (b *batch) run() {
+	b.batcher.mutex.Lock()
+	b.timer.Stop()
+	// no more jobs can be added to this batch
+	if b.batcher.current == b {
+		b.batcher.current = nil
+	}
+	b.batcher.mutex.Unlock()
+
+	for len(b.calls) > 0 {
+		failed := -1
+		err := b.batcher.update(func(transaction Transaction) error {
+			for i, call := range b.calls {
+				if err := safelyRun(call.job, transaction); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+
+		if failed >= 0 {
+			call := b.calls[failed]
+			b.calls[failed], b.calls = b.calls[len(b.calls)-1], b.calls[:len(b.calls)-1]
+			call.err <- errTrySolo
+			continue
+		}
+
+		for _, call := range b.calls {
+			call.err <- err
+		}
+		break
+	}
+}
+
+// safelyRun converts a panic into an error
+func safelyRun(job func(transaction Transaction) error, transaction Transaction) (err error) {
+	defer func() {
+		if reason := recover(); reason != nil {
+			err = fmt.Errorf("panic: %v", reason)
+		}
+	}()
+	return job(transaction)
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchOptions configures the size and the time limits of a BatchWriter
+type BatchOptions struct {
+	// MaxSize is the maximum number of items committed in one transaction
+	MaxSize int
+	// MaxDelay is the maximum time an item can wait before being committed
+	MaxDelay time.Duration
+	// OnError is called when a transaction fails to commit
+	OnError func(err error)
+}
+
+// BatchWriter groups many small items into fewer transactions.
+// The items are saved together when the batch is full or when it gets too old: the save function receives
+// all the items of the batch in the order they were added, so it can write them in the order of the keys.
+// If a transaction fails, the items in it are lost and the error is returned by all the next calls.
+type BatchWriter[T any] struct {
+	store   Store
+	options BatchOptions
+	save    func(transaction Transaction, items []T) error
+	mutex   *sync.Mutex
+	items   []T
+	timer   *time.Timer
+	err     error
+}
+
+// NewBatchWriter creates a BatchWriter on the store. The transactions are committed with Store.Batch,
+// so the save function can be called more than once for the same items: it must be idempotent.
+func NewBatchWriter[T any](store Store, options BatchOptions, save func(transaction Transaction, items []T) error) *BatchWriter[T] {
+	if options.MaxSize < 1 {
+		options.MaxSize = DefaultMaxBatchSize
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = time.Second
+	}
+	return &BatchWriter[T]{
+		store:   store,
+		options: options,
+		save:    save,
+		mutex:   &sync.Mutex{},
+		items:   make([]T, 0, options.MaxSize),
+	}
+}
+
+// Add queues the item into the current batch. It returns the error of a previous failed commit.
+func (w *BatchWriter[T]) Add(item T) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	w.items = append(w.items, item)
+	if len(w.items) == 1 {
+		w.timer = time.AfterFunc(w.options.MaxDelay, func() {
+			_ = w.Flush()
+		})
+	}
+	if len(w.items) >= w.options.MaxSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Flush commits all the items waiting in the batch
+func (w *BatchWriter[T]) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.flush()
+}
+
+// Close commits the last items, and returns the first error met by the BatchWriter
+func (w *BatchWriter[T]) Close() error {
+	return w.Flush()
+}
+
+func (w *BatchWriter[T]) flush() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.err != nil || len(w.items) == 0 {
+		return w.err
+	}
+	items := w.items
+	w.items = make([]T, 0, w.options.MaxSize)
+
+	err := w.store.Batch(func(transaction Transaction) error {
+		return w.save(transaction, items)
+	})
+	if err != nil {
+		w.err = err
+		if w.options.OnError != nil {
+			w.options.OnError(err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,228 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putJob(bucketName, key string) func(transaction Transaction) error {
+	return func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, []byte(key))
+	}
+}
+
+// runJobs is the save function of a BatchWriter of jobs
+func runJobs(transaction Transaction, jobs []func(transaction Transaction) error) error {
+	for _, job := range jobs {
+		if err := job(transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func countKeys(t *testing.T, store Store, bucketName string) int {
+	t.Helper()
+
+	count := 0
+	err := store.View(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key string, data []byte) error {
+			count++
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	return count
+}
+
+func newStoreWithBucket(t testing.TB, bucketName string) *MemoryStore {
+	store := NewMemoryStore()
+	err := store.Update(func(transaction Transaction) error {
+		_, err := transaction.CreateBucket(bucketName)
+		return err
+	})
+	require.NoError(t, err)
+	return store
+}
+
+func TestMemoryBatchCombinesConcurrentCalls(t *testing.T) {
+	t.Parallel()
+
+	store := newStoreWithBucket(t, "bucket")
+	defer store.Close()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.Batch(putJob("bucket", strconv.Itoa(i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 100, countKeys(t, store, "bucket"))
+	// with a default delay of 10ms the calls cannot have run in 100 transactions
+	assert.Less(t, store.nextTransactionID, uint(100))
+}
+
+func TestMemoryBatchRunsFailingCallOnItsOwn(t *testing.T) {
+	t.Parallel()
+
+	store := newStoreWithBucket(t, "bucket")
+	defer store.Close()
+
+	failure := errors.New("failure")
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i == 5 {
+				err := store.Batch(func(transaction Transaction) error {
+					return failure
+				})
+				assert.Equal(t, failure, err)
+				return
+			}
+			err := store.Batch(putJob("bucket", strconv.Itoa(i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 9, countKeys(t, store, "bucket"))
+}
+
+func TestBatchWriterCommitsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	store := newStoreWithBucket(t, "bucket")
+	defer store.Close()
+
+	writer := NewBatchWriter(store, BatchOptions{MaxSize: 10, MaxDelay: time.Hour}, runJobs)
+	for i := 0; i < 25; i++ {
+		require.NoError(t, writer.Add(putJob("bucket", strconv.Itoa(i))))
+	}
+	assert.Equal(t, 20, countKeys(t, store, "bucket"))
+
+	require.NoError(t, writer.Close())
+	assert.Equal(t, 25, countKeys(t, store, "bucket"))
+}
+
+func TestBatchWriterCommitsAfterDelay(t *testing.T) {
+	t.Parallel()
+
+	store := newStoreWithBucket(t, "bucket")
+	defer store.Close()
+
+	writer := NewBatchWriter(store, BatchOptions{MaxSize: 1000, MaxDelay: 10 * time.Millisecond}, runJobs)
+	defer writer.Close()
+
+	require.NoError(t, writer.Add(putJob("bucket", "key")))
+	assert.Eventually(t, func() bool {
+		return countKeys(t, store, "bucket") == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBatchWriterReportsFailedCommit(t *testing.T) {
+	t.Parallel()
+
+	store := newStoreWithBucket(t, "bucket")
+	defer store.Close()
+
+	var reported atomic.Int32
+	writer := NewBatchWriter(store, BatchOptions{
+		MaxSize:  2,
+		MaxDelay: time.Hour,
+		OnError: func(err error) {
+			reported.Add(1)
+		},
+	}, runJobs)
+
+	require.NoError(t, writer.Add(putJob("bucket", "1")))
+	err := writer.Add(putJob("unknown-bucket", "2"))
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	assert.Equal(t, int32(1), reported.Load())
+
+	// nothing from the failed batch was saved
+	assert.Equal(t, 0, countKeys(t, store, "bucket"))
+
+	// the error is sticky
+	err = writer.Add(putJob("bucket", "3"))
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	err = writer.Close()
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	assert.Equal(t, int32(1), reported.Load())
+}
+
+func BenchmarkWrites(b *testing.B) {
+	backends := []struct {
+		name  string
+		store func(b *testing.B) Store
+	}{
+		{"InMemory", func(b *testing.B) Store {
+			return newStoreWithBucket(b, "bucket")
+		}},
+		{"BoltDB", func(b *testing.B) Store {
+			store, err := NewBoltStore(filepath.Join(b.TempDir(), "bench.db"))
+			require.NoError(b, err)
+			err = store.Update(func(transaction Transaction) error {
+				_, err := transaction.CreateBucket("bucket")
+				return err
+			})
+			require.NoError(b, err)
+			return store
+		}},
+	}
+	for _, backend := range backends {
+		b.Run(backend.name+"/UpdatePerKey", func(b *testing.B) {
+			store := backend.store(b)
+			defer store.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := store.Update(putJob("bucket", fmt.Sprintf("key-%012d", i)))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "keys/s")
+		})
+
+		b.Run(backend.name+"/BatchWriter", func(b *testing.B) {
+			store := backend.store(b)
+			defer store.Close()
+
+			writer := NewBatchWriter(store, BatchOptions{MaxSize: 10000, MaxDelay: time.Second}, runJobs)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := writer.Add(putJob("bucket", fmt.Sprintf("key-%012d", i)))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			err := writer.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "keys/s")
+		})
+	}
+}
@@ -39,7 +39,7 @@ func (b *BoltBucket) Put(key string, data []byte) error {
 		return ErrBucketReadOnly
 	}
 
-	return b.bucket.Put([]byte(key), data)
+	return convertBoltError(b.bucket.Put([]byte(key), data))
 }
 
 func (b *BoltBucket) Delete(key string) error {
@@ -53,18 +53,30 @@ func (b *BoltBucket) Delete(key string) error {
 		return ErrBucketReadOnly
 	}
 
-	return b.bucket.Delete([]byte(key))
+	return convertBoltError(b.bucket.Delete([]byte(key)))
 }
 
 func (b *BoltBucket) CreateBucket(name string) (Bucket, error) {
+	if b == nil {
+		return nil, ErrNullPointerBucket
+	}
+	if name == "" {
+		return nil, ErrBucketNoName
+	}
 	bucket, err := b.bucket.CreateBucket([]byte(name))
 	if err != nil {
-		return nil, err
+		return nil, convertBoltError(err)
 	}
 	return newBoltBucket(bucket), nil
 }
 
 func (b *BoltBucket) GetBucket(name string) (Bucket, error) {
+	if b == nil {
+		return nil, ErrNullPointerBucket
+	}
+	if name == "" {
+		return nil, ErrBucketNoName
+	}
 	bucket := b.bucket.Bucket([]byte(name))
 	if bucket == nil {
 		return nil, ErrBucketNotFound
@@ -73,7 +85,100 @@ func (b *BoltBucket) GetBucket(name string) (Bucket, error) {
 }
 
 func (b *BoltBucket) DeleteBucket(name string) error {
-	return b.bucket.DeleteBucket([]byte(name))
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	if name == "" {
+		return ErrBucketNoName
+	}
+	return convertBoltError(b.bucket.DeleteBucket([]byte(name)))
+}
+
+// NextSequence increments and returns the sequence of the bucket
+func (b *BoltBucket) NextSequence() (uint64, error) {
+	if b == nil {
+		return 0, ErrNullPointerBucket
+	}
+	if !b.bucket.Writable() {
+		return 0, ErrBucketReadOnly
+	}
+	sequence, err := b.bucket.NextSequence()
+	return sequence, convertBoltError(err)
+}
+
+// Sequence returns the current sequence of the bucket
+func (b *BoltBucket) Sequence() uint64 {
+	if b == nil {
+		return 0
+	}
+	return b.bucket.Sequence()
+}
+
+// SetSequence changes the sequence of the bucket
+func (b *BoltBucket) SetSequence(value uint64) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	if !b.bucket.Writable() {
+		return ErrBucketReadOnly
+	}
+	return convertBoltError(b.bucket.SetSequence(value))
+}
+
+// Stats returns the storage used by the bucket and its nested buckets
+func (b *BoltBucket) Stats() BucketStats {
+	if b == nil {
+		return BucketStats{}
+	}
+	stats := b.bucket.Stats()
+	// bolt counts the nested buckets as keys, and the bucket itself as a bucket
+	return BucketStats{
+		Keys:      stats.KeyN - stats.BucketN + 1,
+		Buckets:   stats.BucketN - 1,
+		InUse:     stats.BranchInuse + stats.LeafInuse + stats.InlineBucketInuse,
+		Allocated: stats.BranchAlloc + stats.LeafAlloc,
+		Pages:     stats.BranchPageN + stats.LeafPageN + stats.BranchOverflowN + stats.LeafOverflowN,
+	}
+}
+
+// ForEachBucket runs the function on the name of every nested bucket
+func (b *BoltBucket) ForEachBucket(fn func(name string) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return b.bucket.ForEach(func(key, data []byte) error {
+		if data != nil {
+			// not a bucket
+			return nil
+		}
+		return fn(string(key))
+	})
+}
+
+// Cursor returns a cursor on the keys of the bucket, in byte order
+func (b *BoltBucket) Cursor() Cursor {
+	return newBoltCursor(b.bucket.Cursor())
+}
+
+func (b *BoltBucket) ForEach(fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEach(b.Cursor(), fn)
+}
+
+func (b *BoltBucket) ForEachPrefix(prefix string, fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEachPrefix(b.Cursor(), prefix, fn)
+}
+
+func (b *BoltBucket) ForEachRange(from, to string, fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEachRange(b.Cursor(), from, to, fn)
 }
 
 // Test the interface
@@ -0,0 +1,57 @@
+package store
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltCursor is a cursor on a bolt bucket. Nested buckets are skipped.
+type BoltCursor struct {
+	cursor *bolt.Cursor
+}
+
+func newBoltCursor(cursor *bolt.Cursor) *BoltCursor {
+	return &BoltCursor{
+		cursor: cursor,
+	}
+}
+
+func (c *BoltCursor) First() (string, []byte) {
+	return c.forward(c.cursor.First())
+}
+
+func (c *BoltCursor) Last() (string, []byte) {
+	return c.backward(c.cursor.Last())
+}
+
+func (c *BoltCursor) Next() (string, []byte) {
+	return c.forward(c.cursor.Next())
+}
+
+func (c *BoltCursor) Prev() (string, []byte) {
+	return c.backward(c.cursor.Prev())
+}
+
+func (c *BoltCursor) Seek(key string) (string, []byte) {
+	return c.forward(c.cursor.Seek([]byte(key)))
+}
+
+// forward skips nested buckets (which have a nil value) moving towards the end
+func (c *BoltCursor) forward(key, data []byte) (string, []byte) {
+	for key != nil && data == nil {
+		key, data = c.cursor.Next()
+	}
+	return string(key), data
+}
+
+// backward skips nested buckets (which have a nil value) moving towards the beginning
+func (c *BoltCursor) backward(key, data []byte) (string, []byte) {
+	for key != nil && data == nil {
+		key, data = c.cursor.Prev()
+	}
+	return string(key), data
+}
+
+// Test the interface
+var (
+	_ Cursor = &BoltCursor{}
+)
@@ -1,30 +1,71 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
+// DefaultLockTimeout is the time to wait for another process to release the database file
+const DefaultLockTimeout = 10 * time.Second
+
+// BoltOptions are the options used to open a bolt database file
+type BoltOptions struct {
+	// ReadOnly opens the file in read-only mode: many processes can read the same file at the same time,
+	// and the file can be saved on a read-only medium
+	ReadOnly bool
+	// LockTimeout is the time to wait for the file lock: zero waits forever
+	LockTimeout time.Duration
+}
+
+// DefaultBoltOptions returns the options to open the database file for writing
+func DefaultBoltOptions() BoltOptions {
+	return BoltOptions{
+		LockTimeout: DefaultLockTimeout,
+	}
+}
+
 type BoltStore struct {
 	db *bolt.DB
 }
 
+// NewBoltStore opens the database file for writing, with the default options
 func NewBoltStore(database string) (*BoltStore, error) {
-	db, err := bolt.Open(database, 0600, nil)
+	return NewBoltStoreWithOptions(database, DefaultBoltOptions())
+}
+
+// NewBoltStoreWithOptions opens the database file. It returns ErrDatabaseLocked if the file is still
+// locked by another process after the timeout.
+func NewBoltStoreWithOptions(database string, options BoltOptions) (*BoltStore, error) {
+	db, err := bolt.Open(database, 0600, &bolt.Options{
+		ReadOnly: options.ReadOnly,
+		Timeout:  options.LockTimeout,
+	})
+	if errors.Is(err, bolt.ErrTimeout) {
+		err = ErrDatabaseLocked
+	}
 	if err != nil {
-		return nil, fmt.Errorf("Cannot open database file '%s'", database)
+		return nil, fmt.Errorf("Cannot open database file '%s': %w", database, err)
 	}
 	return &BoltStore{
 		db: db,
 	}, nil
 }
 
+// IsReadOnly returns true when the database file was opened in read-only mode
+func (s *BoltStore) IsReadOnly() bool {
+	return s.db.IsReadOnly()
+}
+
 // Begin a transaction
 func (s *BoltStore) Begin(writable bool) (Transaction, error) {
 	tx, err := s.db.Begin(writable)
 	if err != nil {
-		return nil, err
+		return nil, convertBoltError(err)
 	}
 	return newBoltTransaction(tx), nil
 }
@@ -36,10 +77,11 @@ func (s *BoltStore) Close() {
 
 // Update run the job in a transaction
 func (s *BoltStore) Update(job func(transaction Transaction) error) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		t := newBoltTransaction(tx)
 		return job(t)
 	})
+	return convertBoltError(err)
 }
 
 // View run the job in a read-only transaction
@@ -50,6 +92,43 @@ func (s *BoltStore) View(job func(transaction Transaction) error) error {
 	})
 }
 
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit.
+// Bolt cannot stop waiting for the write lock: the context is checked once the transaction has started.
+func (s *BoltStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Update(jobWithContext(ctx, job))
+}
+
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *BoltStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.View(jobWithContext(ctx, job))
+}
+
+// Batch runs the job in a write transaction shared with other concurrent calls
+func (s *BoltStore) Batch(job func(transaction Transaction) error) error {
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		t := newBoltTransaction(tx)
+		return job(t)
+	})
+	return convertBoltError(err)
+}
+
+// WriteTo writes a copy of the database file, from a read-only transaction
+func (s *BoltStore) WriteTo(w io.Writer) (int64, error) {
+	var size int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		size, err = tx.WriteTo(w)
+		return err
+	})
+	return size, err
+}
+
 // Test the interface
 var (
 	_ Store = &BoltStore{}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -37,12 +39,12 @@ func (t *BoltTransaction) CreateBucket(bucket string) (Bucket, error) {
 
 	b, err := t.tx.CreateBucket([]byte(bucket))
 	if err != nil {
-		return nil, err
+		return nil, convertBoltError(err)
 	}
 	return newBoltBucket(b), nil
 }
 
-// GetBucket returns a bucket from its name. If it does not exists, a new empty bucket will be returned.
+// GetBucket returns a bucket from its name. Returns ErrBucketNotFound if it does not exist.
 func (t *BoltTransaction) GetBucket(bucket string) (Bucket, error) {
 	if bucket == "" {
 		return nil, ErrBucketNoName
@@ -64,7 +66,36 @@ func (t *BoltTransaction) DeleteBucket(bucket string) error {
 		return ErrBucketNoName
 	}
 
-	return t.tx.DeleteBucket([]byte(bucket))
+	return convertBoltError(t.tx.DeleteBucket([]byte(bucket)))
+}
+
+// ForEachBucket runs the function on the name of every top level bucket
+func (t *BoltTransaction) ForEachBucket(fn func(name string) error) error {
+	return t.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		return fn(string(name))
+	})
+}
+
+// convertBoltError returns the store error equivalent to the bolt error
+func convertBoltError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bolt.ErrBucketExists):
+		return ErrBucketNameExists
+	case errors.Is(err, bolt.ErrBucketNotFound):
+		return ErrBucketNotFound
+	case errors.Is(err, bolt.ErrBucketNameRequired):
+		return ErrBucketNoName
+	case errors.Is(err, bolt.ErrIncompatibleValue):
+		return ErrIncompatibleValue
+	case errors.Is(err, bolt.ErrTxNotWritable):
+		return ErrTransactionReadonly
+	case errors.Is(err, bolt.ErrDatabaseReadOnly):
+		return ErrDatabaseReadOnly
+	default:
+		return err
+	}
 }
 
 var (
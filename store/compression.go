@@ -0,0 +1,240 @@
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// BucketCompression is the bucket holding the compression header
+	BucketCompression = "catalogue-compression"
+	// DefaultCompressionThreshold is the minimum size of a value to try to compress it
+	DefaultCompressionThreshold = 64
+)
+
+// marker bytes saved in front of each value
+const (
+	markerRaw     byte = 0
+	markerDeflate byte = 1
+)
+
+var (
+	ErrNotCompressed           = errors.New("The store is not compressed")
+	ErrAlreadyCompressed       = errors.New("The store is already compressed")
+	ErrUnknownCompression      = errors.New("Unknown compression marker")
+	ErrInvalidCompressionLevel = errors.New("Invalid compression level")
+)
+
+// CompressionOptions are the parameters used when creating a new compressed store
+type CompressionOptions struct {
+	// Level of deflate compression, from flate.HuffmanOnly to flate.BestCompression
+	Level int
+	// Threshold is the minimum size of a value to try to compress it: smaller values are saved as they are
+	Threshold int
+}
+
+// DefaultCompressionOptions returns the default compression parameters
+func DefaultCompressionOptions() CompressionOptions {
+	return CompressionOptions{
+		Level:     flate.DefaultCompression,
+		Threshold: DefaultCompressionThreshold,
+	}
+}
+
+// compressionHeader is saved in the BucketCompression bucket
+type compressionHeader struct {
+	Algorithm string
+	Level     int
+	Threshold int
+}
+
+// InitCompression saves a new compression header into the store, and returns a compressed store.
+// The store should be empty: existing values don't have a marker byte and would not be readable anymore.
+func InitCompression(store Store, options CompressionOptions) (*TransformStore, error) {
+	if options.Level < flate.HuffmanOnly || options.Level > flate.BestCompression {
+		return nil, ErrInvalidCompressionLevel
+	}
+	if options.Threshold < 0 {
+		options.Threshold = 0
+	}
+	header := compressionHeader{
+		Algorithm: "deflate",
+		Level:     options.Level,
+		Threshold: options.Threshold,
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.Update(func(transaction Transaction) error {
+		bucket, err := transaction.CreateBucket(BucketCompression)
+		if errors.Is(err, ErrBucketNameExists) {
+			return ErrAlreadyCompressed
+		}
+		if err != nil {
+			return err
+		}
+		return bucket.Put(keyHeader, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTransformStore(store, newCompressor(header), BucketCompression), nil
+}
+
+// OpenCompression reads the compression header from the store and returns a compressed store
+func OpenCompression(store Store) (*TransformStore, error) {
+	header, err := readCompressionHeader(store)
+	if err != nil {
+		return nil, err
+	}
+	if header.Algorithm != "deflate" {
+		return nil, fmt.Errorf("unsupported compression algorithm %q", header.Algorithm)
+	}
+	return newTransformStore(store, newCompressor(header), BucketCompression), nil
+}
+
+// GetCompressionOptions returns the parameters used to create the compressed store
+func GetCompressionOptions(store Store) (CompressionOptions, error) {
+	header, err := readCompressionHeader(store)
+	if err != nil {
+		return CompressionOptions{}, err
+	}
+	return CompressionOptions{
+		Level:     header.Level,
+		Threshold: header.Threshold,
+	}, nil
+}
+
+// readCompressionHeader loads the compression header from the store
+func readCompressionHeader(store Store) (compressionHeader, error) {
+	header := compressionHeader{}
+	err := store.View(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket(BucketCompression)
+		if errors.Is(err, ErrBucketNotFound) {
+			return ErrNotCompressed
+		}
+		if err != nil {
+			return err
+		}
+		data, err := bucket.Get(keyHeader)
+		if err != nil {
+			return fmt.Errorf("cannot read compression header: %w", err)
+		}
+		return json.Unmarshal(data, &header)
+	})
+	return header, err
+}
+
+// IsCompressed returns true when the store contains a compression header
+func IsCompressed(store Store) (bool, error) {
+	compressed := false
+	err := store.View(func(transaction Transaction) error {
+		_, err := transaction.GetBucket(BucketCompression)
+		if errors.Is(err, ErrBucketNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		compressed = true
+		return nil
+	})
+	return compressed, err
+}
+
+// compressor is a Transformer compressing the values with deflate.
+// Each value starts with a marker byte telling if the rest is compressed or not:
+// a value is saved as it is when it's below the threshold or when compressing doesn't make it smaller.
+type compressor struct {
+	threshold int
+	writers   *sync.Pool
+	readers   *sync.Pool
+}
+
+func newCompressor(header compressionHeader) *compressor {
+	return &compressor{
+		threshold: header.Threshold,
+		writers: &sync.Pool{
+			New: func() any {
+				// the level has already been checked
+				writer, _ := flate.NewWriter(nil, header.Level)
+				return writer
+			},
+		},
+		readers: &sync.Pool{
+			New: func() any {
+				return flate.NewReader(nil)
+			},
+		},
+	}
+}
+
+// Key is not transformed
+func (c *compressor) Key(key string) string {
+	return key
+}
+
+func (c *compressor) Encode(_ []string, key string, data []byte) ([]byte, error) {
+	if len(data) >= c.threshold && len(data) > 0 {
+		buffer := bytes.NewBuffer(make([]byte, 0, len(data)/2+1))
+		buffer.WriteByte(markerDeflate)
+		writer := c.writers.Get().(*flate.Writer)
+		writer.Reset(buffer)
+		_, err := writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+		c.writers.Put(writer)
+		if err != nil {
+			return nil, err
+		}
+		if buffer.Len() < len(data)+1 {
+			return buffer.Bytes(), nil
+		}
+	}
+	stored := make([]byte, len(data)+1)
+	stored[0] = markerRaw
+	copy(stored[1:], data)
+	return stored, nil
+}
+
+func (c *compressor) Decode(_ []string, storedKey string, stored []byte) (string, []byte, error) {
+	if len(stored) == 0 {
+		return storedKey, nil, ErrUnknownCompression
+	}
+	switch stored[0] {
+	case markerRaw:
+		return storedKey, stored[1:], nil
+
+	case markerDeflate:
+		reader := c.readers.Get().(io.ReadCloser)
+		defer c.readers.Put(reader)
+		if err := reader.(flate.Resetter).Reset(bytes.NewReader(stored[1:]), nil); err != nil {
+			return storedKey, nil, err
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return storedKey, nil, err
+		}
+		return storedKey, data, nil
+
+	default:
+		return storedKey, nil, ErrUnknownCompression
+	}
+}
+
+// Ordered is true since the keys are not transformed
+func (c *compressor) Ordered() bool {
+	return true
+}
+
+var (
+	_ Transformer = &compressor{}
+)
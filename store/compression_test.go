@@ -0,0 +1,294 @@
+package store_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compressibleValue looks like a catalogue record full of paths
+var compressibleValue = []byte(strings.Repeat(`{"path":"Photos/2015/Wedding/RAW/IMG_0001.CR2","size":25165824},`, 10))
+
+func newCompressedStore(t *testing.T) (*store.TransformStore, *store.MemoryStore) {
+	t.Helper()
+
+	memory := store.NewMemoryStore()
+	compressed, err := store.InitCompression(memory, store.DefaultCompressionOptions())
+	require.NoError(t, err)
+	return compressed, memory
+}
+
+func TestCompressedMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		compressed, _ := newCompressedStore(t)
+		return compressed
+	})
+}
+
+func TestCompressedBoltStore(t *testing.T) {
+	t.Parallel()
+
+	testPath := os.Getenv("DB_TEST_PATH")
+	if testPath == "" {
+		t.Skip("DB_TEST_PATH is not set")
+	}
+	dir, err := os.MkdirTemp(testPath, "compression_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	var counter atomic.Int32
+	storetest.Run(t, func() store.Store {
+		database := filepath.Join(dir, fmt.Sprintf("compression_test_%d.db", counter.Add(1)))
+		boltStore, err := store.NewBoltStore(database)
+		require.NoError(t, err)
+		compressed, err := store.InitCompression(boltStore, store.DefaultCompressionOptions())
+		require.NoError(t, err)
+		return compressed
+	})
+}
+
+func TestCompressedEncryptedStore(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		encrypted, _ := newEncryptedStore(t, false)
+		compressed, err := store.InitCompression(encrypted, store.DefaultCompressionOptions())
+		require.NoError(t, err)
+		return compressed
+	})
+}
+
+func TestCompressedValues(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		key        string
+		value      []byte
+		compressed bool
+	}{
+		{"empty", []byte{}, false},
+		{"small", []byte("small value"), false},
+		{"compressible", compressibleValue, true},
+		{"random", []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ+/"), false},
+	}
+
+	compressed, memory := newCompressedStore(t)
+	err := compressed.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		for _, testItem := range testData {
+			if err := bucket.Put(testItem.key, testItem.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = memory.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		for _, testItem := range testData {
+			stored, err := bucket.Get(testItem.key)
+			require.NoError(t, err)
+			if testItem.compressed {
+				assert.Equal(t, byte(1), stored[0])
+				assert.Less(t, len(stored), len(testItem.value))
+			} else {
+				assert.Equal(t, byte(0), stored[0])
+				assert.Equal(t, testItem.value, stored[1:])
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = compressed.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		for _, testItem := range testData {
+			value, err := bucket.Get(testItem.key)
+			require.NoError(t, err)
+			assert.Equal(t, testItem.value, value)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestValueWithoutCompressionMarker(t *testing.T) {
+	t.Parallel()
+
+	compressed, memory := newCompressedStore(t)
+	err := memory.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte{42, 1, 2, 3})
+	})
+	require.NoError(t, err)
+
+	err = compressed.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		_, err = bucket.Get("key")
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrUnknownCompression)
+}
+
+func TestOpenCompression(t *testing.T) {
+	t.Parallel()
+
+	compressed, memory := newCompressedStore(t)
+	err := compressed.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", compressibleValue)
+	})
+	require.NoError(t, err)
+
+	isCompressed, err := store.IsCompressed(memory)
+	require.NoError(t, err)
+	assert.True(t, isCompressed)
+
+	_, err = store.InitCompression(memory, store.DefaultCompressionOptions())
+	assert.ErrorIs(t, err, store.ErrAlreadyCompressed)
+
+	options, err := store.GetCompressionOptions(memory)
+	require.NoError(t, err)
+	assert.Equal(t, store.DefaultCompressionOptions(), options)
+
+	reopened, err := store.OpenCompression(memory)
+	require.NoError(t, err)
+	err = reopened.View(func(transaction store.Transaction) error {
+		_, err := transaction.GetBucket(store.BucketCompression)
+		assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		value, err := bucket.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, compressibleValue, value)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenCompressionOnPlainStore(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	isCompressed, err := store.IsCompressed(memory)
+	require.NoError(t, err)
+	assert.False(t, isCompressed)
+
+	_, err = store.OpenCompression(memory)
+	assert.ErrorIs(t, err, store.ErrNotCompressed)
+
+	_, err = store.GetCompressionOptions(memory)
+	assert.ErrorIs(t, err, store.ErrNotCompressed)
+
+	_, err = store.InitCompression(memory, store.CompressionOptions{Level: 10})
+	assert.ErrorIs(t, err, store.ErrInvalidCompressionLevel)
+}
+
+func BenchmarkCompressedWrites(b *testing.B) {
+	backends := []struct {
+		name  string
+		store func(b *testing.B) store.Store
+	}{
+		{"InMemory", func(b *testing.B) store.Store {
+			return store.NewMemoryStore()
+		}},
+		{"BoltDB", func(b *testing.B) store.Store {
+			boltStore, err := store.NewBoltStore(filepath.Join(b.TempDir(), "bench.db"))
+			require.NoError(b, err)
+			return boltStore
+		}},
+	}
+	wrappers := []struct {
+		name string
+		wrap func(b *testing.B, s store.Store) store.Store
+	}{
+		{"Uncompressed", func(b *testing.B, s store.Store) store.Store {
+			return s
+		}},
+		{"Compressed", func(b *testing.B, s store.Store) store.Store {
+			compressed, err := store.InitCompression(s, store.DefaultCompressionOptions())
+			require.NoError(b, err)
+			return compressed
+		}},
+	}
+	for _, backend := range backends {
+		for _, wrapper := range wrappers {
+			b.Run(backend.name+"/"+wrapper.name, func(b *testing.B) {
+				base := backend.store(b)
+				s := wrapper.wrap(b, base)
+				defer s.Close()
+
+				err := s.Update(func(transaction store.Transaction) error {
+					_, err := transaction.CreateBucket("bucket")
+					return err
+				})
+				require.NoError(b, err)
+
+				writer := store.NewBatchWriter(s, store.BatchOptions{}, func(transaction store.Transaction, keys []string) error {
+					bucket, err := transaction.GetBucket("bucket")
+					if err != nil {
+						return err
+					}
+					for _, key := range keys {
+						if err := bucket.Put(key, compressibleValue); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					err := writer.Add(fmt.Sprintf("key-%012d", i))
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := writer.Close(); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "keys/s")
+
+				// size of the values saved in the underlying store
+				stored := 0
+				err = base.View(func(transaction store.Transaction) error {
+					bucket, err := transaction.GetBucket("bucket")
+					if err != nil {
+						return err
+					}
+					return bucket.ForEach(func(key string, data []byte) error {
+						stored += len(data)
+						return nil
+					})
+				})
+				require.NoError(b, err)
+				b.ReportMetric(float64(stored)/float64(b.N), "stored-bytes/key")
+			})
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package store
+
+import "context"
+
+// jobWithContext returns a job failing with the context error when the context is done before or after the job runs,
+// so the transaction is rolled back instead of committed.
+// The job itself can watch the context to stop early.
+func jobWithContext(ctx context.Context, job func(transaction Transaction) error) func(transaction Transaction) error {
+	return func(transaction Transaction) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := job(transaction); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+}
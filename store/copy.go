@@ -0,0 +1,138 @@
+package store
+
+// DefaultCopyBatchSize is the number of keys saved in each write transaction during a copy
+const DefaultCopyBatchSize = 10000
+
+// Copy copies all the buckets, nested buckets, sequences and keys from the source store into the destination store.
+// The source is read in a single transaction so the copy is consistent.
+// The keys are written in order, in transactions of at most batchSize keys.
+// The buckets must not exist in the destination store.
+func Copy(dst, src Store, batchSize int) error {
+	if batchSize < 1 {
+		batchSize = DefaultCopyBatchSize
+	}
+	return src.View(func(transaction Transaction) error {
+		return transaction.ForEachBucket(func(name string) error {
+			bucket, err := transaction.GetBucket(name)
+			if err != nil {
+				return err
+			}
+			return copyBucket(dst, []string{name}, bucket, batchSize)
+		})
+	})
+}
+
+// copyBucket creates the bucket at the path in the destination store, and copies the content of the source bucket into it
+func copyBucket(dst Store, path []string, src Bucket, batchSize int) error {
+	err := dst.Update(func(transaction Transaction) error {
+		bucket, err := bucketAtPath(transaction, path, true)
+		if err != nil {
+			return err
+		}
+		return bucket.SetSequence(src.Sequence())
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, batchSize)
+	values := make([][]byte, 0, batchSize)
+	flush := func() error {
+		err := dst.Update(func(transaction Transaction) error {
+			bucket, err := bucketAtPath(transaction, path, false)
+			if err != nil {
+				return err
+			}
+			for i, key := range keys {
+				if err := bucket.Put(key, values[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		keys = keys[:0]
+		values = values[:0]
+		return err
+	}
+
+	err = src.ForEach(func(key string, data []byte) error {
+		keys = append(keys, key)
+		values = append(values, data)
+		if len(keys) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	return src.ForEachBucket(func(name string) error {
+		nested, err := src.GetBucket(name)
+		if err != nil {
+			return err
+		}
+		return copyBucket(dst, childPath(path, name), nested, batchSize)
+	})
+}
+
+// Records is the number of buckets and keys of a store, the nested buckets included
+type Records struct {
+	Buckets uint64
+	Keys    uint64
+}
+
+// CountRecords walks all the buckets of the store in a single read transaction, and counts the buckets and the keys.
+// It's used to check a copy: the sequences are not compared.
+func CountRecords(store Store) (Records, error) {
+	records := Records{}
+	var walk func(bucket Bucket) error
+	walk = func(bucket Bucket) error {
+		records.Buckets++
+		err := bucket.ForEach(func(key string, data []byte) error {
+			records.Keys++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return bucket.ForEachBucket(func(name string) error {
+			nested, err := bucket.GetBucket(name)
+			if err != nil {
+				return err
+			}
+			return walk(nested)
+		})
+	}
+	err := store.View(func(transaction Transaction) error {
+		return transaction.ForEachBucket(func(name string) error {
+			bucket, err := transaction.GetBucket(name)
+			if err != nil {
+				return err
+			}
+			return walk(bucket)
+		})
+	})
+	return records, err
+}
+
+// bucketAtPath returns the bucket at the path. When create is true, the last bucket of the path is created.
+func bucketAtPath(transaction Transaction, path []string, create bool) (Bucket, error) {
+	var parent Bucketeer = transaction
+	for i, name := range path {
+		if create && i == len(path)-1 {
+			return parent.CreateBucket(name)
+		}
+		bucket, err := parent.GetBucket(name)
+		if err != nil {
+			return nil, err
+		}
+		parent = bucket
+	}
+	return parent.(Bucket), nil
+}
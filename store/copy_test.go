@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+
+	src := store.NewMemoryStore()
+	err := src.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{"c", "a", "b", "e", "d"} {
+			if err := bucket.Put(key, []byte("value-"+key)); err != nil {
+				return err
+			}
+		}
+		if err := bucket.SetSequence(12); err != nil {
+			return err
+		}
+		nested, err := bucket.CreateBucket("nested")
+		if err != nil {
+			return err
+		}
+		if err := nested.Put("key", []byte("nested-value")); err != nil {
+			return err
+		}
+		_, err = transaction.CreateBucket("empty")
+		return err
+	})
+	require.NoError(t, err)
+
+	dst, _ := newCompressedStore(t)
+	err = store.Copy(dst, src, 2)
+	require.NoError(t, err)
+
+	records, err := store.CountRecords(src)
+	require.NoError(t, err)
+	assert.Equal(t, store.Records{Buckets: 3, Keys: 6}, records)
+	copied, err := store.CountRecords(dst)
+	require.NoError(t, err)
+	assert.Equal(t, records, copied)
+
+	err = dst.View(func(transaction store.Transaction) error {
+		names := make([]string, 0, 2)
+		err := transaction.ForEachBucket(func(name string) error {
+			names = append(names, name)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bucket", "empty"}, names)
+
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		keys := make([]string, 0, 5)
+		err = bucket.ForEach(func(key string, data []byte) error {
+			keys = append(keys, key)
+			assert.Equal(t, []byte("value-"+key), data)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, keys)
+		assert.Equal(t, uint64(12), bucket.Sequence())
+
+		nested, err := bucket.GetBucket("nested")
+		require.NoError(t, err)
+		value, err := nested.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("nested-value"), value)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCopyIntoExistingBucket(t *testing.T) {
+	t.Parallel()
+
+	src := store.NewMemoryStore()
+	dst := store.NewMemoryStore()
+	for _, s := range []store.Store{src, dst} {
+		err := s.Update(func(transaction store.Transaction) error {
+			_, err := transaction.CreateBucket("bucket")
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	err := store.Copy(dst, src, 0)
+	assert.ErrorIs(t, err, store.ErrBucketNameExists)
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// BucketEncryption is the bucket holding the encryption header. It is not encrypted.
+	BucketEncryption = "catalogue-encryption"
+	keyHeader        = "header"
+	// verificationText is encrypted in the header to check the passphrase
+	verificationText = "catalogue-encryption-verification"
+	saltSize         = 16
+)
+
+var (
+	ErrNotEncrypted       = errors.New("The store is not encrypted")
+	ErrAlreadyEncrypted   = errors.New("The store is already encrypted")
+	ErrWrongPassphrase    = errors.New("Wrong passphrase")
+	ErrEmptyPassphrase    = errors.New("Cannot use a blank passphrase")
+	ErrCannotDecryptValue = errors.New("Cannot decrypt value")
+)
+
+// EncryptionOptions are the parameters used when creating a new encrypted store
+type EncryptionOptions struct {
+	// HashKeys saves a keyed hash of the keys instead of the keys themselves.
+	// The original key is encrypted with the value.
+	// Cursors and scans on a bucket need to load and sort the whole bucket in memory.
+	HashKeys bool
+	// Argon2id parameters used to derive the key from the passphrase: zero values take the defaults
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+}
+
+// DefaultEncryptionOptions returns the recommended parameters for argon2id
+func DefaultEncryptionOptions() EncryptionOptions {
+	return EncryptionOptions{
+		Time:    1,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+}
+
+// encryptionHeader is saved in the BucketEncryption bucket
+type encryptionHeader struct {
+	KDF          string
+	Salt         []byte
+	Time         uint32
+	Memory       uint32
+	Threads      uint8
+	HashKeys     bool
+	Verification []byte
+}
+
+// InitEncryption saves a new encryption header into the store, and returns an encrypted store.
+// The store should be empty: existing values would not be readable anymore.
+func InitEncryption(store Store, passphrase []byte, options EncryptionOptions) (*TransformStore, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	defaults := DefaultEncryptionOptions()
+	if options.Time == 0 {
+		options.Time = defaults.Time
+	}
+	if options.Memory == 0 {
+		options.Memory = defaults.Memory
+	}
+	if options.Threads == 0 {
+		options.Threads = defaults.Threads
+	}
+	header := encryptionHeader{
+		KDF:      "argon2id",
+		Salt:     make([]byte, saltSize),
+		Time:     options.Time,
+		Memory:   options.Memory,
+		Threads:  options.Threads,
+		HashKeys: options.HashKeys,
+	}
+	if _, err := io.ReadFull(rand.Reader, header.Salt); err != nil {
+		return nil, err
+	}
+	encryptor, err := newEncryptor(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	header.Verification, err = encryptor.Encode(nil, verificationText, []byte(verificationText))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.Update(func(transaction Transaction) error {
+		bucket, err := transaction.CreateBucket(BucketEncryption)
+		if errors.Is(err, ErrBucketNameExists) {
+			return ErrAlreadyEncrypted
+		}
+		if err != nil {
+			return err
+		}
+		return bucket.Put(keyHeader, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTransformStore(store, encryptor, BucketEncryption), nil
+}
+
+// OpenEncryption reads the encryption header from the store, checks the passphrase and returns an encrypted store
+func OpenEncryption(store Store, passphrase []byte) (*TransformStore, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	header, err := readEncryptionHeader(store)
+	if err != nil {
+		return nil, err
+	}
+	encryptor, err := newEncryptor(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	_, verification, err := encryptor.Decode(nil, encryptor.Key(verificationText), header.Verification)
+	if err != nil || !bytes.Equal(verification, []byte(verificationText)) {
+		return nil, ErrWrongPassphrase
+	}
+	return newTransformStore(store, encryptor, BucketEncryption), nil
+}
+
+// GetEncryptionOptions returns the parameters used to create the encrypted store
+func GetEncryptionOptions(store Store) (EncryptionOptions, error) {
+	header, err := readEncryptionHeader(store)
+	if err != nil {
+		return EncryptionOptions{}, err
+	}
+	return EncryptionOptions{
+		HashKeys: header.HashKeys,
+		Time:     header.Time,
+		Memory:   header.Memory,
+		Threads:  header.Threads,
+	}, nil
+}
+
+// readEncryptionHeader loads the encryption header from the store
+func readEncryptionHeader(store Store) (encryptionHeader, error) {
+	header := encryptionHeader{}
+	err := store.View(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket(BucketEncryption)
+		if errors.Is(err, ErrBucketNotFound) {
+			return ErrNotEncrypted
+		}
+		if err != nil {
+			return err
+		}
+		data, err := bucket.Get(keyHeader)
+		if err != nil {
+			return fmt.Errorf("cannot read encryption header: %w", err)
+		}
+		return json.Unmarshal(data, &header)
+	})
+	if err != nil {
+		return header, err
+	}
+	if header.KDF != "argon2id" {
+		return header, fmt.Errorf("unsupported key derivation function %q", header.KDF)
+	}
+	return header, nil
+}
+
+// IsEncrypted returns true when the store contains an encryption header
+func IsEncrypted(store Store) (bool, error) {
+	encrypted := false
+	err := store.View(func(transaction Transaction) error {
+		_, err := transaction.GetBucket(BucketEncryption)
+		if errors.Is(err, ErrBucketNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		encrypted = true
+		return nil
+	})
+	return encrypted, err
+}
+
+// encryptor is a Transformer encrypting the values with AES-256-GCM.
+// The path of the bucket and the key used to save the value are authenticated with it,
+// so values cannot be swapped between keys or moved to another bucket.
+type encryptor struct {
+	aead     cipher.AEAD
+	hashKey  []byte
+	hashKeys bool
+}
+
+func newEncryptor(passphrase []byte, header encryptionHeader) (*encryptor, error) {
+	// first half is the encryption key, second half is the key to hash the keys
+	derived := argon2.IDKey(passphrase, header.Salt, header.Time, header.Memory, header.Threads, 64)
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptor{
+		aead:     aead,
+		hashKey:  derived[32:],
+		hashKeys: header.HashKeys,
+	}, nil
+}
+
+// Key returns the keyed hash of the key when hashing is enabled
+func (e *encryptor) Key(key string) string {
+	if !e.hashKeys || key == "" {
+		return key
+	}
+	mac := hmac.New(sha256.New, e.hashKey)
+	mac.Write([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Encode returns nonce + encrypted data. When the keys are hashed, the original key is encrypted with the data.
+func (e *encryptor) Encode(path []string, key string, data []byte) ([]byte, error) {
+	nonceSize := e.aead.NonceSize()
+	plain := data
+	if e.hashKeys {
+		plain = make([]byte, 0, binary.MaxVarintLen64+len(key)+len(data))
+		plain = binary.AppendUvarint(plain, uint64(len(key)))
+		plain = append(plain, key...)
+		plain = append(plain, data...)
+	}
+	output := make([]byte, nonceSize, nonceSize+len(plain)+e.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, output); err != nil {
+		return nil, err
+	}
+	return e.aead.Seal(output, output, plain, e.additionalData(path, e.Key(key))), nil
+}
+
+func (e *encryptor) Decode(path []string, storedKey string, stored []byte) (string, []byte, error) {
+	nonceSize := e.aead.NonceSize()
+	if len(stored) < nonceSize {
+		return "", nil, ErrCannotDecryptValue
+	}
+	plain, err := e.aead.Open(nil, stored[:nonceSize], stored[nonceSize:], e.additionalData(path, storedKey))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrCannotDecryptValue, err)
+	}
+	if !e.hashKeys {
+		return storedKey, plain, nil
+	}
+	size, read := binary.Uvarint(plain)
+	if read <= 0 || uint64(len(plain)-read) < size {
+		return "", nil, ErrCannotDecryptValue
+	}
+	return string(plain[read : read+int(size)]), plain[read+int(size):], nil
+}
+
+// additionalData returns the data authenticated with the value: the length and the name of each bucket of the path,
+// followed by the key saved in the underlying store
+func (e *encryptor) additionalData(path []string, storedKey string) []byte {
+	size := len(storedKey)
+	for _, name := range path {
+		size += binary.MaxVarintLen64 + len(name)
+	}
+	data := make([]byte, 0, size)
+	for _, name := range path {
+		data = binary.AppendUvarint(data, uint64(len(name)))
+		data = append(data, name...)
+	}
+	return append(data, storedKey...)
+}
+
+// Ordered is false when the keys are hashed
+func (e *encryptor) Ordered() bool {
+	return !e.hashKeys
+}
+
+var (
+	_ Transformer = &encryptor{}
+)
@@ -0,0 +1,229 @@
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testPassphrase = []byte("correct horse battery staple")
+	// lightweight parameters to keep the tests fast
+	testEncryptionOptions = store.EncryptionOptions{Time: 1, Memory: 64, Threads: 1}
+)
+
+func newEncryptedStore(t *testing.T, hashKeys bool) (*store.TransformStore, *store.MemoryStore) {
+	t.Helper()
+
+	memory := store.NewMemoryStore()
+	options := testEncryptionOptions
+	options.HashKeys = hashKeys
+	encrypted, err := store.InitEncryption(memory, testPassphrase, options)
+	require.NoError(t, err)
+	return encrypted, memory
+}
+
+func TestEncryptedStore(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		encrypted, _ := newEncryptedStore(t, false)
+		return encrypted
+	})
+}
+
+func TestEncryptedStoreWithHashedKeys(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		encrypted, _ := newEncryptedStore(t, true)
+		return encrypted
+	})
+}
+
+func TestEncryptedValuesAreNotReadable(t *testing.T) {
+	t.Parallel()
+
+	for _, hashKeys := range []bool{false, true} {
+		encrypted, memory := newEncryptedStore(t, hashKeys)
+		err := encrypted.Update(func(transaction store.Transaction) error {
+			bucket, err := transaction.CreateBucket("bucket")
+			if err != nil {
+				return err
+			}
+			return bucket.Put("medical-scans", []byte("private content"))
+		})
+		require.NoError(t, err)
+
+		err = memory.View(func(transaction store.Transaction) error {
+			bucket, err := transaction.GetBucket("bucket")
+			require.NoError(t, err)
+			return bucket.ForEach(func(key string, data []byte) error {
+				assert.Equal(t, !hashKeys, key == "medical-scans")
+				assert.False(t, bytes.Contains(data, []byte("private content")))
+				assert.False(t, bytes.Contains(data, []byte("medical-scans")))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestCannotSwapEncryptedValues(t *testing.T) {
+	t.Parallel()
+
+	encrypted, memory := newEncryptedStore(t, false)
+	err := encrypted.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key1", []byte("value1"))
+	})
+	require.NoError(t, err)
+
+	// copy the encrypted value to another key
+	err = memory.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		data, err := bucket.Get("key1")
+		require.NoError(t, err)
+		return bucket.Put("key2", data)
+	})
+	require.NoError(t, err)
+
+	err = encrypted.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		_, err = bucket.Get("key2")
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrCannotDecryptValue)
+}
+
+func TestCannotMoveEncryptedValuesToAnotherBucket(t *testing.T) {
+	t.Parallel()
+
+	for _, hashKeys := range []bool{false, true} {
+		encrypted, memory := newEncryptedStore(t, hashKeys)
+		err := encrypted.Update(func(transaction store.Transaction) error {
+			for _, name := range []string{"bucket1", "bucket2"} {
+				bucket, err := transaction.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				nested, err := bucket.CreateBucket("nested")
+				if err != nil {
+					return err
+				}
+				if err = nested.Put("key", []byte(name)); err != nil {
+					return err
+				}
+				if err = bucket.Put("key", []byte(name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		// copy the encrypted values under the same key in the other bucket, and in the nested bucket
+		err = memory.Update(func(transaction store.Transaction) error {
+			bucket1, err := transaction.GetBucket("bucket1")
+			require.NoError(t, err)
+			bucket2, err := transaction.GetBucket("bucket2")
+			require.NoError(t, err)
+			nested2, err := bucket2.GetBucket("nested")
+			require.NoError(t, err)
+			return bucket1.ForEach(func(key string, data []byte) error {
+				if data == nil {
+					// nested bucket
+					return nil
+				}
+				return errors.Join(bucket2.Put(key, data), nested2.Put(key, data))
+			})
+		})
+		require.NoError(t, err)
+
+		err = encrypted.View(func(transaction store.Transaction) error {
+			bucket1, err := transaction.GetBucket("bucket1")
+			require.NoError(t, err)
+			value, err := bucket1.Get("key")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("bucket1"), value)
+
+			bucket2, err := transaction.GetBucket("bucket2")
+			require.NoError(t, err)
+			_, err = bucket2.Get("key")
+			assert.ErrorIs(t, err, store.ErrCannotDecryptValue)
+
+			nested2, err := bucket2.GetBucket("nested")
+			require.NoError(t, err)
+			_, err = nested2.Get("key")
+			assert.ErrorIs(t, err, store.ErrCannotDecryptValue)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestOpenEncryption(t *testing.T) {
+	t.Parallel()
+
+	encrypted, memory := newEncryptedStore(t, true)
+	err := encrypted.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	isEncrypted, err := store.IsEncrypted(memory)
+	require.NoError(t, err)
+	assert.True(t, isEncrypted)
+
+	options, err := store.GetEncryptionOptions(memory)
+	require.NoError(t, err)
+	assert.True(t, options.HashKeys)
+	assert.Equal(t, testEncryptionOptions.Memory, options.Memory)
+
+	_, err = store.OpenEncryption(memory, []byte("wrong passphrase"))
+	assert.ErrorIs(t, err, store.ErrWrongPassphrase)
+
+	_, err = store.OpenEncryption(memory, nil)
+	assert.ErrorIs(t, err, store.ErrEmptyPassphrase)
+
+	_, err = store.InitEncryption(memory, testPassphrase, testEncryptionOptions)
+	assert.ErrorIs(t, err, store.ErrAlreadyEncrypted)
+
+	reopened, err := store.OpenEncryption(memory, testPassphrase)
+	require.NoError(t, err)
+	err = reopened.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		value, err := bucket.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenEncryptionOnPlainStore(t *testing.T) {
+	t.Parallel()
+
+	memory := store.NewMemoryStore()
+	isEncrypted, err := store.IsEncrypted(memory)
+	require.NoError(t, err)
+	assert.False(t, isEncrypted)
+
+	_, err = store.OpenEncryption(memory, testPassphrase)
+	assert.ErrorIs(t, err, store.ErrNotEncrypted)
+}
@@ -12,4 +12,7 @@ var (
 	ErrBucketNameExists    = errors.New("A bucket with this name already exists")
 	ErrKeyNoName           = errors.New("Cannot use a blank name for a key")
 	ErrKeyNotFound         = errors.New("Key not found")
+	ErrIncompatibleValue   = errors.New("A key and a bucket cannot share the same name")
+	ErrDatabaseLocked      = errors.New("The database is in use by another process")
+	ErrDatabaseReadOnly    = errors.New("The database is opened in read-only mode")
 )
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes a shared or an exclusive lock on the file.
+// It returns ErrDatabaseLocked if the lock is still held by another process after the timeout: zero waits forever.
+func lockFile(file *os.File, exclusive bool, timeout time.Duration) error {
+	flag := unix.LOCK_SH
+	if exclusive {
+		flag = unix.LOCK_EX
+	}
+	start := time.Now()
+	for {
+		err := unix.Flock(int(file.Fd()), flag|unix.LOCK_NB)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			return err
+		}
+		if timeout > 0 && time.Since(start) > timeout {
+			return ErrDatabaseLocked
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// unlockFile releases the lock on the file
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
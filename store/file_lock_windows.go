@@ -0,0 +1,40 @@
+//go:build windows
+// +build windows
+
+package store
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes a shared or an exclusive lock on the file.
+// It returns ErrDatabaseLocked if the lock is still held by another process after the timeout: zero waits forever.
+func lockFile(file *os.File, exclusive bool, timeout time.Duration) error {
+	var flag uint32 = windows.LOCKFILE_FAIL_IMMEDIATELY
+	if exclusive {
+		flag |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	start := time.Now()
+	for {
+		err := windows.LockFileEx(windows.Handle(file.Fd()), flag, 0, 1, 0, &windows.Overlapped{})
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return err
+		}
+		if timeout > 0 && time.Since(start) > timeout {
+			return ErrDatabaseLocked
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// unlockFile releases the lock on the file
+func unlockFile(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
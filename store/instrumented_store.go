@@ -0,0 +1,368 @@
+package store
+
+import (
+	"context"
+	"io"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Metrics is a snapshot of the activity of an InstrumentedStore
+type Metrics struct {
+	ReadTransactions  uint64
+	WriteTransactions uint64
+	// Rollbacks is the number of write transactions rolled back, on error or on request
+	Rollbacks uint64
+	// ReadTime is the total time spent in read transactions
+	ReadTime time.Duration
+	// WriteTime is the total time spent in write transactions, commits included
+	WriteTime time.Duration
+	// MaxWriteTime is the longest write transaction
+	MaxWriteTime time.Duration
+	// LockWait is the total time spent waiting for write transactions to start.
+	// The time a job waits for a batch is counted too.
+	LockWait time.Duration
+	// Buckets is the activity per bucket, from the path of the bucket joined with "/"
+	Buckets map[string]BucketMetrics
+}
+
+// BucketMetrics is the activity on the keys of a bucket
+type BucketMetrics struct {
+	Reads        uint64
+	Writes       uint64
+	Deletes      uint64
+	BytesRead    uint64
+	BytesWritten uint64
+}
+
+// InstrumentedStore is a Store decorator measuring the transactions and the data read and written in each bucket
+type InstrumentedStore struct {
+	store   Store
+	mutex   *sync.Mutex
+	metrics Metrics
+}
+
+// NewInstrumentedStore wraps the store
+func NewInstrumentedStore(store Store) *InstrumentedStore {
+	return &InstrumentedStore{
+		store: store,
+		mutex: &sync.Mutex{},
+		metrics: Metrics{
+			Buckets: make(map[string]BucketMetrics),
+		},
+	}
+}
+
+// Metrics returns a snapshot of the metrics collected since the store was created
+func (s *InstrumentedStore) Metrics() Metrics {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	metrics := s.metrics
+	metrics.Buckets = maps.Clone(s.metrics.Buckets)
+	return metrics
+}
+
+// Unwrap returns the underlying store
+func (s *InstrumentedStore) Unwrap() Store {
+	return s.store
+}
+
+// Begin a transaction. The transaction is measured until it's committed or rolled back.
+func (s *InstrumentedStore) Begin(writable bool) (Transaction, error) {
+	start := time.Now()
+	tx, err := s.store.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	if writable {
+		s.lockWait(time.Since(start))
+	}
+	transaction := s.wrap(tx)
+	transaction.start = time.Now()
+	return transaction, nil
+}
+
+// Close the underlying store
+func (s *InstrumentedStore) Close() {
+	s.store.Close()
+}
+
+// Update run the job in a transaction
+func (s *InstrumentedStore) Update(job func(transaction Transaction) error) error {
+	return s.UpdateContext(context.Background(), job)
+}
+
+// View run the job in a read-only transaction
+func (s *InstrumentedStore) View(job func(transaction Transaction) error) error {
+	return s.ViewContext(context.Background(), job)
+}
+
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit
+func (s *InstrumentedStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.measureWrite(job, func(job func(transaction Transaction) error) error {
+		return s.store.UpdateContext(ctx, job)
+	})
+}
+
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *InstrumentedStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	start := time.Now()
+	err := s.store.ViewContext(ctx, func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+	s.transactionDone(false, time.Since(start), err != nil)
+	return err
+}
+
+// Batch runs the job in a write transaction shared with other concurrent calls.
+// Each job is measured as a separate transaction.
+func (s *InstrumentedStore) Batch(job func(transaction Transaction) error) error {
+	return s.measureWrite(job, s.store.Batch)
+}
+
+// WriteTo writes a snapshot of the underlying store
+func (s *InstrumentedStore) WriteTo(w io.Writer) (int64, error) {
+	return s.store.WriteTo(w)
+}
+
+// measureWrite runs the job through the run function, measuring the time before the job starts and the time until the commit
+func (s *InstrumentedStore) measureWrite(job func(transaction Transaction) error, run func(job func(transaction Transaction) error) error) error {
+	start := time.Now()
+	var started time.Time
+	err := run(func(transaction Transaction) error {
+		// the job can run more than once in a batch
+		if started.IsZero() {
+			started = time.Now()
+			s.lockWait(started.Sub(start))
+		}
+		return job(s.wrap(transaction))
+	})
+	if started.IsZero() {
+		// the transaction never started
+		return err
+	}
+	s.transactionDone(true, time.Since(started), err != nil)
+	return err
+}
+
+func (s *InstrumentedStore) lockWait(duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.metrics.LockWait += duration
+}
+
+func (s *InstrumentedStore) transactionDone(writable bool, duration time.Duration, rollback bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !writable {
+		s.metrics.ReadTransactions++
+		s.metrics.ReadTime += duration
+		return
+	}
+	s.metrics.WriteTransactions++
+	s.metrics.WriteTime += duration
+	s.metrics.MaxWriteTime = max(s.metrics.MaxWriteTime, duration)
+	if rollback {
+		s.metrics.Rollbacks++
+	}
+}
+
+// bucketActivity adds the activity to the metrics of the bucket
+func (s *InstrumentedStore) bucketActivity(path string, activity BucketMetrics) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	metrics := s.metrics.Buckets[path]
+	metrics.Reads += activity.Reads
+	metrics.Writes += activity.Writes
+	metrics.Deletes += activity.Deletes
+	metrics.BytesRead += activity.BytesRead
+	metrics.BytesWritten += activity.BytesWritten
+	s.metrics.Buckets[path] = metrics
+}
+
+func (s *InstrumentedStore) wrap(transaction Transaction) *InstrumentedTransaction {
+	return &InstrumentedTransaction{
+		Transaction: transaction,
+		store:       s,
+	}
+}
+
+// InstrumentedTransaction is a transaction measuring the activity of its buckets
+type InstrumentedTransaction struct {
+	Transaction
+	store *InstrumentedStore
+	// start is only set on the transactions created by Begin
+	start time.Time
+}
+
+// Commit the transaction
+func (t *InstrumentedTransaction) Commit() error {
+	err := t.Transaction.Commit()
+	t.done(err != nil)
+	return err
+}
+
+// Rollback the transaction
+func (t *InstrumentedTransaction) Rollback() error {
+	err := t.Transaction.Rollback()
+	t.done(t.IsWritable())
+	return err
+}
+
+// CreateBucket returns a new bucket. Returns an error if the name already exists
+func (t *InstrumentedTransaction) CreateBucket(name string) (Bucket, error) {
+	bucket, err := t.Transaction.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedBucket(bucket, name, t.store), nil
+}
+
+// GetBucket returns a bucket from its name
+func (t *InstrumentedTransaction) GetBucket(name string) (Bucket, error) {
+	bucket, err := t.Transaction.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedBucket(bucket, name, t.store), nil
+}
+
+// done records the end of a transaction created by Begin, only once
+func (t *InstrumentedTransaction) done(rollback bool) {
+	if t.start.IsZero() {
+		return
+	}
+	t.store.transactionDone(t.IsWritable(), time.Since(t.start), rollback)
+	t.start = time.Time{}
+}
+
+// InstrumentedBucket is a bucket measuring the keys read and written
+type InstrumentedBucket struct {
+	Bucket
+	path  string
+	store *InstrumentedStore
+}
+
+func newInstrumentedBucket(bucket Bucket, path string, store *InstrumentedStore) *InstrumentedBucket {
+	return &InstrumentedBucket{
+		Bucket: bucket,
+		path:   path,
+		store:  store,
+	}
+}
+
+func (b *InstrumentedBucket) Get(key string) ([]byte, error) {
+	data, err := b.Bucket.Get(key)
+	if err == nil {
+		b.store.bucketActivity(b.path, BucketMetrics{Reads: 1, BytesRead: uint64(len(key) + len(data))})
+	}
+	return data, err
+}
+
+func (b *InstrumentedBucket) Put(key string, data []byte) error {
+	err := b.Bucket.Put(key, data)
+	if err == nil {
+		b.store.bucketActivity(b.path, BucketMetrics{Writes: 1, BytesWritten: uint64(len(key) + len(data))})
+	}
+	return err
+}
+
+func (b *InstrumentedBucket) Delete(key string) error {
+	err := b.Bucket.Delete(key)
+	if err == nil {
+		b.store.bucketActivity(b.path, BucketMetrics{Deletes: 1})
+	}
+	return err
+}
+
+func (b *InstrumentedBucket) CreateBucket(name string) (Bucket, error) {
+	bucket, err := b.Bucket.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedBucket(bucket, b.childPath(name), b.store), nil
+}
+
+func (b *InstrumentedBucket) GetBucket(name string) (Bucket, error) {
+	bucket, err := b.Bucket.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedBucket(bucket, b.childPath(name), b.store), nil
+}
+
+func (b *InstrumentedBucket) Cursor() Cursor {
+	return &InstrumentedCursor{
+		cursor: b.Bucket.Cursor(),
+		bucket: b,
+	}
+}
+
+func (b *InstrumentedBucket) ForEach(fn func(key string, data []byte) error) error {
+	return b.Bucket.ForEach(b.count(fn))
+}
+
+func (b *InstrumentedBucket) ForEachPrefix(prefix string, fn func(key string, data []byte) error) error {
+	return b.Bucket.ForEachPrefix(prefix, b.count(fn))
+}
+
+func (b *InstrumentedBucket) ForEachRange(from, to string, fn func(key string, data []byte) error) error {
+	return b.Bucket.ForEachRange(from, to, b.count(fn))
+}
+
+// count returns an iteration function recording the keys read
+func (b *InstrumentedBucket) count(fn func(key string, data []byte) error) func(key string, data []byte) error {
+	return func(key string, data []byte) error {
+		b.store.bucketActivity(b.path, BucketMetrics{Reads: 1, BytesRead: uint64(len(key) + len(data))})
+		return fn(key, data)
+	}
+}
+
+func (b *InstrumentedBucket) childPath(name string) string {
+	return b.path + "/" + name
+}
+
+// InstrumentedCursor is a cursor measuring the keys read
+type InstrumentedCursor struct {
+	cursor Cursor
+	bucket *InstrumentedBucket
+}
+
+func (c *InstrumentedCursor) First() (string, []byte) {
+	return c.read(c.cursor.First())
+}
+
+func (c *InstrumentedCursor) Last() (string, []byte) {
+	return c.read(c.cursor.Last())
+}
+
+func (c *InstrumentedCursor) Next() (string, []byte) {
+	return c.read(c.cursor.Next())
+}
+
+func (c *InstrumentedCursor) Prev() (string, []byte) {
+	return c.read(c.cursor.Prev())
+}
+
+func (c *InstrumentedCursor) Seek(key string) (string, []byte) {
+	return c.read(c.cursor.Seek(key))
+}
+
+func (c *InstrumentedCursor) read(key string, data []byte) (string, []byte) {
+	if key != "" {
+		c.bucket.store.bucketActivity(c.bucket.path, BucketMetrics{Reads: 1, BytesRead: uint64(len(key) + len(data))})
+	}
+	return key, data
+}
+
+var (
+	_ Store       = &InstrumentedStore{}
+	_ Transaction = &InstrumentedTransaction{}
+	_ Bucket      = &InstrumentedBucket{}
+	_ Cursor      = &InstrumentedCursor{}
+)
@@ -0,0 +1,135 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		return store.NewInstrumentedStore(store.NewMemoryStore())
+	})
+}
+
+func TestInstrumentedBoltStore(t *testing.T) {
+	t.Parallel()
+
+	dir := boltTestPath(t)
+	var counter atomic.Int32
+	storetest.Run(t, func() store.Store {
+		database := filepath.Join(dir, fmt.Sprintf("instrumented_test_%d.db", counter.Add(1)))
+		boltStore, err := store.NewBoltStore(database)
+		require.NoError(t, err)
+		return store.NewInstrumentedStore(boltStore)
+	})
+}
+
+func TestInstrumentedStoreMetrics(t *testing.T) {
+	t.Parallel()
+
+	instrumented := store.NewInstrumentedStore(store.NewMemoryStore())
+	defer instrumented.Close()
+
+	err := instrumented.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		nested, err := bucket.CreateBucket("nested")
+		if err != nil {
+			return err
+		}
+		if err := nested.Put("key", []byte("value")); err != nil {
+			return err
+		}
+		if err := bucket.Put("key1", []byte("value1")); err != nil {
+			return err
+		}
+		if err := bucket.Put("key2", []byte("value2")); err != nil {
+			return err
+		}
+		return bucket.Delete("key2")
+	})
+	require.NoError(t, err)
+
+	err = instrumented.Update(func(transaction store.Transaction) error {
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	err = instrumented.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		if _, err := bucket.Get("key1"); err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key string, data []byte) error {
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	tx, err := instrumented.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	// rolling back twice is not counted
+	_ = tx.Rollback()
+
+	metrics := instrumented.Metrics()
+	assert.Equal(t, uint64(1), metrics.ReadTransactions)
+	assert.Equal(t, uint64(3), metrics.WriteTransactions)
+	assert.Equal(t, uint64(2), metrics.Rollbacks)
+	assert.Greater(t, metrics.WriteTime, time.Duration(0))
+	assert.GreaterOrEqual(t, metrics.WriteTime, metrics.MaxWriteTime)
+
+	assert.Equal(t, store.BucketMetrics{
+		Reads:        2,
+		Writes:       2,
+		Deletes:      1,
+		BytesRead:    20,
+		BytesWritten: 20,
+	}, metrics.Buckets["bucket"])
+	assert.Equal(t, store.BucketMetrics{
+		Writes:       1,
+		BytesWritten: 8,
+	}, metrics.Buckets["bucket/nested"])
+
+	// the snapshot is a copy
+	metrics.Buckets["bucket"] = store.BucketMetrics{}
+	assert.Equal(t, uint64(2), instrumented.Metrics().Buckets["bucket"].Writes)
+}
+
+func TestInstrumentedStoreLockWait(t *testing.T) {
+	t.Parallel()
+
+	instrumented := store.NewInstrumentedStore(store.NewMemoryStore())
+	defer instrumented.Close()
+
+	tx, err := instrumented.Begin(true)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- instrumented.Update(func(transaction store.Transaction) error {
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, tx.Rollback())
+	require.NoError(t, <-done)
+
+	assert.GreaterOrEqual(t, instrumented.Metrics().LockWait, 20*time.Millisecond)
+}
@@ -1,5 +1,10 @@
 package store
 
+import (
+	"context"
+	"io"
+)
+
 type Store interface {
 	// Begin a transaction
 	Begin(writable bool) (Transaction, error)
@@ -11,12 +16,25 @@ type Store interface {
 	Update(func(transaction Transaction) error) error
 	// View is a read-only view of a the database
 	View(func(transaction Transaction) error) error
+	// UpdateContext is like Update, but the transaction is rolled back if the context is done before the commit.
+	// The function can watch the context to stop early.
+	UpdateContext(ctx context.Context, job func(transaction Transaction) error) error
+	// ViewContext is like View, but returns the context error if the context is done before the end of the function
+	ViewContext(ctx context.Context, job func(transaction Transaction) error) error
+	// Batch is like Update, but concurrent calls are combined into a single transaction.
+	// The function can be called more than once: it must be idempotent.
+	Batch(func(transaction Transaction) error) error
+	// WriteTo writes a consistent snapshot of the whole store, taken in a read-only transaction.
+	// Other transactions can keep running during the copy.
+	WriteTo(w io.Writer) (int64, error)
 }
 
 type Bucketeer interface {
 	CreateBucket(string) (Bucket, error)
 	GetBucket(string) (Bucket, error)
 	DeleteBucket(string) error
+	// ForEachBucket runs the function on the name of every bucket at this level, in byte order
+	ForEachBucket(func(name string) error) error
 }
 
 type Transaction interface {
@@ -34,7 +52,69 @@ type KVPair interface {
 	Delete(key string) error
 }
 
+// Cursor iterates over the keys of a bucket in byte order. Nested buckets are skipped.
+// A blank key is returned when the cursor moves past the first or the last key.
+type Cursor interface {
+	First() (key string, data []byte)
+	Last() (key string, data []byte)
+	Next() (key string, data []byte)
+	Prev() (key string, data []byte)
+	// Seek moves the cursor to the given key, or to the next one if the key doesn't exist
+	Seek(key string) (string, []byte)
+}
+
+type Iterator interface {
+	Cursor() Cursor
+	// ForEach runs the function on every key of the bucket, in byte order
+	ForEach(func(key string, data []byte) error) error
+	// ForEachPrefix runs the function on every key starting with prefix
+	ForEachPrefix(prefix string, fn func(key string, data []byte) error) error
+	// ForEachRange runs the function on every key from "from" (inclusive) to "to" (exclusive).
+	// A blank "to" means until the end of the bucket.
+	ForEachRange(from, to string, fn func(key string, data []byte) error) error
+}
+
+// Sequencer is an auto-increment counter attached to a bucket.
+// Changes to the counter are committed or rolled back with the transaction.
+type Sequencer interface {
+	// NextSequence increments and returns the counter. The first value is 1.
+	NextSequence() (uint64, error)
+	// Sequence returns the current value of the counter without incrementing it
+	Sequence() uint64
+	// SetSequence changes the value of the counter
+	SetSequence(value uint64) error
+}
+
+// BucketStats is the storage used by a bucket, including all its nested buckets
+type BucketStats struct {
+	// Keys is the number of keys, the nested buckets are not counted
+	Keys int
+	// Buckets is the number of nested buckets at all levels
+	Buckets int
+	// InUse is the number of bytes used by the data
+	InUse int
+	// Allocated is the number of bytes reserved for the data. It's the same as InUse when the store has no pages.
+	// A small bolt bucket is saved inline in a page of its parent: it has no allocation of its own.
+	Allocated int
+	// Pages is the number of pages of the bucket, zero when the store has no pages
+	Pages int
+}
+
+// Add the stats of another bucket
+func (s *BucketStats) Add(other BucketStats) {
+	s.Keys += other.Keys
+	s.Buckets += other.Buckets
+	s.InUse += other.InUse
+	s.Allocated += other.Allocated
+	s.Pages += other.Pages
+}
+
 type Bucket interface {
 	Bucketeer
 	KVPair
+	Iterator
+	Sequencer
+	// Stats returns the storage used by the bucket.
+	// Some backends only count the data committed before the transaction started.
+	Stats() BucketStats
 }
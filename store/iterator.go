@@ -0,0 +1,33 @@
+package store
+
+import "strings"
+
+// forEach runs the function on every key of the cursor
+func forEach(cursor Cursor, fn func(key string, data []byte) error) error {
+	for key, data := cursor.First(); key != ""; key, data = cursor.Next() {
+		if err := fn(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachPrefix runs the function on every key starting with prefix
+func forEachPrefix(cursor Cursor, prefix string, fn func(key string, data []byte) error) error {
+	for key, data := cursor.Seek(prefix); key != "" && strings.HasPrefix(key, prefix); key, data = cursor.Next() {
+		if err := fn(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachRange runs the function on every key from "from" (inclusive) to "to" (exclusive)
+func forEachRange(cursor Cursor, from, to string, fn func(key string, data []byte) error) error {
+	for key, data := cursor.Seek(from); key != "" && (to == "" || key < to); key, data = cursor.Next() {
+		if err := fn(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
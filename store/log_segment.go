@@ -0,0 +1,349 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	logSegmentExtension = ".log"
+	// logSnapshotBatchSize is the maximum number of operations in each record of a snapshot
+	logSnapshotBatchSize = 1000
+)
+
+// Operations saved in the log
+const (
+	logPut          = "put"
+	logDelete       = "delete"
+	logCreateBucket = "create-bucket"
+	logDeleteBucket = "delete-bucket"
+	logSequence     = "sequence"
+)
+
+var logChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// logRecord is a committed transaction, saved on one line of a segment.
+// A snapshot record starts a new state: all the records before it are discarded.
+type logRecord struct {
+	Snapshot   bool           `json:"snapshot,omitempty"`
+	Operations []logOperation `json:"ops"`
+}
+
+// logOperation is a change made in a transaction
+type logOperation struct {
+	Op       string   `json:"op"`
+	Path     []string `json:"path"`
+	Key      string   `json:"key,omitempty"`
+	Value    []byte   `json:"value,omitempty"`
+	Sequence uint64   `json:"sequence,omitempty"`
+}
+
+// newLogRecord converts the changes of a transaction into a record
+func newLogRecord(changes []Change) logRecord {
+	record := logRecord{
+		Operations: make([]logOperation, 0, len(changes)),
+	}
+	for _, change := range changes {
+		operation := logOperation{
+			Path: change.Path,
+			Key:  change.Key,
+		}
+		switch change.Type {
+		case ChangePut:
+			operation.Op = logPut
+			operation.Value = change.New
+		case ChangeDelete:
+			operation.Op = logDelete
+		case ChangeCreateBucket:
+			operation.Op = logCreateBucket
+		case ChangeDeleteBucket:
+			operation.Op = logDeleteBucket
+		case ChangeSequence:
+			operation.Op = logSequence
+			operation.Sequence = binary.BigEndian.Uint64(change.New)
+		}
+		record.Operations = append(record.Operations, operation)
+	}
+	return record
+}
+
+// encodeLogRecord returns the line saved in a segment: the checksum of the record followed by the record in JSON
+func encodeLogRecord(record logRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(data)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(data, logChecksumTable))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeLogRecord reads a line saved by encodeLogRecord, without the end of line
+func decodeLogRecord(line []byte) (logRecord, error) {
+	record := logRecord{}
+	checksum, data, found := bytes.Cut(line, []byte{' '})
+	if !found || len(checksum) != 8 {
+		return record, errors.New("missing checksum")
+	}
+	expected, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil {
+		return record, fmt.Errorf("invalid checksum: %w", err)
+	}
+	if crc32.Checksum(data, logChecksumTable) != uint32(expected) {
+		return record, errors.New("checksum mismatch")
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+// apply runs all the operations of the record in the transaction
+func (r logRecord) apply(transaction Transaction) error {
+	for _, operation := range r.Operations {
+		if err := operation.apply(transaction); err != nil {
+			return fmt.Errorf("%s %q in bucket %q: %w", operation.Op, operation.Key, strings.Join(operation.Path, "/"), err)
+		}
+	}
+	return nil
+}
+
+func (o logOperation) apply(transaction Transaction) error {
+	if len(o.Path) == 0 {
+		return ErrBucketNoName
+	}
+	switch o.Op {
+	case logCreateBucket:
+		_, err := bucketAtPath(transaction, o.Path, true)
+		return err
+	case logDeleteBucket:
+		last := len(o.Path) - 1
+		if last == 0 {
+			return transaction.DeleteBucket(o.Path[0])
+		}
+		parent, err := bucketAtPath(transaction, o.Path[:last], false)
+		if err != nil {
+			return err
+		}
+		return parent.DeleteBucket(o.Path[last])
+	}
+
+	bucket, err := bucketAtPath(transaction, o.Path, false)
+	if err != nil {
+		return err
+	}
+	switch o.Op {
+	case logPut:
+		return bucket.Put(o.Key, o.Value)
+	case logDelete:
+		return bucket.Delete(o.Key)
+	case logSequence:
+		return bucket.SetSequence(o.Sequence)
+	default:
+		return fmt.Errorf("unknown operation %q", o.Op)
+	}
+}
+
+// readLogSegment runs the function on each valid record of the segment.
+// It returns the size of the valid part of the segment: anything after it is a record partially written.
+// A complete line with an invalid record is an error: the records after it were committed.
+func readLogSegment(r io.Reader, fn func(record logRecord) error) (int64, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a line without an end of line was not completely written
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+		record, err := decodeLogRecord(line[:len(line)-1])
+		if err != nil {
+			return valid, fmt.Errorf("invalid record at offset %d: %w", valid, err)
+		}
+		if err := fn(record); err != nil {
+			return valid, err
+		}
+		valid += int64(len(line))
+	}
+}
+
+// writeLogSnapshot writes the content of all the buckets of the transaction as snapshot records
+func writeLogSnapshot(w io.Writer, transaction Transaction) (int64, error) {
+	writer := &countingWriter{writer: w}
+	record := logRecord{
+		Snapshot:   true,
+		Operations: make([]logOperation, 0, logSnapshotBatchSize),
+	}
+	flush := func() error {
+		line, err := encodeLogRecord(record)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(line)
+		record = logRecord{
+			Operations: record.Operations[:0],
+		}
+		return err
+	}
+	add := func(operation logOperation) error {
+		record.Operations = append(record.Operations, operation)
+		if len(record.Operations) >= logSnapshotBatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var walk func(bucket Bucket, path []string) error
+	walk = func(bucket Bucket, path []string) error {
+		if err := add(logOperation{Op: logCreateBucket, Path: path}); err != nil {
+			return err
+		}
+		if sequence := bucket.Sequence(); sequence > 0 {
+			if err := add(logOperation{Op: logSequence, Path: path, Sequence: sequence}); err != nil {
+				return err
+			}
+		}
+		err := bucket.ForEach(func(key string, data []byte) error {
+			return add(logOperation{Op: logPut, Path: path, Key: key, Value: copyBytes(data)})
+		})
+		if err != nil {
+			return err
+		}
+		return bucket.ForEachBucket(func(name string) error {
+			nested, err := bucket.GetBucket(name)
+			if err != nil {
+				return err
+			}
+			return walk(nested, childPath(path, name))
+		})
+	}
+	err := transaction.ForEachBucket(func(name string) error {
+		bucket, err := transaction.GetBucket(name)
+		if err != nil {
+			return err
+		}
+		return walk(bucket, []string{name})
+	})
+	if err != nil {
+		return writer.count, err
+	}
+	// the last record also marks an empty snapshot
+	if len(record.Operations) > 0 || record.Snapshot {
+		err = flush()
+	}
+	return writer.count, err
+}
+
+// logSegmentName returns the file name of a segment: the names are sorted in the order of the segments
+func logSegmentName(id uint64) string {
+	return fmt.Sprintf("%016d%s", id, logSegmentExtension)
+}
+
+// listLogSegments returns the ID of all the segments found in the directory, in order
+func listLogSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		name, found := strings.CutSuffix(entry.Name(), logSegmentExtension)
+		if !found || entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, id)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// logWriter appends the records at the end of the last segment, and starts a new segment when it gets too big
+type logWriter struct {
+	dir         string
+	id          uint64
+	file        *os.File
+	size        int64
+	segmentSize int64
+	sync        bool
+}
+
+// openLogWriter opens the segment for appending the next records
+func openLogWriter(dir string, id uint64, segmentSize int64, sync bool) (*logWriter, error) {
+	w := &logWriter{
+		dir:         dir,
+		segmentSize: segmentSize,
+		sync:        sync,
+	}
+	if err := w.open(id); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// append writes the record at the end of the log. A record is never split between two segments.
+// If the write fails, the segment is truncated back to its previous size.
+func (w *logWriter) append(record logRecord) error {
+	line, err := encodeLogRecord(record)
+	if err != nil {
+		return err
+	}
+	if w.size > 0 && w.segmentSize > 0 && w.size+int64(len(line)) > w.segmentSize {
+		if err := w.open(w.id + 1); err != nil {
+			return err
+		}
+	}
+	_, err = w.file.Write(line)
+	if err == nil && w.sync {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		_ = w.file.Truncate(w.size)
+		return err
+	}
+	w.size += int64(len(line))
+	return nil
+}
+
+// open closes the current segment and opens the segment for appending
+func (w *logWriter) open(id uint64) error {
+	file, err := os.OpenFile(filepath.Join(w.dir, logSegmentName(id)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.id = id
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *logWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultLogSegmentSize is the size after which the log continues in a new segment file
+	DefaultLogSegmentSize = 64 * 1024 * 1024
+	logLockFile           = "LOCK"
+	lockRetryDelay        = 50 * time.Millisecond
+)
+
+var (
+	ErrLogCorrupted = errors.New("The log is corrupted")
+)
+
+// LogOptions are the options used to open a log directory
+type LogOptions struct {
+	// ReadOnly opens the log in read-only mode: many processes can read the same log at the same time,
+	// and the log can be saved on a read-only medium
+	ReadOnly bool
+	// LockTimeout is the time to wait for the directory lock: zero waits forever
+	LockTimeout time.Duration
+	// SegmentSize is the size after which the log continues in a new segment file
+	SegmentSize int64
+	// NoSync doesn't flush the segment to the disk after each commit: it's faster,
+	// but the last transactions can be lost if the system crashes
+	NoSync bool
+}
+
+// DefaultLogOptions returns the options to open the log for writing
+func DefaultLogOptions() LogOptions {
+	return LogOptions{
+		LockTimeout: DefaultLockTimeout,
+		SegmentSize: DefaultLogSegmentSize,
+	}
+}
+
+// LogStore is an append-only store: each committed transaction is added as a new record at the end of a log,
+// which is split into segment files in a directory. The segments are never modified once complete,
+// so they can be synchronized or diffed easily. A record is one line of JSON, starting with its checksum.
+//
+// The whole content is kept in memory: the log is replayed when the store is opened. A record partially written
+// at the end of the log, after a crash, is discarded. The space used by old values is only reclaimed by CompactLogStore.
+type LogStore struct {
+	dir        string
+	options    LogOptions
+	lock       *os.File
+	memory     *MemoryStore
+	observable *ObservableStore
+	writer     *logWriter
+	batcher    *batcher
+}
+
+// NewLogStore opens or creates the log directory for writing, with the default options
+func NewLogStore(dir string) (*LogStore, error) {
+	return NewLogStoreWithOptions(dir, DefaultLogOptions())
+}
+
+// NewLogStoreWithOptions opens the log directory, and loads all the records in memory.
+// It returns ErrDatabaseLocked if the directory is still locked by another process after the timeout.
+func NewLogStoreWithOptions(dir string, options LogOptions) (*LogStore, error) {
+	s, err := openLogStore(dir, options)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open log directory '%s': %w", dir, err)
+	}
+	return s, nil
+}
+
+func openLogStore(dir string, options LogOptions) (*LogStore, error) {
+	if !options.ReadOnly {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	lock, err := openLockFile(filepath.Join(dir, logLockFile), options)
+	if err != nil {
+		return nil, err
+	}
+	s := &LogStore{
+		dir:     dir,
+		options: options,
+		lock:    lock,
+	}
+	err = s.load()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.observable = NewObservableStore(s.memory)
+	s.observable.OnBeforeCommit(s.appendRecord)
+	s.batcher = newBatcher(s.Update, DefaultMaxBatchSize, DefaultMaxBatchDelay)
+	return s, nil
+}
+
+// openLockFile takes a shared lock in read-only mode, or an exclusive lock for writing
+func openLockFile(filename string, options LogOptions) (*os.File, error) {
+	flag := os.O_RDONLY
+	if !options.ReadOnly {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+	file, err := os.OpenFile(filename, flag, 0o600)
+	if errors.Is(err, os.ErrNotExist) && options.ReadOnly {
+		return nil, fmt.Errorf("not a log directory: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(file, !options.ReadOnly, options.LockTimeout)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// load replays all the segments into a new memory store, and opens the last segment for writing
+func (s *LogStore) load() error {
+	segments, err := listLogSegments(s.dir)
+	if err != nil {
+		return err
+	}
+	s.memory = NewMemoryStore()
+	tx, err := s.memory.begin(context.Background(), true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// index of the segment starting with the last snapshot
+	start := 0
+	for index, id := range segments {
+		valid, err := s.replay(id, func(record logRecord) error {
+			if record.Snapshot {
+				// discard the state from the previous segments
+				_ = tx.Rollback()
+				s.memory = NewMemoryStore()
+				var err error
+				tx, err = s.memory.begin(context.Background(), true)
+				if err != nil {
+					return err
+				}
+				start = index
+			}
+			return record.apply(tx)
+		})
+		if err != nil {
+			return fmt.Errorf("%w: segment %s: %w", ErrLogCorrupted, logSegmentName(id), err)
+		}
+		last := index == len(segments)-1
+		if err := s.recover(id, valid, last); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if s.options.ReadOnly {
+		return nil
+	}
+
+	// the segments before the last snapshot are left over from an interrupted compaction
+	for _, id := range segments[:start] {
+		if err := os.Remove(filepath.Join(s.dir, logSegmentName(id))); err != nil {
+			return err
+		}
+	}
+	next := uint64(1)
+	if len(segments) > 0 {
+		next = segments[len(segments)-1]
+	}
+	s.writer, err = openLogWriter(s.dir, next, s.options.SegmentSize, !s.options.NoSync)
+	return err
+}
+
+// replay runs the function on all the valid records of the segment, and returns the size of the valid part of the segment
+func (s *LogStore) replay(id uint64, fn func(record logRecord) error) (int64, error) {
+	file, err := os.Open(filepath.Join(s.dir, logSegmentName(id)))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return readLogSegment(file, fn)
+}
+
+// recover removes the record partially written at the end of the last segment.
+// Any invalid data in the middle of the log is an error.
+func (s *LogStore) recover(id uint64, valid int64, last bool) error {
+	filename := filepath.Join(s.dir, logSegmentName(id))
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if info.Size() == valid {
+		return nil
+	}
+	if !last {
+		return fmt.Errorf("%w: invalid record at offset %d of segment %s", ErrLogCorrupted, valid, logSegmentName(id))
+	}
+	if s.options.ReadOnly {
+		// the end of the log is ignored
+		return nil
+	}
+	return os.Truncate(filename, valid)
+}
+
+// appendRecord is the commit hook saving the changes of the transaction at the end of the log
+func (s *LogStore) appendRecord(transaction Transaction, changes []Change) error {
+	return s.writer.append(newLogRecord(changes))
+}
+
+// Dir returns the directory of the log
+func (s *LogStore) Dir() string {
+	return s.dir
+}
+
+// IsReadOnly returns true when the log was opened in read-only mode
+func (s *LogStore) IsReadOnly() bool {
+	return s.options.ReadOnly
+}
+
+// Begin a transaction
+func (s *LogStore) Begin(writable bool) (Transaction, error) {
+	if writable && s.options.ReadOnly {
+		return nil, ErrDatabaseReadOnly
+	}
+	return s.observable.Begin(writable)
+}
+
+// Close the log
+func (s *LogStore) Close() {
+	if s.memory != nil {
+		s.memory.Close()
+	}
+	if s.writer != nil {
+		s.writer.Close()
+	}
+	_ = unlockFile(s.lock)
+	s.lock.Close()
+}
+
+// Update run the job in a transaction, saved at the end of the log when committed
+func (s *LogStore) Update(job func(transaction Transaction) error) error {
+	return s.UpdateContext(context.Background(), job)
+}
+
+// View run the job in a read-only transaction
+func (s *LogStore) View(job func(transaction Transaction) error) error {
+	return s.observable.View(job)
+}
+
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit
+func (s *LogStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	if s.options.ReadOnly {
+		return ErrDatabaseReadOnly
+	}
+	return s.observable.UpdateContext(ctx, job)
+}
+
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *LogStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.observable.ViewContext(ctx, job)
+}
+
+// Batch runs the job in a write transaction shared with other concurrent calls: the jobs are saved in a single record
+func (s *LogStore) Batch(job func(transaction Transaction) error) error {
+	if s.options.ReadOnly {
+		return ErrDatabaseReadOnly
+	}
+	return s.batcher.Batch(job)
+}
+
+// WriteTo writes the current content of the store as a single segment.
+// A directory containing only this segment is a valid log.
+func (s *LogStore) WriteTo(w io.Writer) (int64, error) {
+	var size int64
+	err := s.memory.View(func(transaction Transaction) error {
+		var err error
+		size, err = writeLogSnapshot(w, transaction)
+		return err
+	})
+	return size, err
+}
+
+// compact writes the current content into a new segment, and removes all the previous segments
+func (s *LogStore) compact() error {
+	id := s.writer.id + 1
+	filename := filepath.Join(s.dir, logSegmentName(id))
+	temporary := filename + ".tmp"
+	file, err := os.Create(temporary)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary)
+
+	_, err = s.WriteTo(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// once renamed, the new segment replaces the previous ones even if they cannot be removed
+	if err := os.Rename(temporary, filename); err != nil {
+		return err
+	}
+	if err := s.writer.open(id); err != nil {
+		return err
+	}
+	segments, err := listLogSegments(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment < id {
+			if err := os.Remove(filepath.Join(s.dir, logSegmentName(segment))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CompactLogStore rewrites the log directory into a single segment containing only the current content.
+// The log must not be in use: it is opened for writing during the compaction.
+func CompactLogStore(dir string, options LogOptions) error {
+	options.ReadOnly = false
+	s, err := NewLogStoreWithOptions(dir, options)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.compact()
+}
+
+var (
+	_ Store = &LogStore{}
+)
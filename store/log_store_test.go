@@ -0,0 +1,407 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logTestPath returns a temporary directory for the log store tests, which only run when DB_TEST_PATH is set
+func logTestPath(t *testing.T) string {
+	t.Helper()
+
+	testPath := os.Getenv("DB_TEST_PATH")
+	if testPath == "" {
+		t.Skip("DB_TEST_PATH is not set")
+	}
+	dir, err := os.MkdirTemp(testPath, "log_store_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// fillLogStore saves some buckets, keys and sequences, with changes that are not visible in the final state
+func fillLogStore(t *testing.T, s Store) {
+	t.Helper()
+
+	err := s.Update(func(transaction Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key1", []byte("value1")))
+		require.NoError(t, bucket.Put("key2", []byte("value2")))
+		require.NoError(t, bucket.Put("empty", []byte{}))
+		nested, err := bucket.CreateBucket("nested")
+		require.NoError(t, err)
+		_, err = nested.NextSequence()
+		require.NoError(t, err)
+		require.NoError(t, nested.Put("key", []byte("nested")))
+		removed, err := transaction.CreateBucket("removed")
+		require.NoError(t, err)
+		return removed.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	err = s.Update(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key1", []byte("changed")))
+		require.NoError(t, bucket.Delete("key2"))
+		nested, err := bucket.GetBucket("nested")
+		require.NoError(t, err)
+		require.NoError(t, nested.SetSequence(10))
+		require.NoError(t, transaction.DeleteBucket("removed"))
+		// same name as the deleted bucket, but a different content
+		removed, err := transaction.CreateBucket("removed")
+		require.NoError(t, err)
+		return removed.Put("other", []byte("value"))
+	})
+	require.NoError(t, err)
+}
+
+// assertLogStoreContent checks the content saved by fillLogStore
+func assertLogStoreContent(t *testing.T, s Store) {
+	t.Helper()
+
+	err := s.View(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		value, err := bucket.Get("key1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("changed"), value)
+		_, err = bucket.Get("key2")
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		value, err = bucket.Get("empty")
+		require.NoError(t, err)
+		assert.Empty(t, value)
+
+		nested, err := bucket.GetBucket("nested")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(10), nested.Sequence())
+		value, err = nested.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("nested"), value)
+
+		removed, err := transaction.GetBucket("removed")
+		require.NoError(t, err)
+		_, err = removed.Get("key")
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		_, err = removed.Get("other")
+		assert.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestLogStoreReopen(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	logStore, err := NewLogStore(dir)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+	logStore.Close()
+
+	logStore, err = NewLogStore(dir)
+	require.NoError(t, err)
+	defer logStore.Close()
+	assertLogStoreContent(t, logStore)
+}
+
+func TestLogStoreRecoversPartialRecord(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	logStore, err := NewLogStore(dir)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+	logStore.Close()
+
+	segment := filepath.Join(dir, logSegmentName(1))
+	valid, err := os.Stat(segment)
+	require.NoError(t, err)
+
+	// crash in the middle of writing a record
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`12345678 {"ops":[{"op":"put","path":["bucket"],"key":"lost"`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	logStore, err = NewLogStore(dir)
+	require.NoError(t, err)
+	assertLogStoreContent(t, logStore)
+
+	info, err := os.Stat(segment)
+	require.NoError(t, err)
+	assert.Equal(t, valid.Size(), info.Size())
+
+	// the log continues after the last valid record
+	err = logStore.Update(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		return bucket.Put("saved", []byte("value"))
+	})
+	require.NoError(t, err)
+	logStore.Close()
+
+	logStore, err = NewLogStore(dir)
+	require.NoError(t, err)
+	defer logStore.Close()
+	assertLogStoreContent(t, logStore)
+}
+
+func TestLogStoreCorruptedSegment(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	options := DefaultLogOptions()
+	options.SegmentSize = 1
+	logStore, err := NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+	logStore.Close()
+
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	// change one byte in the middle of the log
+	segment := filepath.Join(dir, logSegmentName(segments[0]))
+	data, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	data[len(data)/2]++
+	require.NoError(t, os.WriteFile(segment, data, 0o600))
+
+	_, err = NewLogStoreWithOptions(dir, options)
+	assert.ErrorIs(t, err, ErrLogCorrupted)
+}
+
+func TestLogStoreCorruptedLastSegment(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	logStore, err := NewLogStore(dir)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+	logStore.Close()
+
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	// change one byte in the middle of the only segment: the records after it must not be dropped
+	segment := filepath.Join(dir, logSegmentName(segments[0]))
+	data, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	data[len(data)/2]++
+	require.NoError(t, os.WriteFile(segment, data, 0o600))
+
+	_, err = NewLogStore(dir)
+	assert.ErrorIs(t, err, ErrLogCorrupted)
+	after, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+}
+
+func TestLogStoreSegments(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	options := DefaultLogOptions()
+	options.SegmentSize = 1024
+	options.NoSync = true
+	logStore, err := NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+
+	err = logStore.Update(func(transaction Transaction) error {
+		_, err := transaction.CreateBucket("bucket")
+		return err
+	})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		err = logStore.Update(func(transaction Transaction) error {
+			bucket, err := transaction.GetBucket("bucket")
+			if err != nil {
+				return err
+			}
+			return bucket.Put(fmt.Sprintf("key%03d", i), bytes.Repeat([]byte{'a'}, 100))
+		})
+		require.NoError(t, err)
+	}
+	logStore.Close()
+
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 10)
+	for _, segment := range segments {
+		info, err := os.Stat(filepath.Join(dir, logSegmentName(segment)))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(1024))
+	}
+
+	logStore, err = NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	defer logStore.Close()
+	err = logStore.View(func(transaction Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		assert.Equal(t, 100, bucket.Stats().Keys)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCompactLogStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	options := DefaultLogOptions()
+	options.SegmentSize = 1024
+	logStore, err := NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+	// overwrite the same key many times
+	for i := 0; i < 50; i++ {
+		err = logStore.Update(func(transaction Transaction) error {
+			bucket, err := transaction.GetBucket("removed")
+			if err != nil {
+				return err
+			}
+			return bucket.Put("counter", []byte(fmt.Sprintf("value %d", i)))
+		})
+		require.NoError(t, err)
+	}
+	logStore.Close()
+	before := logSize(t, dir)
+
+	require.NoError(t, CompactLogStore(dir, options))
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+	assert.Less(t, logSize(t, dir), before/4)
+
+	logStore, err = NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	defer logStore.Close()
+	assertLogStoreContent(t, logStore)
+}
+
+func TestLogStoreInterruptedCompaction(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	logStore, err := NewLogStore(dir)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+
+	// the compacted segment was saved, but the previous segment was not removed
+	snapshot := &bytes.Buffer{}
+	_, err = logStore.WriteTo(snapshot)
+	require.NoError(t, err)
+	logStore.Close()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, logSegmentName(2)), snapshot.Bytes(), 0o600))
+
+	logStore, err = NewLogStore(dir)
+	require.NoError(t, err)
+	defer logStore.Close()
+	assertLogStoreContent(t, logStore)
+
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, segments)
+}
+
+func TestLogStoreWriteTo(t *testing.T) {
+	t.Parallel()
+
+	root := logTestPath(t)
+	logStore, err := NewLogStore(filepath.Join(root, "log"))
+	require.NoError(t, err)
+	defer logStore.Close()
+	fillLogStore(t, logStore)
+
+	// a directory with only the snapshot is a valid log
+	copied := filepath.Join(root, "copy")
+	require.NoError(t, os.Mkdir(copied, 0o700))
+	file, err := os.Create(filepath.Join(copied, logSegmentName(1)))
+	require.NoError(t, err)
+	size, err := logStore.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	assert.Greater(t, size, int64(0))
+
+	copyStore, err := NewLogStore(copied)
+	require.NoError(t, err)
+	defer copyStore.Close()
+	assertLogStoreContent(t, copyStore)
+}
+
+func TestLogStoreReadOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(logTestPath(t), "log")
+	options := DefaultLogOptions()
+	options.ReadOnly = true
+	_, err := NewLogStoreWithOptions(dir, options)
+	assert.Error(t, err)
+
+	logStore, err := NewLogStore(dir)
+	require.NoError(t, err)
+	fillLogStore(t, logStore)
+
+	// the directory is locked by the writer
+	options.LockTimeout = 100 * time.Millisecond
+	_, err = NewLogStoreWithOptions(dir, options)
+	assert.ErrorIs(t, err, ErrDatabaseLocked)
+	logStore.Close()
+
+	// many readers at the same time
+	reader1, err := NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	defer reader1.Close()
+	reader2, err := NewLogStoreWithOptions(dir, options)
+	require.NoError(t, err)
+	defer reader2.Close()
+	assertLogStoreContent(t, reader2)
+
+	assert.True(t, reader1.IsReadOnly())
+	_, err = reader1.Begin(true)
+	assert.ErrorIs(t, err, ErrDatabaseReadOnly)
+	err = reader1.Update(func(transaction Transaction) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrDatabaseReadOnly)
+	err = reader1.Batch(func(transaction Transaction) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrDatabaseReadOnly)
+
+	// the writer must wait for the readers
+	writerOptions := DefaultLogOptions()
+	writerOptions.LockTimeout = 100 * time.Millisecond
+	_, err = NewLogStoreWithOptions(dir, writerOptions)
+	assert.ErrorIs(t, err, ErrDatabaseLocked)
+}
+
+// logSize returns the total size of the segments
+func logSize(t *testing.T, dir string) int64 {
+	t.Helper()
+
+	segments, err := listLogSegments(dir)
+	require.NoError(t, err)
+	var size int64
+	for _, segment := range segments {
+		info, err := os.Stat(filepath.Join(dir, logSegmentName(segment)))
+		require.NoError(t, err)
+		size += info.Size()
+	}
+	return size
+}
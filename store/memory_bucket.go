@@ -1,24 +1,16 @@
 package store
 
-import (
-	"sync"
-)
-
 // MemoryBucket represents a bucket in memory during a transaction
 type MemoryBucket struct {
-	name  string
-	data  map[string][]byte
-	mutex *sync.Mutex
-	tx    *MemoryTransaction
+	path []string
+	tx   *MemoryTransaction
 }
 
 // newMemoryBucket instantiate a new bucket in memory
-func newMemoryBucket(name string, data map[string][]byte, tx *MemoryTransaction) *MemoryBucket {
+func newMemoryBucket(path []string, tx *MemoryTransaction) *MemoryBucket {
 	return &MemoryBucket{
-		name:  name,
-		data:  data,
-		mutex: &sync.Mutex{},
-		tx:    tx,
+		path: path,
+		tx:   tx,
 	}
 }
 
@@ -31,13 +23,14 @@ func (b *MemoryBucket) Get(key string) ([]byte, error) {
 		return nil, ErrKeyNoName
 	}
 
-	var data []byte
-	var ok bool
-
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
 
-	data, ok = b.data[key]
+	node := b.tx.node(b.path)
+	if node == nil {
+		return nil, ErrBucketNotFound
+	}
+	data, ok := node.data[key]
 	if ok {
 		return data, nil
 	}
@@ -56,10 +49,20 @@ func (b *MemoryBucket) Put(key string, data []byte) error {
 		return ErrBucketReadOnly
 	}
 
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
 
-	b.data[key] = data
+	node, err := b.tx.writableNode(b.path)
+	if err != nil {
+		return err
+	}
+	if _, found := node.buckets[key]; found {
+		return ErrIncompatibleValue
+	}
+	// the caller is free to reuse its buffer
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	node.put(key, copied)
 	return nil
 }
 
@@ -75,34 +78,159 @@ func (b *MemoryBucket) Delete(key string) error {
 		return ErrBucketReadOnly
 	}
 
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
 
-	if _, found := b.data[key]; found {
-		delete(b.data, key)
+	node, err := b.tx.writableNode(b.path)
+	if err != nil {
+		return err
+	}
+	if _, found := node.buckets[key]; found {
+		return ErrIncompatibleValue
 	}
+	node.delete(key)
 	return nil
 }
 
+// Cursor returns a cursor on a snapshot of the keys of the bucket, in byte order
+func (b *MemoryBucket) Cursor() Cursor {
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
+
+	node := b.tx.node(b.path)
+	if node == nil {
+		return newMemoryCursor(nil)
+	}
+	if node.txID != b.tx.id {
+		// committed nodes never change, and their keys are already sorted
+		return &MemoryCursor{
+			keys: node.keys,
+			data: node.data,
+		}
+	}
+	return node.cursor()
+}
+
+func (b *MemoryBucket) ForEach(fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEach(b.Cursor(), fn)
+}
+
+func (b *MemoryBucket) ForEachPrefix(prefix string, fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEachPrefix(b.Cursor(), prefix, fn)
+}
+
+func (b *MemoryBucket) ForEachRange(from, to string, fn func(key string, data []byte) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return forEachRange(b.Cursor(), from, to, fn)
+}
+
 func (b *MemoryBucket) CreateBucket(name string) (Bucket, error) {
-	return b.tx.CreateBucket(b.bucketName(name))
+	if b == nil {
+		return nil, ErrNullPointerBucket
+	}
+	return b.tx.createBucket(b.path, name)
 }
 
 func (b *MemoryBucket) GetBucket(name string) (Bucket, error) {
-	return b.tx.GetBucket(b.bucketName(name))
+	if b == nil {
+		return nil, ErrNullPointerBucket
+	}
+	return b.tx.getBucket(b.path, name)
 }
 
 func (b *MemoryBucket) DeleteBucket(name string) error {
-	return b.tx.DeleteBucket(b.bucketName(name))
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return b.tx.deleteBucket(b.path, name)
 }
 
-func (b *MemoryBucket) bucketName(name string) string {
-	return b.name + "/" + name
+// NextSequence increments and returns the sequence of the bucket
+func (b *MemoryBucket) NextSequence() (uint64, error) {
+	if b == nil {
+		return 0, ErrNullPointerBucket
+	}
+	if !b.tx.IsWritable() {
+		return 0, ErrBucketReadOnly
+	}
+
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
+
+	node, err := b.tx.writableNode(b.path)
+	if err != nil {
+		return 0, err
+	}
+	node.sequence++
+	return node.sequence, nil
+}
+
+// Sequence returns the current sequence of the bucket
+func (b *MemoryBucket) Sequence() uint64 {
+	if b == nil {
+		return 0
+	}
+
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
+
+	node := b.tx.node(b.path)
+	if node == nil {
+		return 0
+	}
+	return node.sequence
+}
+
+// SetSequence changes the sequence of the bucket
+func (b *MemoryBucket) SetSequence(value uint64) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	if !b.tx.IsWritable() {
+		return ErrBucketReadOnly
+	}
+
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
+
+	node, err := b.tx.writableNode(b.path)
+	if err != nil {
+		return err
+	}
+	node.sequence = value
+	return nil
+}
+
+// Stats returns the size of the keys and values of the bucket and its nested buckets
+func (b *MemoryBucket) Stats() BucketStats {
+	if b == nil {
+		return BucketStats{}
+	}
+
+	b.tx.mutex.Lock()
+	defer b.tx.mutex.Unlock()
+
+	node := b.tx.node(b.path)
+	if node == nil {
+		return BucketStats{}
+	}
+	return node.stats()
 }
 
-// getData returns the data in the bucket
-func (b *MemoryBucket) getData() map[string][]byte {
-	return b.data
+// ForEachBucket runs the function on the name of every nested bucket
+func (b *MemoryBucket) ForEachBucket(fn func(name string) error) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	return b.tx.forEachBucket(b.path, fn)
 }
 
 // Test the interface
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"sort"
+)
+
+// MemoryCursor is a cursor on a snapshot of the keys of a memory bucket
+type MemoryCursor struct {
+	keys     []string
+	data     map[string][]byte
+	position int
+}
+
+// newMemoryCursor creates a cursor on a sorted copy of the keys of a map loaded in memory. The data map must not be modified.
+func newMemoryCursor(data map[string][]byte) *MemoryCursor {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &MemoryCursor{
+		keys: keys,
+		data: data,
+	}
+}
+
+func (c *MemoryCursor) First() (string, []byte) {
+	return c.move(0)
+}
+
+func (c *MemoryCursor) Last() (string, []byte) {
+	return c.move(len(c.keys) - 1)
+}
+
+func (c *MemoryCursor) Next() (string, []byte) {
+	return c.move(c.position + 1)
+}
+
+func (c *MemoryCursor) Prev() (string, []byte) {
+	return c.move(c.position - 1)
+}
+
+func (c *MemoryCursor) Seek(key string) (string, []byte) {
+	return c.move(sort.SearchStrings(c.keys, key))
+}
+
+func (c *MemoryCursor) move(position int) (string, []byte) {
+	// stay one step outside the keys so the cursor can come back
+	if position < 0 {
+		c.position = -1
+		return "", nil
+	}
+	if position >= len(c.keys) {
+		c.position = len(c.keys)
+		return "", nil
+	}
+	c.position = position
+	key := c.keys[position]
+	return key, c.data[key]
+}
+
+// Test the interface
+var (
+	_ Cursor = &MemoryCursor{}
+)
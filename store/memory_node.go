@@ -0,0 +1,160 @@
+package store
+
+import (
+	"slices"
+	"sort"
+)
+
+// memoryNode is a bucket of the memory store: its keys, its nested buckets and its sequence.
+// Only the write transaction which created a node can modify it.
+// Once committed, a node is shared between transactions and never changes again.
+type memoryNode struct {
+	data     map[string][]byte
+	buckets  map[string]*memoryNode
+	sequence uint64
+	txID     uint
+	// keys of data in byte order, without the keys added since the last sort.
+	// The keys of a committed node are always sorted.
+	keys []string
+	// added are the keys added since the last sort, in any order
+	added []string
+	// deleted is true when some keys were removed from data since the last sort
+	deleted bool
+	// shared is true when a cursor reads data and keys: they must be copied before the next change
+	shared bool
+}
+
+func newMemoryNode(txID uint) *memoryNode {
+	return &memoryNode{
+		data:    make(map[string][]byte),
+		buckets: make(map[string]*memoryNode),
+		txID:    txID,
+	}
+}
+
+// clone returns a shallow copy of the node, owned by the transaction.
+// The values and the nested nodes are shared with the original node.
+func (n *memoryNode) clone(txID uint) *memoryNode {
+	clone := &memoryNode{
+		data:     make(map[string][]byte, len(n.data)),
+		buckets:  make(map[string]*memoryNode, len(n.buckets)),
+		sequence: n.sequence,
+		txID:     txID,
+		// the first key added to the clone makes a copy of the sorted keys
+		keys: slices.Clip(n.keys),
+	}
+	for key, value := range n.data {
+		clone.data[key] = value
+	}
+	for name, bucket := range n.buckets {
+		clone.buckets[name] = bucket
+	}
+	return clone
+}
+
+// put sets the value of the key in a node owned by the transaction
+func (n *memoryNode) put(key string, value []byte) {
+	n.unshare()
+	if _, found := n.data[key]; !found {
+		n.added = append(n.added, key)
+	}
+	n.data[key] = value
+}
+
+// delete removes the key from a node owned by the transaction
+func (n *memoryNode) delete(key string) {
+	if _, found := n.data[key]; !found {
+		return
+	}
+	n.unshare()
+	delete(n.data, key)
+	n.deleted = true
+}
+
+// cursor returns a cursor on the keys of the node. The node keeps the keys sorted
+// so a cursor costs nothing: the keys and the data are copied on the next change instead.
+func (n *memoryNode) cursor() *MemoryCursor {
+	n.sortKeys()
+	n.shared = true
+	return &MemoryCursor{
+		keys: n.keys,
+		data: n.data,
+	}
+}
+
+// unshare copies the keys and the data still read by a cursor
+func (n *memoryNode) unshare() {
+	if !n.shared {
+		return
+	}
+	data := make(map[string][]byte, len(n.data))
+	for key, value := range n.data {
+		data[key] = value
+	}
+	n.data = data
+	n.keys = slices.Clip(n.keys)
+	n.added = slices.Clone(n.added)
+	n.shared = false
+}
+
+// sortKeys merges the keys added since the last sort into the sorted keys, and drops the deleted ones
+func (n *memoryNode) sortKeys() {
+	if len(n.added) == 0 && !n.deleted {
+		return
+	}
+	sort.Strings(n.added)
+	keys := make([]string, 0, len(n.data))
+	i, j := 0, 0
+	for i < len(n.keys) || j < len(n.added) {
+		var key string
+		if j == len(n.added) || (i < len(n.keys) && n.keys[i] < n.added[j]) {
+			key = n.keys[i]
+			i++
+		} else {
+			key = n.added[j]
+			j++
+		}
+		// a key deleted then added again is in both lists
+		if len(keys) > 0 && keys[len(keys)-1] == key {
+			continue
+		}
+		if n.deleted {
+			if _, found := n.data[key]; !found {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	n.keys = keys
+	n.added = nil
+	n.deleted = false
+}
+
+// commit sorts the keys of all the nodes changed by the transaction: a committed node never changes again
+func (n *memoryNode) commit(txID uint) {
+	if n.txID != txID {
+		return
+	}
+	n.sortKeys()
+	n.shared = false
+	for _, bucket := range n.buckets {
+		bucket.commit(txID)
+	}
+}
+
+// stats returns the size of the keys and values of the node and its nested nodes
+func (n *memoryNode) stats() BucketStats {
+	stats := BucketStats{
+		Keys: len(n.data),
+	}
+	for key, value := range n.data {
+		stats.InUse += len(key) + len(value)
+	}
+	for name, bucket := range n.buckets {
+		stats.Buckets++
+		stats.InUse += len(name)
+		stats.Add(bucket.stats())
+	}
+	stats.Allocated = stats.InUse
+	return stats
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/gob"
+	"io"
+	"sort"
+)
+
+// memorySnapshot is the serialized form of a memoryNode
+type memorySnapshot struct {
+	Data     map[string][]byte
+	Buckets  map[string]*memorySnapshot
+	Sequence uint64
+}
+
+func newMemorySnapshot(node *memoryNode) *memorySnapshot {
+	snapshot := &memorySnapshot{
+		Data:     node.data,
+		Buckets:  make(map[string]*memorySnapshot, len(node.buckets)),
+		Sequence: node.sequence,
+	}
+	for name, bucket := range node.buckets {
+		snapshot.Buckets[name] = newMemorySnapshot(bucket)
+	}
+	return snapshot
+}
+
+// node returns a committed memoryNode from the snapshot
+func (s *memorySnapshot) node() *memoryNode {
+	node := newMemoryNode(0)
+	node.sequence = s.Sequence
+	for key, value := range s.Data {
+		node.data[key] = value
+		node.keys = append(node.keys, key)
+	}
+	sort.Strings(node.keys)
+	for name, bucket := range s.Buckets {
+		if bucket == nil {
+			bucket = &memorySnapshot{}
+		}
+		node.buckets[name] = bucket.node()
+	}
+	return node
+}
+
+// WriteTo writes a consistent snapshot of the committed buckets.
+// It doesn't wait for a running write transaction.
+func (s *MemoryStore) WriteTo(w io.Writer) (int64, error) {
+	// the committed tree is never modified
+	root := s.getRoot()
+	writer := &countingWriter{writer: w}
+	err := gob.NewEncoder(writer).Encode(newMemorySnapshot(root))
+	return writer.count, err
+}
+
+// LoadMemoryStore creates a new memory store from a snapshot saved by WriteTo
+func LoadMemoryStore(r io.Reader) (*MemoryStore, error) {
+	snapshot := &memorySnapshot{}
+	err := gob.NewDecoder(r).Decode(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	s := NewMemoryStore()
+	s.setRoot(snapshot.node())
+	return s, nil
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
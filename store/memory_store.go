@@ -1,35 +1,56 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
 
+// MemoryStore is a store keeping all the buckets in memory.
+//
+// Each transaction works on a snapshot of the tree of buckets taken when it begins:
+// committed buckets are never modified, a write transaction copies the buckets it changes
+// and the new tree replaces the previous one on commit (copy-on-write).
+// Read transactions are never blocked, and only one write transaction can run at a time.
 type MemoryStore struct {
-	buckets           map[string]map[string][]byte
-	bucketsMutex      *sync.Mutex
-	writeMutex        *sync.Mutex
+	root              *memoryNode
+	rootMutex         *sync.Mutex
+	writeLock         chan struct{}
 	transactions      map[uint]*MemoryTransaction
 	transactionsMutex *sync.Mutex
 	nextTransactionID uint
+	batcher           *batcher
 }
 
 func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		buckets:           make(map[string]map[string][]byte, 0),
-		bucketsMutex:      &sync.Mutex{},
-		writeMutex:        &sync.Mutex{},
+	s := &MemoryStore{
+		root:              newMemoryNode(0),
+		rootMutex:         &sync.Mutex{},
+		writeLock:         make(chan struct{}, 1),
 		transactions:      make(map[uint]*MemoryTransaction, 0),
 		transactionsMutex: &sync.Mutex{},
 	}
+	s.batcher = newBatcher(s.Update, DefaultMaxBatchSize, DefaultMaxBatchDelay)
+	return s
 }
 
 // Begin a transaction
 func (s *MemoryStore) Begin(writeable bool) (Transaction, error) {
-	return s.begin(writeable)
+	return s.begin(context.Background(), writeable)
 }
 
-func (s *MemoryStore) begin(writeable bool) (*MemoryTransaction, error) {
+// begin a transaction. A write transaction waits for the write lock until the context is done.
+func (s *MemoryStore) begin(ctx context.Context, writeable bool) (*MemoryTransaction, error) {
+	if writeable {
+		// Have a full lock on writeable buckets.
+		// It must be acquired before the transactions lock, which is needed to finish a transaction
+		select {
+		case s.writeLock <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
 	s.transactionsMutex.Lock()
 	defer s.transactionsMutex.Unlock()
 
@@ -49,49 +70,49 @@ func (s *MemoryStore) begin(writeable bool) (*MemoryTransaction, error) {
 	}
 
 	if writeable {
-		// Have a full lock on writeable buckets
-		s.writeMutex.Lock()
-
 		// Unlock at the end
 		close = func() {
-			s.writeMutex.Unlock()
+			<-s.writeLock
 			remove()
 		}
 	}
 
-	tx := newMemoryTransaction(s, writeable, close)
+	tx := newMemoryTransaction(transactionID, s, writeable, close)
 	s.transactions[transactionID] = tx
 	return tx, nil
 }
 
 // Close the store
 func (s *MemoryStore) Close() {
+	s.transactionsMutex.Lock()
+	defer s.transactionsMutex.Unlock()
+
 	if len(s.transactions) > 0 {
 		panic(fmt.Errorf("Store was closed with %d running transaction(s)", len(s.transactions)))
 	}
 }
 
 func (s *MemoryStore) Update(job func(transaction Transaction) error) error {
-	t, err := s.begin(true)
-	if err != nil {
-		return err
-	}
+	return s.UpdateContext(context.Background(), job)
+}
 
-	// Make sure the transaction rolls back in the event of a panic.
-	defer t.Rollback()
+func (s *MemoryStore) View(job func(transaction Transaction) error) error {
+	return s.ViewContext(context.Background(), job)
+}
 
-	// If an error is returned from the function then rollback and return error.
-	err = job(t)
-	if err != nil {
-		_ = t.Rollback()
-		return err
-	}
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit.
+// Waiting for another write transaction to finish stops when the context is done.
+func (s *MemoryStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.run(ctx, true, jobWithContext(ctx, job))
+}
 
-	return t.Commit()
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *MemoryStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.run(ctx, false, jobWithContext(ctx, job))
 }
 
-func (s *MemoryStore) View(job func(transaction Transaction) error) error {
-	t, err := s.begin(false)
+func (s *MemoryStore) run(ctx context.Context, writeable bool, job func(transaction Transaction) error) error {
+	t, err := s.begin(ctx, writeable)
 	if err != nil {
 		return err
 	}
@@ -109,23 +130,25 @@ func (s *MemoryStore) View(job func(transaction Transaction) error) error {
 	return t.Commit()
 }
 
-// saveBucket replaces the bucket data
-func (s *MemoryStore) saveBucket(bucket string, data map[string][]byte) {
-	s.bucketsMutex.Lock()
-	defer s.bucketsMutex.Unlock()
+// Batch runs the job in a write transaction shared with other concurrent calls
+func (s *MemoryStore) Batch(job func(transaction Transaction) error) error {
+	return s.batcher.Batch(job)
+}
+
+// getRoot returns the latest committed tree of buckets
+func (s *MemoryStore) getRoot() *memoryNode {
+	s.rootMutex.Lock()
+	defer s.rootMutex.Unlock()
 
-	s.buckets[bucket] = data
+	return s.root
 }
 
-// deleteBucket removes the bucket from memory. It does not fail if the bucket was not found
-func (s *MemoryStore) deleteBucket(bucket string) {
-	s.bucketsMutex.Lock()
-	defer s.bucketsMutex.Unlock()
+// setRoot replaces the committed tree of buckets
+func (s *MemoryStore) setRoot(root *memoryNode) {
+	s.rootMutex.Lock()
+	defer s.rootMutex.Unlock()
 
-	_, found := s.buckets[bucket]
-	if found {
-		delete(s.buckets, bucket)
-	}
+	s.root = root
 }
 
 // Test the interface
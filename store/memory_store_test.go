@@ -0,0 +1,412 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryReadTransactionIsNotSeeingLaterCommits(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("before"))
+	})
+	require.NoError(t, err)
+
+	reader, err := store.Begin(false)
+	require.NoError(t, err)
+	defer reader.Rollback()
+
+	// load the bucket before the commit
+	bucket, err := reader.GetBucket("bucket")
+	require.NoError(t, err)
+
+	err = store.Update(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		err = bucket.Put("key", []byte("after"))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket("new-bucket")
+		return err
+	})
+	require.NoError(t, err)
+
+	value, err := bucket.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("before"), value)
+
+	// load the bucket after the commit
+	bucket, err = reader.GetBucket("bucket")
+	require.NoError(t, err)
+	value, err = bucket.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("before"), value)
+
+	_, err = reader.GetBucket("new-bucket")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+}
+
+func TestMemoryWriteTransactionIsNotVisibleBeforeCommit(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	writer, err := store.Begin(true)
+	require.NoError(t, err)
+	defer writer.Rollback()
+
+	bucket, err := writer.CreateBucket("bucket")
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("value")))
+
+	err = store.View(func(tx Transaction) error {
+		_, err := tx.GetBucket("bucket")
+		assert.ErrorIs(t, err, ErrBucketNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Commit())
+
+	err = store.View(func(tx Transaction) error {
+		_, err := tx.GetBucket("bucket")
+		assert.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestMemoryConcurrentTransactions is best run with the race detector:
+// each writer moves some amount from one account to the other, so the total never changes.
+// Readers must never see a partial commit.
+func TestMemoryConcurrentTransactions(t *testing.T) {
+	t.Parallel()
+
+	const (
+		writers    = 8
+		readers    = 8
+		iterations = 200
+		total      = 1000
+	)
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		accounts, err := tx.CreateBucket("accounts")
+		if err != nil {
+			return err
+		}
+		err = accounts.Put("left", uint64Bytes(total))
+		if err != nil {
+			return err
+		}
+		return accounts.Put("right", uint64Bytes(0))
+	})
+	require.NoError(t, err)
+
+	errInconsistent := errors.New("inconsistent snapshot")
+	wg := new(sync.WaitGroup)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				err := store.Update(func(tx Transaction) error {
+					accounts, err := tx.GetBucket("accounts")
+					if err != nil {
+						return err
+					}
+					left, right, err := readAccounts(accounts)
+					if err != nil {
+						return err
+					}
+					amount := uint64(1)
+					if w%2 == 0 && left > 0 {
+						left, right = left-amount, right+amount
+					} else if right > 0 {
+						left, right = left+amount, right-amount
+					}
+					err = accounts.Put("left", uint64Bytes(left))
+					if err != nil {
+						return err
+					}
+					// nested bucket created in the middle of the transaction
+					history, err := accounts.CreateBucket("history")
+					if errors.Is(err, ErrBucketNameExists) {
+						history, err = accounts.GetBucket("history")
+					}
+					if err != nil {
+						return err
+					}
+					err = history.Put("last", []byte{byte(w)})
+					if err != nil {
+						return err
+					}
+					return accounts.Put("right", uint64Bytes(right))
+				})
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				err := store.View(func(tx Transaction) error {
+					accounts, err := tx.GetBucket("accounts")
+					if err != nil {
+						return err
+					}
+					left, right, err := readAccounts(accounts)
+					if err != nil {
+						return err
+					}
+					if left+right != total {
+						return errInconsistent
+					}
+					// reading again from the same transaction must give the same result
+					accounts, err = tx.GetBucket("accounts")
+					if err != nil {
+						return err
+					}
+					again, _, err := readAccounts(accounts)
+					if err != nil {
+						return err
+					}
+					if again != left {
+						return errInconsistent
+					}
+					return accounts.ForEach(func(key string, data []byte) error {
+						return nil
+					})
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func uint64Bytes(value uint64) []byte {
+	buffer := make([]byte, 8)
+	binary.BigEndian.PutUint64(buffer, value)
+	return buffer
+}
+
+func readAccounts(accounts Bucket) (left, right uint64, err error) {
+	data, err := accounts.Get("left")
+	if err != nil {
+		return 0, 0, err
+	}
+	left = binary.BigEndian.Uint64(data)
+	data, err = accounts.Get("right")
+	if err != nil {
+		return 0, 0, err
+	}
+	right = binary.BigEndian.Uint64(data)
+	return left, right, nil
+}
+
+func TestClosingStoreShouldPanicIfTransactionIsRunning(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	store.Begin(false)
+	assert.Panics(t, store.Close)
+}
+
+func TestMemorySnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put("key", []byte("value")); err != nil {
+			return err
+		}
+		if err := bucket.SetSequence(42); err != nil {
+			return err
+		}
+		nested, err := bucket.CreateBucket("nested")
+		if err != nil {
+			return err
+		}
+		if err := nested.Put("nested-key", []byte("nested-value")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket("empty")
+		return err
+	})
+	require.NoError(t, err)
+
+	// uncommitted changes are not in the snapshot
+	writer, err := store.Begin(true)
+	require.NoError(t, err)
+	bucket, err := writer.GetBucket("bucket")
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("uncommitted")))
+
+	buffer := &bytes.Buffer{}
+	_, err = store.WriteTo(buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Rollback())
+
+	loaded, err := LoadMemoryStore(buffer)
+	require.NoError(t, err)
+	defer loaded.Close()
+
+	err = loaded.View(func(tx Transaction) error {
+		_, err := tx.GetBucket("empty")
+		require.NoError(t, err)
+
+		bucket, err := tx.GetBucket("bucket")
+		require.NoError(t, err)
+		value, err := bucket.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+		assert.Equal(t, uint64(42), bucket.Sequence())
+
+		nested, err := bucket.GetBucket("nested")
+		require.NoError(t, err)
+		value, err = nested.Get("nested-key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("nested-value"), value)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the loaded store can be modified
+	err = loaded.Update(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("changed"))
+	})
+	require.NoError(t, err)
+}
+
+func TestMemoryCursorKeepsTheKeysSorted(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	keys := func(bucket Bucket) []string {
+		found := make([]string, 0)
+		err := bucket.ForEach(func(key string, _ []byte) error {
+			found = append(found, key)
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("bucket")
+		require.NoError(t, err)
+		for _, key := range []string{"d", "b", "e", "a"} {
+			require.NoError(t, bucket.Put(key, []byte(key)))
+		}
+		require.NoError(t, bucket.Delete("b"))
+		assert.Equal(t, []string{"a", "d", "e"}, keys(bucket))
+
+		// the cursor doesn't see the changes made after it was created
+		cursor := bucket.Cursor()
+		require.NoError(t, bucket.Put("c", []byte("c")))
+		require.NoError(t, bucket.Delete("e"))
+		require.NoError(t, bucket.Put("a", []byte("changed")))
+		key, value := cursor.First()
+		assert.Equal(t, "a", key)
+		assert.Equal(t, []byte("a"), value)
+		key, _ = cursor.Last()
+		assert.Equal(t, "e", key)
+
+		// a key deleted then added again is only found once
+		require.NoError(t, bucket.Delete("d"))
+		require.NoError(t, bucket.Put("d", []byte("again")))
+		assert.Equal(t, []string{"a", "c", "d"}, keys(bucket))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = store.Update(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("bucket")
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("b", []byte("b")))
+		return bucket.Put("z", []byte("z"))
+	})
+	require.NoError(t, err)
+
+	err = store.View(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("bucket")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d", "z"}, keys(bucket))
+
+		key, value := bucket.Cursor().Seek("bb")
+		assert.Equal(t, "c", key)
+		assert.Equal(t, []byte("c"), value)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestLoadMemoryStoreFromInvalidData(t *testing.T) {
+	t.Parallel()
+
+	_, err := LoadMemoryStore(bytes.NewReader([]byte("not a snapshot")))
+	assert.Error(t, err)
+}
+
+func TestMemoryUpdateContextStopsWaitingForWriteLock(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	tx, err := store.Begin(true)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = store.UpdateContext(ctx, func(transaction Transaction) error {
+		t.Error("the job should not run")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the lock is released with the running transaction
+	require.NoError(t, tx.Rollback())
+	err = store.UpdateContext(context.Background(), func(transaction Transaction) error {
+		_, err := transaction.CreateBucket("bucket")
+		return err
+	})
+	assert.NoError(t, err)
+}
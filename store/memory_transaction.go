@@ -1,29 +1,31 @@
 package store
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 type MemoryTransaction struct {
-	store          *MemoryStore
-	readBuckets    map[string]map[string][]byte
-	writeBuckets   map[string]*MemoryBucket
-	deletedBuckets map[string]bool
-	mutex          *sync.Mutex
-	writable       bool
-	close          func()
-	closing        *sync.Mutex
-	closed         bool
-}
-
-func newMemoryTransaction(store *MemoryStore, writable bool, close func()) *MemoryTransaction {
+	id       uint
+	store    *MemoryStore
+	root     *memoryNode
+	mutex    *sync.Mutex
+	writable bool
+	close    func()
+	closing  *sync.Mutex
+	closed   bool
+}
+
+func newMemoryTransaction(id uint, store *MemoryStore, writable bool, close func()) *MemoryTransaction {
 	return &MemoryTransaction{
-		store:          store,
-		readBuckets:    store.buckets,
-		writeBuckets:   make(map[string]*MemoryBucket, 0),
-		deletedBuckets: make(map[string]bool, 0),
-		mutex:          &sync.Mutex{},
-		writable:       writable,
-		close:          close,
-		closing:        &sync.Mutex{},
+		id: id,
+		// snapshot of the committed tree: it will never change during the lifetime of the transaction
+		root:     store.getRoot(),
+		store:    store,
+		mutex:    &sync.Mutex{},
+		writable: writable,
+		close:    close,
+		closing:  &sync.Mutex{},
 	}
 }
 
@@ -52,17 +54,10 @@ func (t *MemoryTransaction) Commit() error {
 	}
 
 	if t.writable {
-		// Deleted buckets
-		for name, deleted := range t.deletedBuckets {
-			if deleted {
-				t.store.deleteBucket(name)
-			}
-		}
-
-		// Updated buckets
-		for name, updated := range t.writeBuckets {
-			t.store.saveBucket(name, updated.getData())
-		}
+		t.mutex.Lock()
+		t.root.commit(t.id)
+		t.store.setRoot(t.root)
+		t.mutex.Unlock()
 	}
 	t.close()
 	t.closed = true
@@ -71,86 +66,153 @@ func (t *MemoryTransaction) Commit() error {
 
 // CreateBucket returns a new bucket. Returns an error if the name already exists
 func (t *MemoryTransaction) CreateBucket(bucket string) (Bucket, error) {
+	return t.createBucket(nil, bucket)
+}
+
+// GetBucket returns a bucket from its name.
+func (t *MemoryTransaction) GetBucket(bucket string) (Bucket, error) {
+	return t.getBucket(nil, bucket)
+}
+
+// DeleteBucket removes the bucket and all its nested buckets from memory
+func (t *MemoryTransaction) DeleteBucket(bucket string) error {
+	return t.deleteBucket(nil, bucket)
+}
+
+// ForEachBucket runs the function on the name of every top level bucket
+func (t *MemoryTransaction) ForEachBucket(fn func(name string) error) error {
+	return t.forEachBucket(nil, fn)
+}
+
+// createBucket creates a new bucket inside the bucket at the parent path
+func (t *MemoryTransaction) createBucket(parent []string, name string) (Bucket, error) {
 	if !t.writable {
 		return nil, ErrTransactionReadonly
 	}
-	if bucket == "" {
+	if name == "" {
 		return nil, ErrBucketNoName
 	}
 
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	// Check for updated buckets
-	if _, found := t.writeBuckets[bucket]; found {
-		return nil, ErrBucketNameExists
+	node, err := t.writableNode(parent)
+	if err != nil {
+		return nil, err
 	}
-
-	// Check for untouched existing bucket
-	if _, found := t.readBuckets[bucket]; found {
+	if _, found := node.buckets[name]; found {
 		return nil, ErrBucketNameExists
 	}
-
-	// Remove from deleted bucket, in case it's there
-	if _, found := t.deletedBuckets[bucket]; found {
-		delete(t.deletedBuckets, bucket)
+	if _, found := node.data[name]; found {
+		return nil, ErrIncompatibleValue
 	}
-
-	b := newMemoryBucket(bucket, make(map[string][]byte), t)
-	t.writeBuckets[bucket] = b
-	return b, nil
+	node.buckets[name] = newMemoryNode(t.id)
+	return newMemoryBucket(childPath(parent, name), t), nil
 }
 
-// GetBucket returns a bucket from its name.
-func (t *MemoryTransaction) GetBucket(bucket string) (Bucket, error) {
-	if bucket == "" {
+// getBucket returns the bucket from inside the bucket at the parent path
+func (t *MemoryTransaction) getBucket(parent []string, name string) (Bucket, error) {
+	if name == "" {
 		return nil, ErrBucketNoName
 	}
 
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	// Check for deleted bucket first
-	if deleted, ok := t.deletedBuckets[bucket]; ok && deleted {
+	path := childPath(parent, name)
+	if t.node(path) == nil {
 		return nil, ErrBucketNotFound
 	}
+	return newMemoryBucket(path, t), nil
+}
 
-	// Check for updated buckets
-	if b, ok := t.writeBuckets[bucket]; ok {
-		return b, nil
+// deleteBucket removes the bucket (and its nested buckets) from the bucket at the parent path
+func (t *MemoryTransaction) deleteBucket(parent []string, name string) error {
+	if !t.writable {
+		return ErrTransactionReadonly
+	}
+	if name == "" {
+		return ErrBucketNoName
 	}
 
-	// Check for untouched existing bucket
-	if b, ok := t.readBuckets[bucket]; ok {
-		return newMemoryBucket(bucket, copyKeyValues(b), t), nil
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	node, err := t.writableNode(parent)
+	if err != nil {
+		return err
+	}
+	if _, found := node.buckets[name]; !found {
+		if _, found := node.data[name]; found {
+			return ErrIncompatibleValue
+		}
+		return ErrBucketNotFound
 	}
-	return nil, ErrBucketNotFound
+	delete(node.buckets, name)
+	return nil
 }
 
-// DeleteBucket removes the bucket from memory
-func (t *MemoryTransaction) DeleteBucket(bucket string) error {
-	if !t.writable {
-		return ErrTransactionReadonly
+// forEachBucket runs the function on a sorted snapshot of the bucket names at the path
+func (t *MemoryTransaction) forEachBucket(path []string, fn func(name string) error) error {
+	t.mutex.Lock()
+	node := t.node(path)
+	if node == nil {
+		t.mutex.Unlock()
+		return ErrBucketNotFound
 	}
-	if bucket == "" {
-		return ErrBucketNoName
+	names := make([]string, 0, len(node.buckets))
+	for name := range node.buckets {
+		names = append(names, name)
 	}
+	t.mutex.Unlock()
 
-	t.deletedBuckets[bucket] = true
-	if _, ok := t.writeBuckets[bucket]; ok {
-		delete(t.writeBuckets, bucket)
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func copyKeyValues(b map[string][]byte) map[string][]byte {
-	copiedMap := make(map[string][]byte)
-	for key, value := range b {
-		copiedValue := make([]byte, len(value))
-		copy(copiedValue, value)
-		copiedMap[key] = copiedValue
+// node returns the node at the path, or nil if it doesn't exist
+func (t *MemoryTransaction) node(path []string) *memoryNode {
+	node := t.root
+	for _, name := range path {
+		node = node.buckets[name]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// writableNode returns the node at the path, making a copy of all the nodes
+// on the way that are not owned by the transaction yet
+func (t *MemoryTransaction) writableNode(path []string) (*memoryNode, error) {
+	if t.root.txID != t.id {
+		t.root = t.root.clone(t.id)
+	}
+	node := t.root
+	for _, name := range path {
+		child, found := node.buckets[name]
+		if !found {
+			return nil, ErrBucketNotFound
+		}
+		if child.txID != t.id {
+			child = child.clone(t.id)
+			node.buckets[name] = child
+		}
+		node = child
 	}
-	return copiedMap
+	return node, nil
+}
+
+// childPath returns a new path to the child bucket
+func childPath(parent []string, name string) []string {
+	path := make([]string, len(parent), len(parent)+1)
+	copy(path, parent)
+	return append(path, name)
 }
 
 var (
@@ -0,0 +1,504 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrCommitVetoed = errors.New("Commit vetoed")
+)
+
+// ChangeType is the kind of modification made in a transaction
+type ChangeType int
+
+const (
+	ChangePut ChangeType = iota
+	ChangeDelete
+	ChangeCreateBucket
+	ChangeDeleteBucket
+	ChangeSequence
+)
+
+// Change is a modification made in a transaction.
+// For the bucket changes, the path includes the bucket created or deleted and the key is blank.
+// The keys inside a deleted bucket are not reported individually.
+// For a sequence change, the path is the bucket and the values are the sequence in big-endian order.
+type Change struct {
+	Type ChangeType
+	// Path of the bucket, from the top level bucket
+	Path []string
+	Key  string
+	// Old is the value before the transaction, nil if the key didn't exist
+	Old []byte
+	// New is the value after the transaction, nil if the key was deleted
+	New []byte
+}
+
+// BeforeCommitHook receives the changes of a transaction just before it's committed.
+// The hook can save more data in the transaction: these writes are not reported as changes.
+// Returning an error vetoes the commit and rolls back the transaction.
+type BeforeCommitHook func(transaction Transaction, changes []Change) error
+
+// AfterCommitHook receives the changes of a transaction once it's committed
+type AfterCommitHook func(changes []Change)
+
+// ObservableOptions configures what is recorded for the hooks
+type ObservableOptions struct {
+	// BucketsOnly records the first change of each bucket, without the values:
+	// enough for hooks only looking at which buckets have changed.
+	// Writing a key then costs no more than in the underlying store, since the old value is never read.
+	// A Delete is recorded even if the key doesn't exist.
+	BucketsOnly bool
+}
+
+// ObservableStore is a Store decorator recording the changes made in each write transaction,
+// and delivering them to the hooks before and after the commit.
+// The changes are only recorded when at least one hook is registered.
+type ObservableStore struct {
+	store   Store
+	options ObservableOptions
+	mutex   *sync.RWMutex
+	before  []BeforeCommitHook
+	after   []AfterCommitHook
+}
+
+// NewObservableStore wraps the store, recording all the changes with their values
+func NewObservableStore(store Store) *ObservableStore {
+	return NewObservableStoreWithOptions(store, ObservableOptions{})
+}
+
+// NewObservableStoreWithOptions wraps the store, recording the changes as configured by the options
+func NewObservableStoreWithOptions(store Store, options ObservableOptions) *ObservableStore {
+	return &ObservableStore{
+		store:   store,
+		options: options,
+		mutex:   &sync.RWMutex{},
+	}
+}
+
+// OnBeforeCommit registers a hook called before each commit of a transaction with changes
+func (s *ObservableStore) OnBeforeCommit(hook BeforeCommitHook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.before = append(s.before, hook)
+}
+
+// OnAfterCommit registers a hook called after each commit of a transaction with changes
+func (s *ObservableStore) OnAfterCommit(hook AfterCommitHook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.after = append(s.after, hook)
+}
+
+// Unwrap returns the underlying store
+func (s *ObservableStore) Unwrap() Store {
+	return s.store
+}
+
+// Begin a transaction
+func (s *ObservableStore) Begin(writable bool) (Transaction, error) {
+	tx, err := s.store.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return s.wrap(tx), nil
+}
+
+// Close the underlying store
+func (s *ObservableStore) Close() {
+	s.store.Close()
+}
+
+// Update run the job in a transaction
+func (s *ObservableStore) Update(job func(transaction Transaction) error) error {
+	return s.UpdateContext(context.Background(), job)
+}
+
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit.
+// The hooks are not called when the context is done before the job starts.
+func (s *ObservableStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	var tx *ObservableTransaction
+	err := s.store.UpdateContext(ctx, func(transaction Transaction) error {
+		tx = s.wrap(transaction)
+		err := job(tx)
+		if err != nil {
+			return err
+		}
+		return tx.beforeCommit()
+	})
+	if err != nil {
+		return err
+	}
+	tx.afterCommit()
+	return nil
+}
+
+// View run the job in a read-only transaction
+func (s *ObservableStore) View(job func(transaction Transaction) error) error {
+	return s.store.View(func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *ObservableStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.store.ViewContext(ctx, func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// Batch runs the job in a write transaction shared with other concurrent calls.
+// The hooks receive the changes of each job separately.
+func (s *ObservableStore) Batch(job func(transaction Transaction) error) error {
+	var tx *ObservableTransaction
+	err := s.store.Batch(func(transaction Transaction) error {
+		// the job can run more than once: only the changes of the last run are committed
+		tx = s.wrap(transaction)
+		err := job(tx)
+		if err != nil {
+			return err
+		}
+		return tx.beforeCommit()
+	})
+	if err != nil {
+		return err
+	}
+	tx.afterCommit()
+	return nil
+}
+
+// WriteTo writes a snapshot of the underlying store
+func (s *ObservableStore) WriteTo(w io.Writer) (int64, error) {
+	return s.store.WriteTo(w)
+}
+
+func (s *ObservableStore) wrap(transaction Transaction) *ObservableTransaction {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tx := &ObservableTransaction{
+		Transaction: transaction,
+		mutex:       &sync.Mutex{},
+		before:      s.before,
+		after:       s.after,
+	}
+	tx.recording = transaction.IsWritable() && (len(tx.before) > 0 || len(tx.after) > 0)
+	tx.values = tx.recording && !s.options.BucketsOnly
+	if tx.recording {
+		tx.index = make(map[string]int)
+	}
+	return tx
+}
+
+// ObservableTransaction is a transaction recording its changes
+type ObservableTransaction struct {
+	Transaction
+	mutex     *sync.Mutex
+	before    []BeforeCommitHook
+	after     []AfterCommitHook
+	recording bool
+	// values is true when the changes are recorded with their values
+	values  bool
+	changes []Change
+	index   map[string]int
+}
+
+// Changes returns the changes recorded so far
+func (t *ObservableTransaction) Changes() []Change {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]Change(nil), t.changes...)
+}
+
+// Commit runs the hooks around the commit of the underlying transaction.
+// The transaction is rolled back when a hook vetoes the commit.
+func (t *ObservableTransaction) Commit() error {
+	if err := t.beforeCommit(); err != nil {
+		_ = t.Transaction.Rollback()
+		return err
+	}
+	if err := t.Transaction.Commit(); err != nil {
+		return err
+	}
+	t.afterCommit()
+	return nil
+}
+
+// CreateBucket returns a new bucket. Returns an error if the name already exists
+func (t *ObservableTransaction) CreateBucket(name string) (Bucket, error) {
+	bucket, err := t.Transaction.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	path := []string{name}
+	t.record(Change{Type: ChangeCreateBucket, Path: path})
+	return newObservableBucket(bucket, path, t), nil
+}
+
+// GetBucket returns a bucket from its name.
+func (t *ObservableTransaction) GetBucket(name string) (Bucket, error) {
+	bucket, err := t.Transaction.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newObservableBucket(bucket, []string{name}, t), nil
+}
+
+// DeleteBucket removes the bucket and all its nested buckets
+func (t *ObservableTransaction) DeleteBucket(name string) error {
+	err := t.Transaction.DeleteBucket(name)
+	if err != nil {
+		return err
+	}
+	t.record(Change{Type: ChangeDeleteBucket, Path: []string{name}})
+	return nil
+}
+
+// beforeCommit runs the hooks on the changes, and returns the first veto
+func (t *ObservableTransaction) beforeCommit() error {
+	changes := t.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, hook := range t.before {
+		if err := hook(t.Transaction, changes); err != nil {
+			return fmt.Errorf("%w: %w", ErrCommitVetoed, err)
+		}
+	}
+	return nil
+}
+
+// afterCommit runs the hooks on the committed changes
+func (t *ObservableTransaction) afterCommit() {
+	changes := t.Changes()
+	if len(changes) == 0 {
+		return
+	}
+	for _, hook := range t.after {
+		hook(changes)
+	}
+}
+
+// record adds the change to the change set. Successive changes on the same key, or on the same sequence, are merged.
+// The changes are never merged across a bucket change, so the change set can be replayed in order.
+func (t *ObservableTransaction) record(change Change) {
+	if !t.recording {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.values {
+		// only the first change of each bucket
+		id := strings.Join(change.Path, "\x00")
+		if _, found := t.index[id]; !found {
+			t.index[id] = len(t.changes)
+			t.changes = append(t.changes, change)
+		}
+		return
+	}
+	id := changeID(change)
+	if id == "" {
+		// bucket changes are never merged
+		t.changes = append(t.changes, change)
+		clear(t.index)
+		return
+	}
+	position, found := t.index[id]
+	if !found {
+		t.index[id] = len(t.changes)
+		t.changes = append(t.changes, change)
+		return
+	}
+	// keep the value from before the transaction
+	change.Old = t.changes[position].Old
+	if change.Type == ChangeDelete && change.Old == nil {
+		// the key was created and deleted in the same transaction
+		t.changes = append(t.changes[:position], t.changes[position+1:]...)
+		t.reindex()
+		return
+	}
+	t.changes[position] = change
+}
+
+// reindex rebuilds the position of each key in the change set, after the last bucket change
+func (t *ObservableTransaction) reindex() {
+	clear(t.index)
+	for position, change := range t.changes {
+		id := changeID(change)
+		if id == "" {
+			clear(t.index)
+			continue
+		}
+		t.index[id] = position
+	}
+}
+
+// changeID returns the identifier used to merge the changes, or a blank string for the bucket changes
+func changeID(change Change) string {
+	switch change.Type {
+	case ChangePut, ChangeDelete:
+		return strings.Join(change.Path, "\x00") + "\x00" + change.Key
+	case ChangeSequence:
+		return "\x01" + strings.Join(change.Path, "\x00")
+	default:
+		return ""
+	}
+}
+
+// ObservableBucket is a bucket recording the changes into its transaction
+type ObservableBucket struct {
+	Bucket
+	path []string
+	tx   *ObservableTransaction
+}
+
+func newObservableBucket(bucket Bucket, path []string, tx *ObservableTransaction) *ObservableBucket {
+	return &ObservableBucket{
+		Bucket: bucket,
+		path:   path,
+		tx:     tx,
+	}
+}
+
+func (b *ObservableBucket) Put(key string, data []byte) error {
+	var old []byte
+	if b.tx.values {
+		old = b.get(key)
+	}
+	err := b.Bucket.Put(key, data)
+	if err != nil {
+		return err
+	}
+	if b.tx.values {
+		b.tx.record(Change{Type: ChangePut, Path: b.path, Key: key, Old: old, New: copyBytes(data)})
+	} else if b.tx.recording {
+		b.tx.record(Change{Type: ChangePut, Path: b.path, Key: key})
+	}
+	return nil
+}
+
+func (b *ObservableBucket) Delete(key string) error {
+	var old []byte
+	if b.tx.values {
+		old = b.get(key)
+	}
+	err := b.Bucket.Delete(key)
+	if err != nil {
+		return err
+	}
+	if b.tx.values && old != nil {
+		b.tx.record(Change{Type: ChangeDelete, Path: b.path, Key: key, Old: old})
+	} else if !b.tx.values && b.tx.recording {
+		b.tx.record(Change{Type: ChangeDelete, Path: b.path, Key: key})
+	}
+	return nil
+}
+
+func (b *ObservableBucket) NextSequence() (uint64, error) {
+	var old uint64
+	if b.tx.recording {
+		old = b.Bucket.Sequence()
+	}
+	sequence, err := b.Bucket.NextSequence()
+	if err != nil {
+		return sequence, err
+	}
+	b.recordSequence(old, sequence)
+	return sequence, nil
+}
+
+func (b *ObservableBucket) SetSequence(value uint64) error {
+	var old uint64
+	if b.tx.recording {
+		old = b.Bucket.Sequence()
+	}
+	err := b.Bucket.SetSequence(value)
+	if err != nil {
+		return err
+	}
+	b.recordSequence(old, value)
+	return nil
+}
+
+func (b *ObservableBucket) CreateBucket(name string) (Bucket, error) {
+	bucket, err := b.Bucket.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	path := childPath(b.path, name)
+	b.tx.record(Change{Type: ChangeCreateBucket, Path: path})
+	return newObservableBucket(bucket, path, b.tx), nil
+}
+
+func (b *ObservableBucket) GetBucket(name string) (Bucket, error) {
+	bucket, err := b.Bucket.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newObservableBucket(bucket, childPath(b.path, name), b.tx), nil
+}
+
+func (b *ObservableBucket) DeleteBucket(name string) error {
+	err := b.Bucket.DeleteBucket(name)
+	if err != nil {
+		return err
+	}
+	b.tx.record(Change{Type: ChangeDeleteBucket, Path: childPath(b.path, name)})
+	return nil
+}
+
+// recordSequence records the change of the sequence of the bucket
+func (b *ObservableBucket) recordSequence(old, value uint64) {
+	if !b.tx.recording {
+		return
+	}
+	if !b.tx.values {
+		b.tx.record(Change{Type: ChangeSequence, Path: b.path})
+		return
+	}
+	b.tx.record(Change{
+		Type: ChangeSequence,
+		Path: b.path,
+		Old:  binary.BigEndian.AppendUint64(nil, old),
+		New:  binary.BigEndian.AppendUint64(nil, value),
+	})
+}
+
+// get returns a copy of the current value of the key, or nil if it doesn't exist
+// get returns a copy of the value, or nil if the key doesn't exist. An empty value is never nil.
+func (b *ObservableBucket) get(key string) []byte {
+	data, err := b.Bucket.Get(key)
+	if err != nil {
+		return nil
+	}
+	if data == nil {
+		return []byte{}
+	}
+	return copyBytes(data)
+}
+
+// copyBytes returns a copy of the data: values returned by a bucket are only valid during the transaction
+func copyBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	copied := make([]byte, len(data))
+	copy(copied, data)
+	return copied
+}
+
+var (
+	_ Store       = &ObservableStore{}
+	_ Transaction = &ObservableTransaction{}
+	_ Bucket      = &ObservableBucket{}
+)
@@ -0,0 +1,422 @@
+package store_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newObservedStore returns an observable memory store recording all the committed changes
+func newObservedStore(t *testing.T) (*store.ObservableStore, func() []store.Change) {
+	t.Helper()
+
+	observable := store.NewObservableStore(store.NewMemoryStore())
+	mutex := &sync.Mutex{}
+	committed := make([]store.Change, 0)
+	observable.OnAfterCommit(func(changes []store.Change) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		committed = append(committed, changes...)
+	})
+	return observable, func() []store.Change {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return committed
+	}
+}
+
+func TestObservableStore(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		observable, _ := newObservedStore(t)
+		observable.OnBeforeCommit(func(transaction store.Transaction, changes []store.Change) error {
+			return nil
+		})
+		return observable
+	})
+}
+
+func TestObservableBoltStore(t *testing.T) {
+	t.Parallel()
+
+	dir := boltTestPath(t)
+	var counter atomic.Int32
+	storetest.Run(t, func() store.Store {
+		database := filepath.Join(dir, fmt.Sprintf("observable_test_%d.db", counter.Add(1)))
+		boltStore, err := store.NewBoltStore(database)
+		require.NoError(t, err)
+		observable := store.NewObservableStore(boltStore)
+		observable.OnAfterCommit(func(changes []store.Change) {})
+		return observable
+	})
+}
+
+func TestObservableStoreChanges(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	err := observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put("key1", []byte("value1")); err != nil {
+			return err
+		}
+		return bucket.Put("key2", []byte("value2"))
+	})
+	require.NoError(t, err)
+
+	err = observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		nested, err := bucket.CreateBucket("nested")
+		if err != nil {
+			return err
+		}
+		if err := nested.Put("key", []byte("nested")); err != nil {
+			return err
+		}
+		// merged into one change
+		if err := bucket.Put("key1", []byte("changed")); err != nil {
+			return err
+		}
+		if err := bucket.Put("key1", []byte("changed again")); err != nil {
+			return err
+		}
+		if err := bucket.Delete("key2"); err != nil {
+			return err
+		}
+		// no change at all
+		if err := bucket.Put("temporary", []byte("value")); err != nil {
+			return err
+		}
+		if err := bucket.Delete("temporary"); err != nil {
+			return err
+		}
+		return bucket.Delete("missing")
+	})
+	require.NoError(t, err)
+
+	expected := []store.Change{
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket"}},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key1", New: []byte("value1")},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key2", New: []byte("value2")},
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket", "nested"}},
+		{Type: store.ChangePut, Path: []string{"bucket", "nested"}, Key: "key", New: []byte("nested")},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key1", Old: []byte("value1"), New: []byte("changed again")},
+		{Type: store.ChangeDelete, Path: []string{"bucket"}, Key: "key2", Old: []byte("value2")},
+	}
+	assert.Equal(t, expected, committed())
+}
+
+func TestObservableStoreNilValues(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	err := observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("empty", nil)
+	})
+	require.NoError(t, err)
+
+	err = observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		// a new key with a nil value still exists after the transaction
+		if err := bucket.Put("key", []byte("value")); err != nil {
+			return err
+		}
+		if err := bucket.Put("key", nil); err != nil {
+			return err
+		}
+		// an existing key with an empty value is deleted
+		return bucket.Delete("empty")
+	})
+	require.NoError(t, err)
+
+	expected := []store.Change{
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket"}},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "empty"},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key"},
+		{Type: store.ChangeDelete, Path: []string{"bucket"}, Key: "empty", Old: []byte{}},
+	}
+	assert.Equal(t, expected, committed())
+}
+
+func TestObservableStoreVeto(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	forbidden := errors.New("forbidden key")
+	observable.OnBeforeCommit(func(transaction store.Transaction, changes []store.Change) error {
+		for _, change := range changes {
+			if change.Key == "forbidden" {
+				return forbidden
+			}
+		}
+		return nil
+	})
+	createBucket := func(transaction store.Transaction) error {
+		_, err := transaction.CreateBucket("bucket")
+		return err
+	}
+	putForbidden := func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("forbidden", []byte("value"))
+	}
+	require.NoError(t, observable.Update(createBucket))
+	assert.Len(t, committed(), 1)
+
+	err := observable.Update(putForbidden)
+	assert.ErrorIs(t, err, store.ErrCommitVetoed)
+	assert.ErrorIs(t, err, forbidden)
+
+	err = observable.Batch(putForbidden)
+	assert.ErrorIs(t, err, store.ErrCommitVetoed)
+
+	tx, err := observable.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, putForbidden(tx))
+	err = tx.Commit()
+	assert.ErrorIs(t, err, store.ErrCommitVetoed)
+
+	// nothing was committed
+	assert.Len(t, committed(), 1)
+	err = observable.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		require.NoError(t, err)
+		_, err = bucket.Get("forbidden")
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func TestObservableStoreHookCanWrite(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	// count the changes into another bucket
+	observable.OnBeforeCommit(func(transaction store.Transaction, changes []store.Change) error {
+		counters, err := transaction.GetBucket("counters")
+		if err != nil {
+			return nil
+		}
+		_, err = counters.NextSequence()
+		return err
+	})
+	err := observable.Update(func(transaction store.Transaction) error {
+		_, err := transaction.CreateBucket("counters")
+		return err
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = observable.Update(func(transaction store.Transaction) error {
+			counters, err := transaction.GetBucket("counters")
+			if err != nil {
+				return err
+			}
+			return counters.Put("key", []byte{byte(i)})
+		})
+		require.NoError(t, err)
+	}
+
+	err = observable.View(func(transaction store.Transaction) error {
+		counters, err := transaction.GetBucket("counters")
+		require.NoError(t, err)
+		// the transaction creating the bucket is counted too
+		assert.Equal(t, uint64(4), counters.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+	// the writes from the hook are not reported
+	assert.Len(t, committed(), 4)
+}
+
+func TestObservableStoreRollback(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	tx, err := observable.Begin(true)
+	require.NoError(t, err)
+	_, err = tx.CreateBucket("bucket")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	err = observable.Update(func(transaction store.Transaction) error {
+		_, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	assert.Empty(t, committed())
+}
+
+func TestObservableStoreBatch(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	require.NoError(t, observable.Update(func(transaction store.Transaction) error {
+		_, err := transaction.CreateBucket("bucket")
+		return err
+	}))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := observable.Batch(func(transaction store.Transaction) error {
+				bucket, err := transaction.GetBucket("bucket")
+				if err != nil {
+					return err
+				}
+				return bucket.Put(string(rune('a'+i)), []byte{byte(i)})
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, committed(), 11)
+}
+
+func TestObservableStoreSequenceChanges(t *testing.T) {
+	t.Parallel()
+
+	observable, committed := newObservedStore(t)
+	defer observable.Close()
+
+	err := observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put("key", []byte("value1")); err != nil {
+			return err
+		}
+		// merged into one change
+		for i := 0; i < 3; i++ {
+			if _, err := bucket.NextSequence(); err != nil {
+				return err
+			}
+		}
+		// the changes after a bucket change are not merged with the changes before
+		if _, err := bucket.CreateBucket("nested"); err != nil {
+			return err
+		}
+		if err := bucket.Put("key", []byte("value2")); err != nil {
+			return err
+		}
+		return bucket.SetSequence(10)
+	})
+	require.NoError(t, err)
+
+	sequence := func(value uint64) []byte {
+		return binary.BigEndian.AppendUint64(nil, value)
+	}
+	expected := []store.Change{
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket"}},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key", New: []byte("value1")},
+		{Type: store.ChangeSequence, Path: []string{"bucket"}, Old: sequence(0), New: sequence(3)},
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket", "nested"}},
+		{Type: store.ChangePut, Path: []string{"bucket"}, Key: "key", Old: []byte("value1"), New: []byte("value2")},
+		{Type: store.ChangeSequence, Path: []string{"bucket"}, Old: sequence(3), New: sequence(10)},
+	}
+	assert.Equal(t, expected, committed())
+}
+
+func TestObservableStoreBucketsOnly(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func() store.Store {
+		observable := store.NewObservableStoreWithOptions(store.NewMemoryStore(), store.ObservableOptions{BucketsOnly: true})
+		observable.OnAfterCommit(func(changes []store.Change) {})
+		return observable
+	})
+
+	observable := store.NewObservableStoreWithOptions(store.NewMemoryStore(), store.ObservableOptions{BucketsOnly: true})
+	defer observable.Close()
+	var committed []store.Change
+	observable.OnAfterCommit(func(changes []store.Change) {
+		committed = changes
+	})
+
+	err := observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket("bucket")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			if err := bucket.Put(fmt.Sprintf("key%d", i), []byte("value")); err != nil {
+				return err
+			}
+		}
+		nested, err := bucket.CreateBucket("nested")
+		if err != nil {
+			return err
+		}
+		if _, err := nested.NextSequence(); err != nil {
+			return err
+		}
+		return nested.Put("key", []byte("nested"))
+	})
+	require.NoError(t, err)
+
+	expected := []store.Change{
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket"}},
+		{Type: store.ChangeCreateBucket, Path: []string{"bucket", "nested"}},
+	}
+	assert.Equal(t, expected, committed)
+
+	err = observable.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket("bucket")
+		if err != nil {
+			return err
+		}
+		if err := bucket.Delete("key0"); err != nil {
+			return err
+		}
+		return bucket.Put("key1", []byte("changed"))
+	})
+	require.NoError(t, err)
+
+	expected = []store.Change{
+		{Type: store.ChangeDelete, Path: []string{"bucket"}, Key: "key0"},
+	}
+	assert.Equal(t, expected, committed)
+}
@@ -1,302 +1,187 @@
-package store
+package store_test
 
 import (
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/creativeprojects/catalogue/store/storetest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	UnknownBucketKey = "unknown-bucket"
-)
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
 
-type testStoreData struct {
-	name  string
-	store Store
+	storetest.Run(t, func() store.Store {
+		return store.NewMemoryStore()
+	})
 }
 
-func TestClosingStoreShouldPanicIfTransactionIsRunning(t *testing.T) {
+func TestBoltStore(t *testing.T) {
 	t.Parallel()
 
-	store := NewMemoryStore()
-	store.Begin(false)
-	assert.Panics(t, store.Close)
+	// Only run the bolt store if the database path is set in the environment
+	dir := boltTestPath(t)
+
+	var counter atomic.Int32
+	storetest.Run(t, func() store.Store {
+		database := filepath.Join(dir, fmt.Sprintf("store_test_%d.db", counter.Add(1)))
+		boltStore, err := store.NewBoltStore(database)
+		require.NoError(t, err)
+		return boltStore
+	})
 }
 
-func TestStores(t *testing.T) {
+func TestLogStore(t *testing.T) {
 	t.Parallel()
 
-	// Prepare stores
-	testStores := make([]testStoreData, 0, 2)
-	testStores = append(testStores, testStoreData{"InMemory", NewMemoryStore()})
+	dir := boltTestPath(t)
+	var counter atomic.Int32
+	storetest.Run(t, func() store.Store {
+		logStore, err := store.NewLogStore(filepath.Join(dir, fmt.Sprintf("log_%d", counter.Add(1))))
+		require.NoError(t, err)
+		return logStore
+	})
+}
+
+// boltTestPath returns a temporary directory for the bolt tests, or skips the test when DB_TEST_PATH is not set
+func boltTestPath(t *testing.T) string {
+	t.Helper()
 
-	// Add bolt store if the database path is set in the environment
 	testPath := os.Getenv("DB_TEST_PATH")
-	if testPath != "" {
-		database := path.Join(testPath, "store_test.db")
-		boltStore, err := NewBoltStore(database)
-		if err == nil {
-			t.Cleanup(func() {
-				boltStore.Close()
-				_ = os.Remove(database)
-			})
-			testStores = append(testStores, testStoreData{"BoltDB", boltStore})
-		} else {
-			t.Logf("BoltDB tests cannot run: %s", err)
-		}
+	if testPath == "" {
+		t.Skip("DB_TEST_PATH is not set")
 	}
+	dir, err := os.MkdirTemp(testPath, "store_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
 
-	for _, testData := range testStores {
-		t.Run(testData.name, func(t *testing.T) {
-
-			t.Run("TestCanCreateReadonlyTransaction", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-
-				assert.NotNil(t, tx)
-				assert.False(t, tx.IsWritable())
-				tx.Rollback()
-			})
-
-			t.Run("TestCanCreateTwoReadonlyTransaction", func(t *testing.T) {
-				t.Parallel()
-				tx1, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				assert.NotNil(t, tx1)
-
-				tx2, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				assert.NotNil(t, tx2)
-
-				assert.False(t, tx1.IsWritable())
-				assert.False(t, tx2.IsWritable())
-				tx1.Rollback()
-				tx2.Rollback()
-			})
-
-			t.Run("TestCanCreateWriteTransaction", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-
-				assert.NotNil(t, tx)
-				assert.True(t, tx.IsWritable())
-				tx.Rollback()
-			})
-
-			t.Run("TestCanCreateReadWriteTransaction", func(t *testing.T) {
-				// don't run this test in parallel (risk of deadlock)
-				tx1, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				assert.NotNil(t, tx1)
-
-				tx2, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				assert.NotNil(t, tx2)
-
-				assert.False(t, tx1.IsWritable())
-				assert.True(t, tx2.IsWritable())
-				tx1.Rollback()
-				tx2.Rollback()
-			})
-
-			t.Run("TestCanCreateWriteReadTransaction", func(t *testing.T) {
-				// don't run this test in parallel (risk of deadlock)
-				tx1, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				assert.NotNil(t, tx1)
-
-				tx2, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				assert.NotNil(t, tx2)
-
-				assert.True(t, tx1.IsWritable())
-				assert.False(t, tx2.IsWritable())
-				tx1.Rollback()
-				tx2.Rollback()
-			})
-
-			t.Run("TestCannotLoadEmptyNameBucket", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				_, err = tx.GetBucket("")
-				assert.Equal(t, ErrBucketNoName, err)
-			})
-
-			t.Run("TestCannotCreateEmptyNameBucket", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				_, err = tx.CreateBucket("")
-				assert.Equal(t, ErrBucketNoName, err)
-			})
-
-			t.Run("TestCannotDeleteEmptyNameBucket", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				err = tx.DeleteBucket("")
-				assert.Equal(t, ErrBucketNoName, err)
-			})
-
-			t.Run("TestCannotLoadUnknownBucket", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				_, err = tx.GetBucket(UnknownBucketKey)
-				assert.Equal(t, ErrBucketNotFound, err)
-			})
-
-			t.Run("TestCannotCreateBucketInReadOnlyTransaction", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				_, err = tx.CreateBucket(UnknownBucketKey)
-				assert.Equal(t, ErrTransactionReadonly, err)
-			})
-
-			t.Run("TestCannotDeleteBucketInReadOnlyTransaction", func(t *testing.T) {
-				t.Parallel()
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				err = tx.DeleteBucket(UnknownBucketKey)
-				assert.Equal(t, ErrTransactionReadonly, err)
-			})
-
-			t.Run("TestCannotSetKeysInReadonlyBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
-
-				// Isolate transaction
-				func() {
-					tx, err := testData.store.Begin(true)
-					require.NoError(t, err)
-					defer tx.Rollback()
-
-					_, err = tx.CreateBucket(name)
-					require.NoError(t, err)
-					tx.Commit()
-				}()
-
-				tx, err := testData.store.Begin(false)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				b, err := tx.GetBucket(name)
-				require.NoError(t, err)
-				err = b.Put("something", []byte("is something"))
-				assert.Equal(t, ErrBucketReadOnly, err)
-			})
-
-			t.Run("TestLoadingUnknownKeyFromBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
-
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				bucket, err := tx.CreateBucket(name)
-				require.NoError(t, err)
-
-				_, err = bucket.Get("some-key")
-				assert.Equal(t, ErrKeyNotFound, err)
-			})
-
-			t.Run("TestSetKeyAndGetKeyFromBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
-
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				bucket, err := tx.CreateBucket(name)
-				require.NoError(t, err)
-
-				var value1, value2 []byte
-
-				value1 = []byte("test data")
-				err = bucket.Put("test-key", value1)
-				require.NoError(t, err)
-
-				value2, err = bucket.Get("test-key")
-				require.NoError(t, err)
-				assert.Equal(t, value1, value2)
-			})
-
-			t.Run("TestCreateBucketInBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
-
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				bucket, err := tx.CreateBucket(name)
-				require.NoError(t, err)
-
-				_, err = bucket.CreateBucket("sub-bucket")
-				require.NoError(t, err)
-			})
-
-			t.Run("TestGetBucketInBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
-
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
-
-				bucket, err := tx.CreateBucket(name)
-				require.NoError(t, err)
+func TestBoltStoreLockTimeout(t *testing.T) {
+	t.Parallel()
 
-				_, err = bucket.CreateBucket("sub-bucket")
-				require.NoError(t, err)
+	database := filepath.Join(boltTestPath(t), "locked.db")
+	boltStore, err := store.NewBoltStore(database)
+	require.NoError(t, err)
+	defer boltStore.Close()
 
-				_, err = bucket.GetBucket("sub-bucket")
-				require.NoError(t, err)
-			})
+	testData := []store.BoltOptions{
+		{LockTimeout: 50 * time.Millisecond},
+		{LockTimeout: 50 * time.Millisecond, ReadOnly: true},
+	}
+	for _, options := range testData {
+		start := time.Now()
+		_, err = store.NewBoltStoreWithOptions(database, options)
+		assert.ErrorIs(t, err, store.ErrDatabaseLocked)
+		assert.ErrorContains(t, err, database)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	}
+}
 
-			t.Run("TestDeleteBucketInBucket", func(t *testing.T) {
-				t.Parallel()
-				name := path.Base(t.Name())
+func TestBoltStoreReadOnly(t *testing.T) {
+	t.Parallel()
 
-				tx, err := testData.store.Begin(true)
-				require.NoError(t, err)
-				defer tx.Rollback()
+	database := filepath.Join(boltTestPath(t), "readonly.db")
+	boltStore, err := store.NewBoltStore(database)
+	require.NoError(t, err)
+	err = boltStore.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket(storetest.BucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+	boltStore.Close()
+
+	options := store.BoltOptions{ReadOnly: true, LockTimeout: 50 * time.Millisecond}
+	reader1, err := store.NewBoltStoreWithOptions(database, options)
+	require.NoError(t, err)
+	defer reader1.Close()
+	assert.True(t, reader1.IsReadOnly())
+
+	// many readers can share the file
+	reader2, err := store.NewBoltStoreWithOptions(database, options)
+	require.NoError(t, err)
+	defer reader2.Close()
+
+	err = reader2.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket(storetest.BucketName)
+		if err != nil {
+			return err
+		}
+		value, err := bucket.Get("key")
+		assert.Equal(t, []byte("value"), value)
+		return err
+	})
+	assert.NoError(t, err)
+
+	err = reader1.Update(func(transaction store.Transaction) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, store.ErrDatabaseReadOnly)
+
+	_, err = reader1.Begin(true)
+	assert.ErrorIs(t, err, store.ErrDatabaseReadOnly)
+}
 
-				bucket, err := tx.CreateBucket(name)
-				require.NoError(t, err)
+func TestBoltStoreOpenErrorIsWrapped(t *testing.T) {
+	t.Parallel()
 
-				_, err = bucket.CreateBucket("sub-bucket")
-				require.NoError(t, err)
+	database := filepath.Join(boltTestPath(t), "missing", "database.db")
+	_, err := store.NewBoltStore(database)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, database)
+}
 
-				err = bucket.DeleteBucket("sub-bucket")
-				require.NoError(t, err)
+func TestBoltStoreWriteTo(t *testing.T) {
+	t.Parallel()
 
-				_, err = bucket.GetBucket("sub-bucket")
-				require.ErrorIs(t, err, ErrBucketNotFound)
-			})
+	dir := boltTestPath(t)
+	boltStore, err := store.NewBoltStore(filepath.Join(dir, "source.db"))
+	require.NoError(t, err)
+	defer boltStore.Close()
 
-		})
-		testData.store.Close()
-	}
+	err = boltStore.Update(func(transaction store.Transaction) error {
+		bucket, err := transaction.CreateBucket(storetest.BucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	backup := filepath.Join(dir, "backup.db")
+	file, err := os.Create(backup)
+	require.NoError(t, err)
+	size, err := boltStore.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	info, err := os.Stat(backup)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), size)
+
+	copied, err := store.NewBoltStoreWithOptions(backup, store.BoltOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer copied.Close()
+
+	err = copied.View(func(transaction store.Transaction) error {
+		bucket, err := transaction.GetBucket(storetest.BucketName)
+		if err != nil {
+			return err
+		}
+		value, err := bucket.Get("key")
+		assert.Equal(t, []byte("value"), value)
+		return err
+	})
+	assert.NoError(t, err)
 }
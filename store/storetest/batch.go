@@ -0,0 +1,83 @@
+package storetest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var batchTests = []storeTest{
+	{"ConcurrentBatches", testConcurrentBatches},
+	{"FailingBatchDoesNotAffectOthers", testFailingBatchDoesNotAffectOthers},
+}
+
+func testConcurrentBatches(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.Batch(func(tx store.Transaction) error {
+				bucket, err := tx.GetBucket(BucketName)
+				if err != nil {
+					return err
+				}
+				return bucket.Put(strconv.Itoa(i), []byte("value"))
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		assertValue(t, s, []string{BucketName}, strconv.Itoa(i), []byte("value"))
+	}
+}
+
+func testFailingBatchDoesNotAffectOthers(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bucketName := BucketName
+			if i == 5 {
+				bucketName = UnknownBucketName
+			}
+			err := s.Batch(func(tx store.Transaction) error {
+				bucket, err := tx.GetBucket(bucketName)
+				if err != nil {
+					return err
+				}
+				return bucket.Put(strconv.Itoa(i), []byte("value"))
+			})
+			if i == 5 {
+				assert.ErrorIs(t, err, store.ErrBucketNotFound)
+				return
+			}
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	err := s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		count := 0
+		err = bucket.ForEach(func(key string, data []byte) error {
+			count++
+			return nil
+		})
+		assert.Equal(t, 9, count)
+		return err
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,242 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var bucketTests = []storeTest{
+	{"CannotLoadEmptyNameBucket", testCannotLoadEmptyNameBucket},
+	{"CannotCreateEmptyNameBucket", testCannotCreateEmptyNameBucket},
+	{"CannotDeleteEmptyNameBucket", testCannotDeleteEmptyNameBucket},
+	{"CannotLoadUnknownBucket", testCannotLoadUnknownBucket},
+	{"CannotDeleteUnknownBucket", testCannotDeleteUnknownBucket},
+	{"CannotCreateBucketTwice", testCannotCreateBucketTwice},
+	{"CannotUseEmptyKey", testCannotUseEmptyKey},
+	{"LoadingUnknownKeyFromBucket", testLoadingUnknownKeyFromBucket},
+	{"SetKeyAndGetKeyFromBucket", testSetKeyAndGetKeyFromBucket},
+	{"OverwriteKey", testOverwriteKey},
+	{"DeleteKey", testDeleteKey},
+	{"DeleteUnknownKey", testDeleteUnknownKey},
+	{"EmptyValue", testEmptyValue},
+	{"CanReuseBufferAfterPut", testCanReuseBufferAfterPut},
+	{"RecreateDeletedBucket", testRecreateDeletedBucket},
+}
+
+func testCannotLoadEmptyNameBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(false)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = tx.GetBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+}
+
+func testCannotCreateEmptyNameBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = tx.CreateBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+}
+
+func testCannotDeleteEmptyNameBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.DeleteBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+}
+
+func testCannotLoadUnknownBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(false)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = tx.GetBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+}
+
+func testCannotDeleteUnknownBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.DeleteBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+}
+
+func testCannotCreateBucketTwice(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	// bucket created in a previous transaction
+	_, err = tx.CreateBucket(BucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNameExists)
+
+	// bucket created in the same transaction
+	_, err = tx.CreateBucket("other-bucket")
+	require.NoError(t, err)
+	_, err = tx.CreateBucket("other-bucket")
+	assert.ErrorIs(t, err, store.ErrBucketNameExists)
+}
+
+func testCannotUseEmptyKey(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.Get("")
+	assert.ErrorIs(t, err, store.ErrKeyNoName)
+
+	err = bucket.Put("", []byte("value"))
+	assert.ErrorIs(t, err, store.ErrKeyNoName)
+
+	err = bucket.Delete("")
+	assert.ErrorIs(t, err, store.ErrKeyNoName)
+}
+
+func testLoadingUnknownKeyFromBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.Get("some-key")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func testSetKeyAndGetKeyFromBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	value1 := []byte("test data")
+	err = bucket.Put("test-key", value1)
+	require.NoError(t, err)
+
+	value2, err := bucket.Get("test-key")
+	require.NoError(t, err)
+	assert.Equal(t, value1, value2)
+}
+
+func testOverwriteKey(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key", []byte("first")))
+		return bucket.Put("key", []byte("second"))
+	})
+	require.NoError(t, err)
+
+	err = s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", []byte("third"))
+	})
+	require.NoError(t, err)
+
+	assertValue(t, s, []string{BucketName}, "key", []byte("third"))
+}
+
+func testDeleteKey(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key1", []byte("value")))
+		return bucket.Put("key2", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	err = s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Delete("key1"))
+
+		_, err = bucket.Get("key1")
+		assert.ErrorIs(t, err, store.ErrKeyNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assertKeyNotFound(t, s, []string{BucketName}, "key1")
+	assertValue(t, s, []string{BucketName}, "key2", []byte("value"))
+}
+
+func testDeleteUnknownKey(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Delete("key")
+	})
+	assert.NoError(t, err)
+}
+
+func testEmptyValue(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", []byte{})
+	})
+	require.NoError(t, err)
+
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		value, err := bucket.Get("key")
+		require.NoError(t, err)
+		assert.Empty(t, value)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testCanReuseBufferAfterPut(t *testing.T, s store.Store) {
+	buffer := []byte("value")
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", buffer)
+	})
+	require.NoError(t, err)
+
+	copy(buffer, "XXXXX")
+	assertValue(t, s, []string{BucketName}, "key", []byte("value"))
+}
+
+func testRecreateDeletedBucket(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	err = s.Update(func(tx store.Transaction) error {
+		require.NoError(t, tx.DeleteBucket(BucketName))
+		_, err := tx.GetBucket(BucketName)
+		assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+		_, err = tx.CreateBucket(BucketName)
+		return err
+	})
+	require.NoError(t, err)
+
+	assertKeyNotFound(t, s, []string{BucketName}, "key")
+}
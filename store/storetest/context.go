@@ -0,0 +1,91 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var contextTests = []storeTest{
+	{"UpdateContext", testUpdateContext},
+	{"UpdateContextCancelledBeforeStart", testUpdateContextCancelledBeforeStart},
+	{"UpdateContextCancelledDuringJob", testUpdateContextCancelledDuringJob},
+	{"ViewContext", testViewContext},
+	{"ViewContextCancelled", testViewContextCancelled},
+}
+
+func testUpdateContext(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	err := s.UpdateContext(context.Background(), func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	assertValue(t, s, []string{BucketName}, "key", []byte("value"))
+}
+
+func testUpdateContextCancelledBeforeStart(t *testing.T, s store.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.UpdateContext(ctx, func(tx store.Transaction) error {
+		t.Error("the job should not run")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func testUpdateContextCancelledDuringJob(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.UpdateContext(ctx, func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key", []byte("value")))
+		_, err = tx.CreateBucket("other-bucket")
+		require.NoError(t, err)
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// nothing was committed
+	assertKeyNotFound(t, s, []string{BucketName}, "key")
+	assertBucketNotFound(t, s, []string{"other-bucket"})
+
+	// the store is still usable
+	createBucket(t, s, "other-bucket")
+}
+
+func testViewContext(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	err := s.ViewContext(context.Background(), func(tx store.Transaction) error {
+		assert.False(t, tx.IsWritable())
+		_, err := tx.GetBucket(BucketName)
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+func testViewContextCancelled(t *testing.T, s store.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.ViewContext(ctx, func(tx store.Transaction) error {
+		t.Error("the job should not run")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
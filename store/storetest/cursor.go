@@ -0,0 +1,170 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cursorTests = []storeTest{
+	{"ForEachInByteOrder", testForEachInByteOrder},
+	{"ForEachStopsOnError", testForEachStopsOnError},
+	{"ForEachPrefix", testForEachPrefix},
+	{"ForEachRange", testForEachRange},
+	{"CursorMoves", testCursorMoves},
+	{"CursorOnEmptyBucket", testCursorOnEmptyBucket},
+	{"CursorAfterCommit", testCursorAfterCommit},
+}
+
+func testForEachInByteOrder(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket := createBucketWithKeys(t, tx, BucketName)
+
+	keys := make([]string, 0, len(unorderedKeys))
+	err = bucket.ForEach(func(key string, data []byte) error {
+		assert.Equal(t, key, string(data))
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, orderedKeys, keys)
+}
+
+func testForEachStopsOnError(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket := createBucketWithKeys(t, tx, BucketName)
+
+	stop := errors.New("stop")
+	count := 0
+	err = bucket.ForEach(func(key string, data []byte) error {
+		count++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, count)
+}
+
+func testForEachPrefix(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket := createBucketWithKeys(t, tx, BucketName)
+
+	keys := make([]string, 0, len(unorderedKeys))
+	err = bucket.ForEachPrefix("a", func(key string, data []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "ab", "abc"}, keys)
+}
+
+func testForEachRange(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket := createBucketWithKeys(t, tx, BucketName)
+
+	keys := make([]string, 0, len(unorderedKeys))
+	err = bucket.ForEachRange("a/", "b", func(key string, data []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b", "ab", "abc"}, keys)
+
+	keys = keys[:0]
+	err = bucket.ForEachRange("b", "", func(key string, data []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "é"}, keys)
+}
+
+func testCursorMoves(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket := createBucketWithKeys(t, tx, BucketName)
+	cursor := bucket.Cursor()
+
+	key, data := cursor.First()
+	assert.Equal(t, orderedKeys[0], key)
+	assert.Equal(t, []byte(orderedKeys[0]), data)
+
+	key, _ = cursor.Last()
+	assert.Equal(t, orderedKeys[len(orderedKeys)-1], key)
+
+	key, _ = cursor.Prev()
+	assert.Equal(t, orderedKeys[len(orderedKeys)-2], key)
+
+	key, _ = cursor.Seek("aa")
+	assert.Equal(t, "ab", key)
+
+	key, _ = cursor.Seek("abc")
+	assert.Equal(t, "abc", key)
+
+	key, _ = cursor.Next()
+	assert.Equal(t, "b", key)
+
+	key, _ = cursor.Seek("ê")
+	assert.Equal(t, "", key)
+
+	cursor.First()
+	key, data = cursor.Prev()
+	assert.Equal(t, "", key)
+	assert.Nil(t, data)
+}
+
+func testCursorOnEmptyBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	cursor := bucket.Cursor()
+	key, _ := cursor.First()
+	assert.Equal(t, "", key)
+	key, _ = cursor.Last()
+	assert.Equal(t, "", key)
+	key, _ = cursor.Seek("a")
+	assert.Equal(t, "", key)
+}
+
+func testCursorAfterCommit(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	createBucketWithKeys(t, tx, BucketName)
+	require.NoError(t, tx.Commit())
+
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+
+		// backwards
+		keys := make([]string, 0, len(unorderedKeys))
+		cursor := bucket.Cursor()
+		for key, data := cursor.Last(); key != ""; key, data = cursor.Prev() {
+			assert.Equal(t, key, string(data))
+			keys = append([]string{key}, keys...)
+		}
+		assert.Equal(t, orderedKeys, keys)
+		return nil
+	})
+	require.NoError(t, err)
+}
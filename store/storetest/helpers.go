@@ -0,0 +1,106 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	BucketName        = "bucket"
+	UnknownBucketName = "unknown-bucket"
+)
+
+var (
+	// unorderedKeys are saved in a bucket to test the iterations
+	unorderedKeys = []string{"b", "abc", "a", "é", "B", "ab", "a/b", "1", "A"}
+	// orderedKeys are the unorderedKeys sorted in byte order
+	orderedKeys = []string{"1", "A", "B", "a", "a/b", "ab", "abc", "b", "é"}
+)
+
+// createBucket creates a top level bucket in its own transaction
+func createBucket(t *testing.T, s store.Store, name string) {
+	t.Helper()
+
+	err := s.Update(func(tx store.Transaction) error {
+		_, err := tx.CreateBucket(name)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+// getBucket returns the bucket at the path
+func getBucket(tx store.Transaction, path []string) (store.Bucket, error) {
+	bucket, err := tx.GetBucket(path[0])
+	for _, name := range path[1:] {
+		if err != nil {
+			return nil, err
+		}
+		bucket, err = bucket.GetBucket(name)
+	}
+	return bucket, err
+}
+
+// assertValue checks the value of a key in a new read-only transaction
+func assertValue(t *testing.T, s store.Store, path []string, key string, expected []byte) {
+	t.Helper()
+
+	err := s.View(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, path)
+		if err != nil {
+			return err
+		}
+		value, err := bucket.Get(key)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, expected, value)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+// assertKeyNotFound checks a key doesn't exist in a new read-only transaction
+func assertKeyNotFound(t *testing.T, s store.Store, path []string, key string) {
+	t.Helper()
+
+	err := s.View(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, path)
+		if err != nil {
+			return err
+		}
+		_, err = bucket.Get(key)
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+// assertBucketNotFound checks a bucket doesn't exist in a new read-only transaction
+func assertBucketNotFound(t *testing.T, s store.Store, path []string) {
+	t.Helper()
+
+	err := s.View(func(tx store.Transaction) error {
+		_, err := getBucket(tx, path)
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+}
+
+// createBucketWithKeys creates a bucket containing all the unorderedKeys (with their name as a value),
+// and a nested bucket which should never appear in the iterations
+func createBucketWithKeys(t *testing.T, tx store.Transaction, name string) store.Bucket {
+	t.Helper()
+
+	bucket, err := tx.CreateBucket(name)
+	require.NoError(t, err)
+
+	for _, key := range unorderedKeys {
+		err = bucket.Put(key, []byte(key))
+		require.NoError(t, err)
+	}
+	_, err = bucket.CreateBucket("a-nested-bucket")
+	require.NoError(t, err)
+	return bucket
+}
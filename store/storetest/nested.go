@@ -0,0 +1,315 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var nestedBucketTests = []storeTest{
+	{"CreateBucketInBucket", testCreateBucketInBucket},
+	{"GetBucketInBucket", testGetBucketInBucket},
+	{"DeleteBucketInBucket", testDeleteBucketInBucket},
+	{"NestedBucketErrors", testNestedBucketErrors},
+	{"NestedBucketIsNotVisibleAtTopLevel", testNestedBucketIsNotVisibleAtTopLevel},
+	{"SlashInBucketNameDoesNotCollide", testSlashInBucketNameDoesNotCollide},
+	{"SameNameAtDifferentLevels", testSameNameAtDifferentLevels},
+	{"KeyAndBucketCannotShareName", testKeyAndBucketCannotShareName},
+	{"DeleteBucketDeletesNestedBuckets", testDeleteBucketDeletesNestedBuckets},
+	{"NestedBucketsAreCommitted", testNestedBucketsAreCommitted},
+	{"NestedBucketsAreRolledBack", testNestedBucketsAreRolledBack},
+	{"ForEachBucketAtTopLevel", testForEachBucketAtTopLevel},
+	{"ForEachBucketInBucket", testForEachBucketInBucket},
+	{"ForEachBucketStopsOnError", testForEachBucketStopsOnError},
+}
+
+func testCreateBucketInBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.CreateBucket("sub-bucket")
+	require.NoError(t, err)
+}
+
+func testGetBucketInBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.CreateBucket("sub-bucket")
+	require.NoError(t, err)
+
+	_, err = bucket.GetBucket("sub-bucket")
+	require.NoError(t, err)
+}
+
+func testDeleteBucketInBucket(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.CreateBucket("sub-bucket")
+	require.NoError(t, err)
+
+	err = bucket.DeleteBucket("sub-bucket")
+	require.NoError(t, err)
+
+	_, err = bucket.GetBucket("sub-bucket")
+	require.ErrorIs(t, err, store.ErrBucketNotFound)
+}
+
+func testNestedBucketErrors(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.CreateBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+
+	_, err = bucket.GetBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+
+	err = bucket.DeleteBucket("")
+	assert.ErrorIs(t, err, store.ErrBucketNoName)
+
+	_, err = bucket.GetBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	err = bucket.DeleteBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	_, err = bucket.CreateBucket("sub-bucket")
+	require.NoError(t, err)
+	_, err = bucket.CreateBucket("sub-bucket")
+	assert.ErrorIs(t, err, store.ErrBucketNameExists)
+}
+
+func testNestedBucketIsNotVisibleAtTopLevel(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = bucket.CreateBucket("child")
+	require.NoError(t, err)
+
+	_, err = tx.GetBucket(BucketName + "/child")
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+	_, err = tx.GetBucket("child")
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+}
+
+func testSlashInBucketNameDoesNotCollide(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	parent, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+	child, err := parent.CreateBucket("child")
+	require.NoError(t, err)
+	require.NoError(t, child.Put("key", []byte("nested")))
+
+	flat, err := tx.CreateBucket(BucketName + "/child")
+	require.NoError(t, err)
+	require.NoError(t, flat.Put("key", []byte("flat")))
+
+	child, err = parent.GetBucket("child")
+	require.NoError(t, err)
+	value, err := child.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("nested"), value)
+
+	flat, err = tx.GetBucket(BucketName + "/child")
+	require.NoError(t, err)
+	value, err = flat.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("flat"), value)
+}
+
+func testSameNameAtDifferentLevels(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	parent, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+	child, err := parent.CreateBucket(BucketName)
+	require.NoError(t, err)
+	_, err = child.CreateBucket(BucketName)
+	require.NoError(t, err)
+
+	_, err = parent.CreateBucket(BucketName)
+	assert.ErrorIs(t, err, store.ErrBucketNameExists)
+}
+
+func testKeyAndBucketCannotShareName(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+	_, err = bucket.CreateBucket("bucket")
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("value")))
+
+	err = bucket.Put("bucket", []byte("value"))
+	assert.ErrorIs(t, err, store.ErrIncompatibleValue)
+
+	err = bucket.Delete("bucket")
+	assert.ErrorIs(t, err, store.ErrIncompatibleValue)
+
+	_, err = bucket.CreateBucket("key")
+	assert.ErrorIs(t, err, store.ErrIncompatibleValue)
+
+	_, err = bucket.GetBucket("key")
+	assert.ErrorIs(t, err, store.ErrBucketNotFound)
+
+	err = bucket.DeleteBucket("key")
+	assert.ErrorIs(t, err, store.ErrIncompatibleValue)
+
+	_, err = bucket.Get("bucket")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func testDeleteBucketDeletesNestedBuckets(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		parent, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		child, err := parent.CreateBucket("child")
+		require.NoError(t, err)
+		grandChild, err := child.CreateBucket("grand-child")
+		require.NoError(t, err)
+		return grandChild.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	err = s.Update(func(tx store.Transaction) error {
+		require.NoError(t, tx.DeleteBucket(BucketName))
+		parent, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+
+		_, err = parent.GetBucket("child")
+		assert.ErrorIs(t, err, store.ErrBucketNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assertBucketNotFound(t, s, []string{BucketName, "child"})
+}
+
+func testNestedBucketsAreCommitted(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		parent, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		child, err := parent.CreateBucket("child")
+		require.NoError(t, err)
+		return child.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	assertValue(t, s, []string{BucketName, "child"}, "key", []byte("value"))
+}
+
+func testNestedBucketsAreRolledBack(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	rollback := errors.New("rollback")
+	err := s.Update(func(tx store.Transaction) error {
+		parent, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		child, err := parent.CreateBucket("child")
+		require.NoError(t, err)
+		require.NoError(t, child.Put("key", []byte("value")))
+		return rollback
+	})
+	require.Equal(t, rollback, err)
+
+	assertBucketNotFound(t, s, []string{BucketName, "child"})
+}
+
+func testForEachBucketAtTopLevel(t *testing.T, s store.Store) {
+	for _, name := range unorderedKeys {
+		createBucket(t, s, name)
+	}
+
+	names := make([]string, 0, len(orderedKeys))
+	err := s.View(func(tx store.Transaction) error {
+		return tx.ForEachBucket(func(name string) error {
+			names = append(names, name)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, orderedKeys, names)
+}
+
+func testForEachBucketInBucket(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"sub2", "sub1"} {
+			sub, err := bucket.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			_, err = sub.CreateBucket("grandchild")
+			if err != nil {
+				return err
+			}
+		}
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	names := make([]string, 0, 2)
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEachBucket(func(name string) error {
+			names = append(names, name)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub1", "sub2"}, names)
+}
+
+func testForEachBucketStopsOnError(t *testing.T, s store.Store) {
+	createBucket(t, s, "bucket1")
+	createBucket(t, s, "bucket2")
+
+	stop := errors.New("stop")
+	count := 0
+	err := s.View(func(tx store.Transaction) error {
+		return tx.ForEachBucket(func(name string) error {
+			count++
+			return stop
+		})
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, count)
+}
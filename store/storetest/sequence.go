@@ -0,0 +1,139 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sequenceTests = []storeTest{
+	{"NewBucketSequenceIsZero", testNewBucketSequenceIsZero},
+	{"NextSequence", testNextSequence},
+	{"SetSequence", testSetSequence},
+	{"SequenceIsCommitted", testSequenceIsCommitted},
+	{"SequenceIsRolledBack", testSequenceIsRolledBack},
+	{"SequenceIsPerBucket", testSequenceIsPerBucket},
+	{"SequenceIsReadOnly", testSequenceIsReadOnly},
+}
+
+func testNewBucketSequenceIsZero(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), bucket.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testNextSequence(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		for expected := uint64(1); expected <= 3; expected++ {
+			sequence, err := bucket.NextSequence()
+			require.NoError(t, err)
+			assert.Equal(t, expected, sequence)
+			assert.Equal(t, expected, bucket.Sequence())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testSetSequence(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.SetSequence(1000))
+		assert.Equal(t, uint64(1000), bucket.Sequence())
+
+		sequence, err := bucket.NextSequence()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1001), sequence)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testSequenceIsCommitted(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, []string{BucketName})
+		require.NoError(t, err)
+		_, err = bucket.NextSequence()
+		return err
+	})
+	require.NoError(t, err)
+
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, []string{BucketName})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), bucket.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testSequenceIsRolledBack(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	bucket, err := getBucket(tx, []string{BucketName})
+	require.NoError(t, err)
+	_, err = bucket.NextSequence()
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, []string{BucketName})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), bucket.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testSequenceIsPerBucket(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		nested, err := bucket.CreateBucket("nested")
+		require.NoError(t, err)
+
+		require.NoError(t, bucket.SetSequence(10))
+		sequence, err := nested.NextSequence()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), sequence)
+		assert.Equal(t, uint64(10), bucket.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = s.View(func(tx store.Transaction) error {
+		nested, err := getBucket(tx, []string{BucketName, "nested"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), nested.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testSequenceIsReadOnly(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	err := s.View(func(tx store.Transaction) error {
+		bucket, err := getBucket(tx, []string{BucketName})
+		require.NoError(t, err)
+
+		_, err = bucket.NextSequence()
+		assert.ErrorIs(t, err, store.ErrBucketReadOnly)
+		err = bucket.SetSequence(10)
+		assert.ErrorIs(t, err, store.ErrBucketReadOnly)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,47 @@
+package storetest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var snapshotTests = []storeTest{
+	{"WriteTo", testWriteTo},
+	{"WriteToDuringWriteTransaction", testWriteToDuringWriteTransaction},
+}
+
+func testWriteTo(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		createBucketWithKeys(t, tx, BucketName)
+		return nil
+	})
+	require.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	size, err := s.WriteTo(buffer)
+	require.NoError(t, err)
+	assert.Greater(t, size, int64(0))
+	assert.Equal(t, int64(buffer.Len()), size)
+}
+
+func testWriteToDuringWriteTransaction(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	bucket, err := tx.GetBucket(BucketName)
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("value")))
+
+	// the snapshot must not wait for the write transaction
+	buffer := &bytes.Buffer{}
+	size, err := s.WriteTo(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buffer.Len()), size)
+}
@@ -0,0 +1,88 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var statsTests = []storeTest{
+	{"EmptyBucketStats", testEmptyBucketStats},
+	{"BucketStats", testBucketStats},
+	{"BucketStatsAfterCommit", testBucketStatsAfterCommit},
+}
+
+func testEmptyBucketStats(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	err := s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		stats := bucket.Stats()
+		assert.Zero(t, stats.Keys)
+		assert.Zero(t, stats.Buckets)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testBucketStats(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket := createBucketWithKeys(t, tx, BucketName)
+		nested, err := bucket.GetBucket("a-nested-bucket")
+		require.NoError(t, err)
+		require.NoError(t, nested.Put("key1", []byte("value1")))
+		require.NoError(t, nested.Put("key2", []byte("value2")))
+		_, err = nested.CreateBucket("deeper")
+		return err
+	})
+	require.NoError(t, err)
+
+	err = s.View(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		nested, err := bucket.GetBucket("a-nested-bucket")
+		require.NoError(t, err)
+		stats := nested.Stats()
+		assert.Equal(t, 2, stats.Keys)
+		assert.Equal(t, 1, stats.Buckets)
+
+		stats = bucket.Stats()
+		assert.Equal(t, len(unorderedKeys)+2, stats.Keys)
+		assert.Equal(t, 2, stats.Buckets)
+		assert.Greater(t, stats.InUse, 0)
+		assert.GreaterOrEqual(t, stats.Allocated, stats.InUse)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testBucketStatsAfterCommit(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	var previous store.BucketStats
+	for i := 0; i < 3; i++ {
+		err := s.Update(func(tx store.Transaction) error {
+			bucket, err := tx.GetBucket(BucketName)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(fmt.Sprintf("key%d", i), make([]byte, 100))
+		})
+		require.NoError(t, err)
+
+		err = s.View(func(tx store.Transaction) error {
+			bucket, err := tx.GetBucket(BucketName)
+			require.NoError(t, err)
+			stats := bucket.Stats()
+			assert.Equal(t, i+1, stats.Keys)
+			assert.Greater(t, stats.InUse, previous.InUse)
+			previous = stats
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
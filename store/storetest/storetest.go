@@ -0,0 +1,49 @@
+// Package storetest is the behavioural contract of a store.Store implementation.
+//
+// Every backend should pass the suite:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func() store.Store {
+//			return NewMyStore()
+//		})
+//	}
+package storetest
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+)
+
+type storeTest struct {
+	name string
+	test func(t *testing.T, s store.Store)
+}
+
+// Run the whole suite against the stores created by the factory.
+// Each test is running in parallel on a new empty store, which is closed at the end of the test.
+func Run(t *testing.T, factory func() store.Store) {
+	t.Helper()
+
+	suite := make([]storeTest, 0, 100)
+	suite = append(suite, transactionTests...)
+	suite = append(suite, bucketTests...)
+	suite = append(suite, nestedBucketTests...)
+	suite = append(suite, cursorTests...)
+	suite = append(suite, batchTests...)
+	suite = append(suite, snapshotTests...)
+	suite = append(suite, sequenceTests...)
+	suite = append(suite, contextTests...)
+	suite = append(suite, statsTests...)
+
+	for _, testCase := range suite {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := factory()
+			defer s.Close()
+
+			testCase.test(t, s)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/creativeprojects/catalogue/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transactionTests = []storeTest{
+	{"CanCreateReadonlyTransaction", testCanCreateReadonlyTransaction},
+	{"CanCreateTwoReadonlyTransactions", testCanCreateTwoReadonlyTransactions},
+	{"CanCreateWriteTransaction", testCanCreateWriteTransaction},
+	{"CanCreateReadWriteTransactions", testCanCreateReadWriteTransactions},
+	{"CanCreateWriteReadTransactions", testCanCreateWriteReadTransactions},
+	{"CommitIsVisibleToNextTransactions", testCommitIsVisibleToNextTransactions},
+	{"RollbackDiscardsChanges", testRollbackDiscardsChanges},
+	{"UpdateRollsBackOnError", testUpdateRollsBackOnError},
+	{"UpdateRollsBackOnPanic", testUpdateRollsBackOnPanic},
+	{"ViewIsReadOnly", testViewIsReadOnly},
+	{"ReadOnlyTransactionErrors", testReadOnlyTransactionErrors},
+}
+
+func testCanCreateReadonlyTransaction(t *testing.T, s store.Store) {
+	tx, err := s.Begin(false)
+	require.NoError(t, err)
+
+	assert.NotNil(t, tx)
+	assert.False(t, tx.IsWritable())
+	assert.NoError(t, tx.Rollback())
+}
+
+func testCanCreateTwoReadonlyTransactions(t *testing.T, s store.Store) {
+	tx1, err := s.Begin(false)
+	require.NoError(t, err)
+	assert.NotNil(t, tx1)
+
+	tx2, err := s.Begin(false)
+	require.NoError(t, err)
+	assert.NotNil(t, tx2)
+
+	assert.False(t, tx1.IsWritable())
+	assert.False(t, tx2.IsWritable())
+	assert.NoError(t, tx1.Rollback())
+	assert.NoError(t, tx2.Rollback())
+}
+
+func testCanCreateWriteTransaction(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+
+	assert.NotNil(t, tx)
+	assert.True(t, tx.IsWritable())
+	assert.NoError(t, tx.Rollback())
+}
+
+func testCanCreateReadWriteTransactions(t *testing.T, s store.Store) {
+	tx1, err := s.Begin(false)
+	require.NoError(t, err)
+	assert.NotNil(t, tx1)
+
+	tx2, err := s.Begin(true)
+	require.NoError(t, err)
+	assert.NotNil(t, tx2)
+
+	assert.False(t, tx1.IsWritable())
+	assert.True(t, tx2.IsWritable())
+	assert.NoError(t, tx1.Rollback())
+	assert.NoError(t, tx2.Rollback())
+}
+
+func testCanCreateWriteReadTransactions(t *testing.T, s store.Store) {
+	tx1, err := s.Begin(true)
+	require.NoError(t, err)
+	assert.NotNil(t, tx1)
+
+	tx2, err := s.Begin(false)
+	require.NoError(t, err)
+	assert.NotNil(t, tx2)
+
+	assert.True(t, tx1.IsWritable())
+	assert.False(t, tx2.IsWritable())
+	assert.NoError(t, tx1.Rollback())
+	assert.NoError(t, tx2.Rollback())
+}
+
+func testCommitIsVisibleToNextTransactions(t *testing.T, s store.Store) {
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	bucket, err := tx.CreateBucket(BucketName)
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("value")))
+	require.NoError(t, tx.Commit())
+
+	assertValue(t, s, []string{BucketName}, "key", []byte("value"))
+}
+
+func testRollbackDiscardsChanges(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	tx, err := s.Begin(true)
+	require.NoError(t, err)
+	bucket, err := tx.GetBucket(BucketName)
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put("key", []byte("value")))
+	_, err = tx.CreateBucket("other-bucket")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	assertKeyNotFound(t, s, []string{BucketName}, "key")
+	assertBucketNotFound(t, s, []string{"other-bucket"})
+}
+
+func testUpdateRollsBackOnError(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	failure := errors.New("failure")
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put("key", []byte("value")))
+		return failure
+	})
+	assert.Equal(t, failure, err)
+
+	assertKeyNotFound(t, s, []string{BucketName}, "key")
+}
+
+func testUpdateRollsBackOnPanic(t *testing.T, s store.Store) {
+	createBucket(t, s, BucketName)
+
+	assert.Panics(t, func() {
+		_ = s.Update(func(tx store.Transaction) error {
+			bucket, err := tx.GetBucket(BucketName)
+			require.NoError(t, err)
+			require.NoError(t, bucket.Put("key", []byte("value")))
+			panic("failure")
+		})
+	})
+
+	assertKeyNotFound(t, s, []string{BucketName}, "key")
+
+	// the store is still usable
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.GetBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+}
+
+func testViewIsReadOnly(t *testing.T, s store.Store) {
+	err := s.View(func(tx store.Transaction) error {
+		assert.False(t, tx.IsWritable())
+		_, err := tx.CreateBucket(BucketName)
+		return err
+	})
+	assert.ErrorIs(t, err, store.ErrTransactionReadonly)
+}
+
+func testReadOnlyTransactionErrors(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Transaction) error {
+		bucket, err := tx.CreateBucket(BucketName)
+		require.NoError(t, err)
+		_, err = bucket.CreateBucket(BucketName)
+		require.NoError(t, err)
+		return bucket.Put("key", []byte("value"))
+	})
+	require.NoError(t, err)
+
+	tx, err := s.Begin(false)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = tx.CreateBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrTransactionReadonly)
+
+	err = tx.DeleteBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrTransactionReadonly)
+
+	bucket, err := tx.GetBucket(BucketName)
+	require.NoError(t, err)
+
+	err = bucket.Put("something", []byte("is something"))
+	assert.ErrorIs(t, err, store.ErrBucketReadOnly)
+
+	err = bucket.Delete("key")
+	assert.ErrorIs(t, err, store.ErrBucketReadOnly)
+
+	_, err = bucket.CreateBucket(UnknownBucketName)
+	assert.ErrorIs(t, err, store.ErrTransactionReadonly)
+
+	err = bucket.DeleteBucket(BucketName)
+	assert.ErrorIs(t, err, store.ErrTransactionReadonly)
+}
@@ -0,0 +1,407 @@
+package store
+
+import (
+	"context"
+	"io"
+)
+
+// Transformer converts the keys and the values on their way to and from an underlying store
+type Transformer interface {
+	// Key returns the key saved in the underlying store
+	Key(key string) string
+	// Encode returns the data saved in the underlying store, in the bucket at the path
+	Encode(path []string, key string, data []byte) ([]byte, error)
+	// Decode returns the original key and data from the key and the data saved in the underlying store, in the bucket at the path
+	Decode(path []string, storedKey string, stored []byte) (string, []byte, error)
+	// Ordered is true when the keys saved in the underlying store keep the original byte order
+	Ordered() bool
+}
+
+// TransformStore is a Store decorator running all the keys and the values through a Transformer.
+// Bucket names are not transformed.
+// The top level bucket holding the settings of the transformer (if any) is hidden from the transactions.
+//
+// When the transformer doesn't keep the keys in order, the cursors and the scans need to read
+// and sort the whole bucket first.
+type TransformStore struct {
+	store       Store
+	transformer Transformer
+	header      string
+}
+
+// NewTransformStore wraps the store with the transformer
+func NewTransformStore(store Store, transformer Transformer) *TransformStore {
+	return newTransformStore(store, transformer, "")
+}
+
+// newTransformStore wraps the store with the transformer, hiding the header bucket
+func newTransformStore(store Store, transformer Transformer, header string) *TransformStore {
+	return &TransformStore{
+		store:       store,
+		transformer: transformer,
+		header:      header,
+	}
+}
+
+// Unwrap returns the underlying store
+func (s *TransformStore) Unwrap() Store {
+	return s.store
+}
+
+// Begin a transaction
+func (s *TransformStore) Begin(writable bool) (Transaction, error) {
+	tx, err := s.store.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return s.wrap(tx), nil
+}
+
+// Close the underlying store
+func (s *TransformStore) Close() {
+	s.store.Close()
+}
+
+// Update run the job in a transaction
+func (s *TransformStore) Update(job func(transaction Transaction) error) error {
+	return s.store.Update(func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// View run the job in a read-only transaction
+func (s *TransformStore) View(job func(transaction Transaction) error) error {
+	return s.store.View(func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// UpdateContext run the job in a transaction, rolled back if the context is done before the commit
+func (s *TransformStore) UpdateContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.store.UpdateContext(ctx, func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// ViewContext run the job in a read-only transaction, returning the context error if the context is done
+func (s *TransformStore) ViewContext(ctx context.Context, job func(transaction Transaction) error) error {
+	return s.store.ViewContext(ctx, func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// Batch runs the job in a write transaction shared with other concurrent calls
+func (s *TransformStore) Batch(job func(transaction Transaction) error) error {
+	return s.store.Batch(func(transaction Transaction) error {
+		return job(s.wrap(transaction))
+	})
+}
+
+// WriteTo writes a snapshot of the underlying store: the values are saved transformed
+func (s *TransformStore) WriteTo(w io.Writer) (int64, error) {
+	return s.store.WriteTo(w)
+}
+
+func (s *TransformStore) wrap(transaction Transaction) *TransformTransaction {
+	return &TransformTransaction{
+		Transaction: transaction,
+		transformer: s.transformer,
+		header:      s.header,
+	}
+}
+
+// TransformTransaction is a transaction returning transformed buckets
+type TransformTransaction struct {
+	Transaction
+	transformer Transformer
+	header      string
+}
+
+// CreateBucket returns a new bucket. Returns an error if the name already exists
+func (t *TransformTransaction) CreateBucket(name string) (Bucket, error) {
+	if t.isHeader(name) {
+		return nil, ErrBucketNameExists
+	}
+	bucket, err := t.Transaction.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newTransformBucket(bucket, t.transformer, []string{name}), nil
+}
+
+// GetBucket returns a bucket from its name.
+func (t *TransformTransaction) GetBucket(name string) (Bucket, error) {
+	if t.isHeader(name) {
+		return nil, ErrBucketNotFound
+	}
+	bucket, err := t.Transaction.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newTransformBucket(bucket, t.transformer, []string{name}), nil
+}
+
+// DeleteBucket removes the bucket and all its nested buckets
+func (t *TransformTransaction) DeleteBucket(name string) error {
+	if t.isHeader(name) {
+		return ErrBucketNotFound
+	}
+	return t.Transaction.DeleteBucket(name)
+}
+
+// ForEachBucket runs the function on the name of every top level bucket, except the header
+func (t *TransformTransaction) ForEachBucket(fn func(name string) error) error {
+	return t.Transaction.ForEachBucket(func(name string) error {
+		if t.isHeader(name) {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+func (t *TransformTransaction) isHeader(name string) bool {
+	return t.header != "" && name == t.header
+}
+
+// TransformBucket is a bucket transforming its keys and values
+type TransformBucket struct {
+	bucket      Bucket
+	transformer Transformer
+	path        []string
+}
+
+func newTransformBucket(bucket Bucket, transformer Transformer, path []string) *TransformBucket {
+	return &TransformBucket{
+		bucket:      bucket,
+		transformer: transformer,
+		path:        path,
+	}
+}
+
+func (b *TransformBucket) Get(key string) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNullPointerBucket
+	}
+	if key == "" {
+		return nil, ErrKeyNoName
+	}
+	stored, err := b.bucket.Get(b.transformer.Key(key))
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := b.transformer.Decode(b.path, b.transformer.Key(key), stored)
+	return data, err
+}
+
+func (b *TransformBucket) Put(key string, data []byte) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	if key == "" {
+		return ErrKeyNoName
+	}
+	if !b.transformer.Ordered() && b.isBucket(key) {
+		return ErrIncompatibleValue
+	}
+	stored, err := b.transformer.Encode(b.path, key, data)
+	if err != nil {
+		return err
+	}
+	return b.bucket.Put(b.transformer.Key(key), stored)
+}
+
+func (b *TransformBucket) Delete(key string) error {
+	if b == nil {
+		return ErrNullPointerBucket
+	}
+	if key == "" {
+		return ErrKeyNoName
+	}
+	if !b.transformer.Ordered() && b.isBucket(key) {
+		return ErrIncompatibleValue
+	}
+	return b.bucket.Delete(b.transformer.Key(key))
+}
+
+func (b *TransformBucket) CreateBucket(name string) (Bucket, error) {
+	if !b.transformer.Ordered() && b.isKey(name) {
+		return nil, ErrIncompatibleValue
+	}
+	bucket, err := b.bucket.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newTransformBucket(bucket, b.transformer, childPath(b.path, name)), nil
+}
+
+func (b *TransformBucket) GetBucket(name string) (Bucket, error) {
+	bucket, err := b.bucket.GetBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return newTransformBucket(bucket, b.transformer, childPath(b.path, name)), nil
+}
+
+func (b *TransformBucket) DeleteBucket(name string) error {
+	if !b.transformer.Ordered() && b.isKey(name) {
+		return ErrIncompatibleValue
+	}
+	return b.bucket.DeleteBucket(name)
+}
+
+func (b *TransformBucket) NextSequence() (uint64, error) {
+	return b.bucket.NextSequence()
+}
+
+func (b *TransformBucket) Sequence() uint64 {
+	return b.bucket.Sequence()
+}
+
+func (b *TransformBucket) SetSequence(value uint64) error {
+	return b.bucket.SetSequence(value)
+}
+
+// Stats returns the storage used by the transformed data
+func (b *TransformBucket) Stats() BucketStats {
+	return b.bucket.Stats()
+}
+
+func (b *TransformBucket) ForEachBucket(fn func(name string) error) error {
+	return b.bucket.ForEachBucket(fn)
+}
+
+// isBucket returns true if a nested bucket exists with this name.
+// Transformed keys never collide with bucket names, so the check is needed to behave like the underlying store.
+func (b *TransformBucket) isBucket(name string) bool {
+	if name == "" {
+		return false
+	}
+	_, err := b.bucket.GetBucket(name)
+	return err == nil
+}
+
+// isKey returns true if a key exists with this name
+func (b *TransformBucket) isKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	_, err := b.bucket.Get(b.transformer.Key(key))
+	return err == nil
+}
+
+// Cursor returns a cursor decoding the keys and values.
+// A value which cannot be decoded stops the cursor: use ForEach to get the error.
+func (b *TransformBucket) Cursor() Cursor {
+	if !b.transformer.Ordered() {
+		data, err := b.decodeAll()
+		if err != nil {
+			return newMemoryCursor(nil)
+		}
+		return newMemoryCursor(data)
+	}
+	return &TransformCursor{
+		cursor:      b.bucket.Cursor(),
+		transformer: b.transformer,
+		path:        b.path,
+	}
+}
+
+func (b *TransformBucket) ForEach(fn func(key string, data []byte) error) error {
+	if !b.transformer.Ordered() {
+		data, err := b.decodeAll()
+		if err != nil {
+			return err
+		}
+		return forEach(newMemoryCursor(data), fn)
+	}
+	return b.bucket.ForEach(b.decode(fn))
+}
+
+func (b *TransformBucket) ForEachPrefix(prefix string, fn func(key string, data []byte) error) error {
+	if !b.transformer.Ordered() {
+		data, err := b.decodeAll()
+		if err != nil {
+			return err
+		}
+		return forEachPrefix(newMemoryCursor(data), prefix, fn)
+	}
+	return b.bucket.ForEachPrefix(b.transformer.Key(prefix), b.decode(fn))
+}
+
+func (b *TransformBucket) ForEachRange(from, to string, fn func(key string, data []byte) error) error {
+	if !b.transformer.Ordered() {
+		data, err := b.decodeAll()
+		if err != nil {
+			return err
+		}
+		return forEachRange(newMemoryCursor(data), from, to, fn)
+	}
+	return b.bucket.ForEachRange(b.transformer.Key(from), b.transformer.Key(to), b.decode(fn))
+}
+
+// decodeAll loads the whole bucket in memory, so it can be sorted by the original keys
+func (b *TransformBucket) decodeAll() (map[string][]byte, error) {
+	all := make(map[string][]byte)
+	err := b.bucket.ForEach(b.decode(func(key string, data []byte) error {
+		all[key] = data
+		return nil
+	}))
+	return all, err
+}
+
+// decode returns a function decoding the key and the data before calling fn
+func (b *TransformBucket) decode(fn func(key string, data []byte) error) func(key string, data []byte) error {
+	return func(storedKey string, stored []byte) error {
+		key, data, err := b.transformer.Decode(b.path, storedKey, stored)
+		if err != nil {
+			return err
+		}
+		return fn(key, data)
+	}
+}
+
+// TransformCursor is a cursor decoding the keys and values, when the transformer keeps the keys in order
+type TransformCursor struct {
+	cursor      Cursor
+	transformer Transformer
+	path        []string
+}
+
+func (c *TransformCursor) First() (string, []byte) {
+	return c.decode(c.cursor.First())
+}
+
+func (c *TransformCursor) Last() (string, []byte) {
+	return c.decode(c.cursor.Last())
+}
+
+func (c *TransformCursor) Next() (string, []byte) {
+	return c.decode(c.cursor.Next())
+}
+
+func (c *TransformCursor) Prev() (string, []byte) {
+	return c.decode(c.cursor.Prev())
+}
+
+func (c *TransformCursor) Seek(key string) (string, []byte) {
+	return c.decode(c.cursor.Seek(c.transformer.Key(key)))
+}
+
+func (c *TransformCursor) decode(storedKey string, stored []byte) (string, []byte) {
+	if storedKey == "" {
+		return "", nil
+	}
+	key, data, err := c.transformer.Decode(c.path, storedKey, stored)
+	if err != nil {
+		return "", nil
+	}
+	return key, data
+}
+
+// Test the interfaces
+var (
+	_ Store       = &TransformStore{}
+	_ Transaction = &TransformTransaction{}
+	_ Bucket      = &TransformBucket{}
+	_ Cursor      = &TransformCursor{}
+)
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/creativeprojects/catalogue/codec"
+)
+
+// TypedBucket reads and writes typed keys and values in a bucket, through a key codec and a value codec
+type TypedBucket[K, V any] struct {
+	bucket Bucket
+	keys   codec.KeyCodec[K]
+	values codec.Codec[V]
+}
+
+// NewTypedBucket wraps the bucket with the codecs
+func NewTypedBucket[K, V any](bucket Bucket, keys codec.KeyCodec[K], values codec.Codec[V]) *TypedBucket[K, V] {
+	return &TypedBucket[K, V]{
+		bucket: bucket,
+		keys:   keys,
+		values: values,
+	}
+}
+
+// Bucket returns the underlying bucket
+func (b *TypedBucket[K, V]) Bucket() Bucket {
+	return b.bucket
+}
+
+// Get returns the value of the key
+func (b *TypedBucket[K, V]) Get(key K) (V, error) {
+	var value V
+	encodedKey, err := b.keys.EncodeKey(key)
+	if err != nil {
+		return value, err
+	}
+	data, err := b.bucket.Get(encodedKey)
+	if err != nil {
+		return value, err
+	}
+	value, err = b.values.Decode(data)
+	if err != nil {
+		return value, fmt.Errorf("cannot decode value of key %q: %w", encodedKey, err)
+	}
+	return value, nil
+}
+
+// Put saves the value of the key
+func (b *TypedBucket[K, V]) Put(key K, value V) error {
+	encodedKey, err := b.keys.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := b.values.Encode(value)
+	if err != nil {
+		return fmt.Errorf("cannot encode value of key %q: %w", encodedKey, err)
+	}
+	return b.bucket.Put(encodedKey, data)
+}
+
+// Delete removes the key
+func (b *TypedBucket[K, V]) Delete(key K) error {
+	encodedKey, err := b.keys.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	return b.bucket.Delete(encodedKey)
+}
+
+// ForEach runs the function on every key of the bucket, in the byte order of the encoded keys
+func (b *TypedBucket[K, V]) ForEach(fn func(key K, value V) error) error {
+	return b.bucket.ForEach(b.decode(fn))
+}
+
+// ForEachRange runs the function on every key from "from" (inclusive) to "to" (exclusive)
+func (b *TypedBucket[K, V]) ForEachRange(from, to K, fn func(key K, value V) error) error {
+	encodedFrom, err := b.keys.EncodeKey(from)
+	if err != nil {
+		return err
+	}
+	encodedTo, err := b.keys.EncodeKey(to)
+	if err != nil {
+		return err
+	}
+	return b.bucket.ForEachRange(encodedFrom, encodedTo, b.decode(fn))
+}
+
+// ForEachPrefix runs the function on every key whose encoding starts with the encoding of prefix.
+// It's only meaningful with a key codec keeping the prefixes, like codec.StringKey.
+func (b *TypedBucket[K, V]) ForEachPrefix(prefix K, fn func(key K, value V) error) error {
+	encodedPrefix, err := b.keys.EncodeKey(prefix)
+	if err != nil {
+		return err
+	}
+	return b.bucket.ForEachPrefix(encodedPrefix, b.decode(fn))
+}
+
+// decode returns a function decoding the key and the value before running fn
+func (b *TypedBucket[K, V]) decode(fn func(key K, value V) error) func(key string, data []byte) error {
+	return func(encodedKey string, data []byte) error {
+		key, err := b.keys.DecodeKey(encodedKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode key %q: %w", encodedKey, err)
+		}
+		value, err := b.values.Decode(data)
+		if err != nil {
+			return fmt.Errorf("cannot decode value of key %q: %w", encodedKey, err)
+		}
+		return fn(key, value)
+	}
+}
+
+// TypedKey is a single key with a typed value, like a field of a record saved in a bucket
+type TypedKey[V any] struct {
+	Name  string
+	Codec codec.Codec[V]
+}
+
+// NewTypedKey declares a key with its value codec
+func NewTypedKey[V any](name string, valueCodec codec.Codec[V]) TypedKey[V] {
+	return TypedKey[V]{
+		Name:  name,
+		Codec: valueCodec,
+	}
+}
+
+// Get reads the value of the key from the bucket
+func (k TypedKey[V]) Get(bucket Bucket) (V, error) {
+	var value V
+	data, err := bucket.Get(k.Name)
+	if err != nil {
+		return value, fmt.Errorf("cannot read key %q: %w", k.Name, err)
+	}
+	value, err = k.Codec.Decode(data)
+	if err != nil {
+		return value, fmt.Errorf("cannot decode value of key %q: %w", k.Name, err)
+	}
+	return value, nil
+}
+
+// Put saves the value of the key into the bucket
+func (k TypedKey[V]) Put(bucket Bucket, value V) error {
+	data, err := k.Codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("cannot encode value of key %q: %w", k.Name, err)
+	}
+	return bucket.Put(k.Name, data)
+}
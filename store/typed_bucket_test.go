@@ -0,0 +1,139 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/creativeprojects/catalogue/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedRecord struct {
+	Name string
+	Size int64
+}
+
+func TestTypedBucket(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("records")
+		if err != nil {
+			return err
+		}
+		typed := NewTypedBucket(bucket, codec.Uint64Key(), codec.CBOR[typedRecord]())
+		for _, id := range []uint64{300, 2, 10} {
+			if err := typed.Put(id, typedRecord{Name: "file", Size: int64(id)}); err != nil {
+				return err
+			}
+		}
+		return typed.Delete(10)
+	})
+	require.NoError(t, err)
+
+	err = store.View(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("records")
+		require.NoError(t, err)
+		typed := NewTypedBucket(bucket, codec.Uint64Key(), codec.CBOR[typedRecord]())
+
+		record, err := typed.Get(300)
+		require.NoError(t, err)
+		assert.Equal(t, typedRecord{Name: "file", Size: 300}, record)
+
+		_, err = typed.Get(10)
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+
+		// numerical order
+		ids := make([]uint64, 0, 2)
+		err = typed.ForEach(func(id uint64, record typedRecord) error {
+			ids = append(ids, id)
+			assert.Equal(t, int64(id), record.Size)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{2, 300}, ids)
+
+		ids = ids[:0]
+		err = typed.ForEachRange(100, 1000, func(id uint64, record typedRecord) error {
+			ids = append(ids, id)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{300}, ids)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestTypedBucketForEachPrefix(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("records")
+		if err != nil {
+			return err
+		}
+		typed := NewTypedBucket(bucket, codec.StringKey(), codec.Binary[int64]())
+		for i, name := range []string{"dir", "dir/file", "dir/other", "directory", "file"} {
+			if err := typed.Put(name, int64(i)); err != nil {
+				return err
+			}
+		}
+
+		names := make([]string, 0, 2)
+		err = typed.ForEachPrefix("dir/", func(name string, value int64) error {
+			names = append(names, name)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dir/file", "dir/other"}, names)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestTypedBucketDecodingErrors(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	defer store.Close()
+
+	err := store.Update(func(tx Transaction) error {
+		bucket, err := tx.CreateBucket("records")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("key", []byte{1, 2, 3})
+	})
+	require.NoError(t, err)
+
+	err = store.View(func(tx Transaction) error {
+		bucket, err := tx.GetBucket("records")
+		require.NoError(t, err)
+
+		typed := NewTypedBucket(bucket, codec.StringKey(), codec.Binary[uint64]())
+		_, err = typed.Get("key")
+		assert.ErrorIs(t, err, codec.ErrInvalidLength)
+
+		err = typed.ForEach(func(key string, value uint64) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, codec.ErrInvalidLength)
+
+		counter := NewTypedKey("key", codec.Binary[uint64]())
+		_, err = counter.Get(bucket)
+		assert.ErrorIs(t, err, codec.ErrInvalidLength)
+		assert.ErrorContains(t, err, "key")
+
+		_, err = NewTypedKey("missing", codec.Binary[uint64]()).Get(bucket)
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -8,7 +8,8 @@ import (
 
 // Volume represents a volume entity
 type Volume struct {
-	Name            string
+	Name            string // Name of the volume in the catalogue: the label of the file system unless renamed
+	Label           string // Label of the file system
 	VolumeType      Type
 	VolumeID        string
 	Format          string
@@ -51,6 +52,7 @@ func NewVolumeFromPath(volumePath string) (*Volume, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getFilesystemInfo: %w", err)
 	}
+	volume.Label = volume.Name
 
 	err = getDeviceID(volumePath, volume)
 	if err != nil {
@@ -60,23 +62,45 @@ func NewVolumeFromPath(volumePath string) (*Volume, error) {
 	return volume, nil
 }
 
+// IsMounted returns true when the volume is available at the path it was indexed from.
+// When the ID of the file system can be read, it must be the ID saved with the volume.
+func IsMounted(volume *Volume) bool {
+	if volume.PathIndex == "" {
+		return false
+	}
+	if _, err := os.Stat(volume.PathIndex); err != nil {
+		return false
+	}
+	if volume.VolumeID == "" {
+		return true
+	}
+	current, err := NewVolumeFromPath(volume.PathIndex)
+	if err != nil || current.VolumeID == "" {
+		return true
+	}
+	return current.VolumeID == volume.VolumeID
+}
+
 // PrintVolume prints volume information to the console
 func PrintVolume(volume *Volume) {
 	fmt.Printf("   Hostname: %s\n", volume.Hostname)
 	fmt.Printf("    Indexed: %s\n", volume.Indexed.Format(time.DateTime))
 	fmt.Printf("       Name: %s\n", volume.Name)
+	fmt.Printf("      Label: %s\n", volume.Label)
 	fmt.Printf(" Connection: %s\n", volume.Connection)
 	fmt.Printf("         ID: %s\n", volume.VolumeID)
 	fmt.Printf("     Device: %s\n", volume.Device)
 	fmt.Printf("       Type: %s\n", volume.VolumeType.String())
 	fmt.Printf("       Path: %s\n", volume.Path)
 	fmt.Printf("   To index: %s\n", volume.PathIndex)
+	fmt.Printf("   Location: %s\n", volume.Location)
 	fmt.Printf("     Format: %s\n", volume.Format)
-	fmt.Printf("Total space: %s\n", getBinaryBytes(volume.BytesTotal))
-	fmt.Printf(" Free space: %s\n", getBinaryBytes(volume.BytesFree))
+	fmt.Printf("Total space: %s\n", FormatBytes(volume.BytesTotal))
+	fmt.Printf(" Free space: %s\n", FormatBytes(volume.BytesFree))
 }
 
-func getBinaryBytes(b uint64) string {
+// FormatBytes returns the size in binary units (KiB, MiB...)
+func FormatBytes(b uint64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
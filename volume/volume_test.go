@@ -2,6 +2,7 @@ package volume
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/creativeprojects/catalogue/platform"
@@ -33,3 +34,12 @@ func TestCanGetVolumeInformation(t *testing.T) {
 		assert.NotEmpty(t, vol.DeviceID, "DeviceID should not be empty")
 	}
 }
+
+func TestIsMounted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	assert.True(t, IsMounted(&Volume{PathIndex: dir}))
+	assert.False(t, IsMounted(&Volume{PathIndex: filepath.Join(dir, "missing")}))
+	assert.False(t, IsMounted(&Volume{}))
+}